@@ -1,27 +1,42 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"AVTproject/grpcserver"
 	"AVTproject/handlers"
 	"AVTproject/models"
+	"AVTproject/notifications"
+	pb "AVTproject/pb/avtshop/v1"
 	"AVTproject/service"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
 )
 
 type inMemRepository struct {
+	// Методы, которые не используются в e2e-сценариях, не реализованы.
+	service.Repository
+
 	mu             sync.Mutex
 	users          map[int]models.User
 	usersByName    map[string]models.User
@@ -30,6 +45,7 @@ type inMemRepository struct {
 	nextUserID     int
 	nextTransID    int
 	nextPurchaseID int
+	hub            *notifications.Hub
 }
 
 func newInMemRepository() *inMemRepository {
@@ -74,49 +90,57 @@ func (r *inMemRepository) CreateUser(ctx context.Context, username, password str
 		Username: username,
 		Password: password,
 		Coins:    1000,
+		Role:     models.RoleUser,
 	}
 	r.users[id] = user
 	r.usersByName[username] = user
 	return id, nil
 }
 
-func (r *inMemRepository) UpdateUserCoins(ctx context.Context, id, delta int) error {
+func (r *inMemRepository) UpdateUserCoins(ctx context.Context, id, delta int) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	user, ok := r.users[id]
 	if !ok {
-		return sql.ErrNoRows
+		return 0, sql.ErrNoRows
 	}
 	newCoins := user.Coins + delta
 	if newCoins < 0 {
-		return errors.New("недостаточно монет")
+		return 0, errors.New("недостаточно монет")
 	}
 	user.Coins = newCoins
 	r.users[id] = user
 	r.usersByName[user.Username] = user
-	return nil
+	return newCoins, nil
+}
+
+func (r *inMemRepository) WithdrawUserCoins(ctx context.Context, id, amount int) (int, []models.CoinLot, error) {
+	coins, err := r.UpdateUserCoins(ctx, id, -amount)
+	return coins, nil, err
 }
 
-func (r *inMemRepository) AddTransaction(ctx context.Context, fromUserID, toUserID, amount int) error {
+func (r *inMemRepository) DepositUserCoins(ctx context.Context, id, amount int, lots []models.CoinLot) (int, error) {
+	return r.UpdateUserCoins(ctx, id, amount)
+}
+
+func (r *inMemRepository) AddTransaction(ctx context.Context, t models.Transaction) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	trans := models.Transaction{
-		ID:         r.nextTransID,
-		FromUserID: fromUserID,
-		ToUserID:   toUserID,
-		Amount:     amount,
-		CreatedAt:  time.Now(),
-	}
+	t.ID = r.nextTransID
+	t.CreatedAt = time.Now()
 	r.nextTransID++
-	r.transactions = append(r.transactions, trans)
-	return nil
+	r.transactions = append(r.transactions, t)
+	return t.ID, nil
 }
 
-func (r *inMemRepository) GetUserTransactions(ctx context.Context, userID int) ([]models.Transaction, []models.Transaction, error) {
+func (r *inMemRepository) GetUserTransactions(ctx context.Context, userID int, category string) ([]models.Transaction, []models.Transaction, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	var received, sent []models.Transaction
 	for _, t := range r.transactions {
+		if category != "" && t.Category != category {
+			continue
+		}
 		if t.ToUserID == userID {
 			received = append(received, t)
 		}
@@ -127,6 +151,22 @@ func (r *inMemRepository) GetUserTransactions(ctx context.Context, userID int) (
 	return received, sent, nil
 }
 
+func (r *inMemRepository) GetTransferLimitOverride(ctx context.Context, userID int, role string) (models.TransferLimitOverride, error) {
+	return models.TransferLimitOverride{}, nil
+}
+
+func (r *inMemRepository) ListCoinLots(ctx context.Context, userID int) ([]models.CoinLot, error) {
+	return nil, nil
+}
+
+func (r *inMemRepository) ListItemVariants(ctx context.Context, item string) ([]models.ItemVariant, error) {
+	return nil, nil
+}
+
+func (r *inMemRepository) ListPriceSchedules(ctx context.Context, item string, from time.Time) ([]models.PriceSchedule, error) {
+	return nil, nil
+}
+
 func (r *inMemRepository) GetUserPurchases(ctx context.Context, userID int) ([]models.Purchase, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -139,24 +179,48 @@ func (r *inMemRepository) GetUserPurchases(ctx context.Context, userID int) ([]m
 	return result, nil
 }
 
-func (r *inMemRepository) AddPurchase(ctx context.Context, userID int, item string) error {
+func (r *inMemRepository) AddPurchase(ctx context.Context, purchase models.Purchase) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	for i, p := range r.purchases {
-		if p.UserID == userID && p.Item == item {
+		if p.UserID == purchase.UserID && p.Item == purchase.Item && p.VariantID == purchase.VariantID {
 			r.purchases[i].Quantity++
-			return nil
+			return p.ID, nil
 		}
 	}
-	purchase := models.Purchase{
-		ID:        r.nextPurchaseID,
-		UserID:    userID,
-		Item:      item,
-		Quantity:  1,
-		CreatedAt: time.Now(),
-	}
+	purchase.ID = r.nextPurchaseID
+	purchase.Quantity = 1
+	purchase.CreatedAt = time.Now()
 	r.nextPurchaseID++
 	r.purchases = append(r.purchases, purchase)
+	return purchase.ID, nil
+}
+
+func (r *inMemRepository) WithTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
+
+func (r *inMemRepository) LockUsers(ctx context.Context, ids ...int) error {
+	return nil
+}
+
+func (r *inMemRepository) AddOutboxEvent(ctx context.Context, e models.OutboxEvent) error {
+	return nil
+}
+
+func (r *inMemRepository) AddAuditEntry(ctx context.Context, e models.AuditEntry) error {
+	return nil
+}
+
+func (r *inMemRepository) NotifyUser(ctx context.Context, payload []byte) error {
+	if r.hub == nil {
+		return nil
+	}
+	var n models.UserNotification
+	if err := json.Unmarshal(payload, &n); err != nil {
+		return err
+	}
+	r.hub.Dispatch(n)
 	return nil
 }
 
@@ -480,3 +544,131 @@ func TestE2E_SendCoin(t *testing.T) {
 		})
 	}
 }
+
+func setupTestGRPCConn(t *testing.T) *grpc.ClientConn {
+	repo := newInMemRepository()
+	svc := service.NewService(repo, "secret")
+	srv := grpcserver.NewServer(svc, "secret", "internal-key")
+
+	lis := bufconn.Listen(1024 * 1024)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+func TestE2E_GRPC(t *testing.T) {
+	conn := setupTestGRPCConn(t)
+	ctx := context.Background()
+	auth := pb.NewAuthServiceClient(conn)
+	wallet := pb.NewWalletServiceClient(conn)
+	shop := pb.NewShopServiceClient(conn)
+
+	authResp, err := auth.Authenticate(ctx, &pb.AuthenticateRequest{Username: "grpc_sender", Password: "pass"})
+	require.NoError(t, err)
+	_, err = auth.Authenticate(ctx, &pb.AuthenticateRequest{Username: "grpc_receiver", Password: "pass"})
+	require.NoError(t, err)
+
+	_, err = wallet.GetInfo(ctx, &pb.GetInfoRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	userCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+authResp.GetToken())
+	_, err = wallet.SendCoin(userCtx, &pb.SendCoinRequest{ToUser: "grpc_receiver", Amount: 100})
+	require.NoError(t, err)
+	_, err = shop.BuyItem(userCtx, &pb.BuyItemRequest{Item: "cup"})
+	require.NoError(t, err)
+
+	info, err := wallet.GetInfo(userCtx, &pb.GetInfoRequest{})
+	require.NoError(t, err)
+	require.Equal(t, int64(880), info.GetCoins())
+	require.Len(t, info.GetInventory(), 1)
+	require.Len(t, info.GetCoinHistory().GetSent(), 1)
+
+	_, err = wallet.CreditCoins(userCtx, &pb.CreditCoinsRequest{Username: "grpc_receiver", Amount: 50})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	serviceCtx := metadata.AppendToOutgoingContext(ctx, "x-api-key", "internal-key")
+	credit, err := wallet.CreditCoins(serviceCtx, &pb.CreditCoinsRequest{Username: "grpc_receiver", Amount: 50})
+	require.NoError(t, err)
+	require.Equal(t, int64(1150), credit.GetCoins())
+
+	balance, err := wallet.GetBalance(serviceCtx, &pb.GetBalanceRequest{Username: "grpc_receiver"})
+	require.NoError(t, err)
+	require.Equal(t, int64(1150), balance.GetCoins())
+
+	_, err = wallet.GetBalance(serviceCtx, &pb.GetBalanceRequest{Username: "unknown"})
+	require.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestE2E_Events(t *testing.T) {
+	repo := newInMemRepository()
+	repo.hub = notifications.NewHub()
+	svc := service.NewService(repo, "secret", service.WithSubscriber(repo.hub))
+	h := handlers.NewHandler(svc, "secret")
+
+	r := mux.NewRouter()
+	r.HandleFunc("/api/auth", h.AuthHandler).Methods("POST")
+	r.HandleFunc("/api/sendCoin", h.JWTMiddleware(h.SendCoinHandler)).Methods("POST")
+	r.HandleFunc("/api/events", h.JWTMiddleware(h.EventsHandler)).Methods("GET")
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	client := ts.Client()
+
+	auth := func(username string) string {
+		data, err := json.Marshal(map[string]string{"username": username, "password": "pass"})
+		require.NoError(t, err)
+		resp, err := client.Post(ts.URL+"/api/auth", "application/json", bytes.NewReader(data))
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		var authResp map[string]string
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&authResp))
+		return authResp["token"]
+	}
+	senderToken := auth("sse_sender")
+	receiverToken := auth("sse_receiver")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", ts.URL+"/api/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+receiverToken)
+	stream, err := client.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = stream.Body.Close() }()
+	require.Equal(t, http.StatusOK, stream.StatusCode)
+	require.Equal(t, "text/event-stream", stream.Header.Get("Content-Type"))
+
+	data, err := json.Marshal(map[string]interface{}{"toUser": "sse_receiver", "amount": 70})
+	require.NoError(t, err)
+	req, err = http.NewRequest("POST", ts.URL+"/api/sendCoin", bytes.NewReader(data))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer "+senderToken)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	events := make(map[string]string)
+	scanner := bufio.NewScanner(stream.Body)
+	var eventType string
+	for len(events) < 2 && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			events[eventType] = strings.TrimPrefix(line, "data: ")
+		}
+	}
+	require.JSONEq(t, `{"coins":1070,"delta":70}`, events[models.NotificationBalanceChanged])
+	require.JSONEq(t, `{"fromUser":"sse_sender","amount":70}`, events[models.NotificationCoinReceived])
+}
@@ -3,13 +3,19 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"time"
 
 	"AVTproject/config"
+	"AVTproject/grpcserver"
 	"AVTproject/handlers"
+	"AVTproject/jobs"
+	"AVTproject/notifications"
+	"AVTproject/outbox"
 	"AVTproject/repository"
 	"AVTproject/service"
+	"AVTproject/webhooks"
 
 	"github.com/gorilla/mux"
 	_ "github.com/lib/pq"
@@ -23,17 +29,101 @@ func main() {
 	db := config.InitDB(ctx, cfg)
 	defer func() { _ = db.Close() }()
 
-	repoImpl := repository.NewPostgresRepository(db)
+	repoImpl := repository.NewPostgresRepository(db, repository.WithCoinLotTTL(cfg.CoinExpiry))
 
-	svc := service.NewService(repoImpl, cfg.JWTSecret)
+	hub := notifications.NewHub()
+	go func() {
+		if err := hub.Listen(ctx, cfg.PostgresConnStr(), repository.NotificationChannel); err != nil {
+			log.Printf("Ошибка получения уведомлений: %v", err)
+		}
+	}()
+
+	svc := service.NewService(
+		repoImpl,
+		cfg.JWTSecret,
+		service.WithAdmins(cfg.AdminUsers...),
+		service.WithManagers(cfg.ManagerUsers...),
+		service.WithSubscriber(hub),
+		service.WithTransferLimits(service.TransferLimits{
+			PerTransfer:      cfg.TransferMaxAmount,
+			PerDay:           cfg.TransferDailyLimit,
+			PerMonth:         cfg.TransferMonthlyLimit,
+			RecipientsPerDay: cfg.TransferDailyRecipients,
+		}),
+		service.WithTransferApproval(cfg.TransferApprovalThreshold, cfg.TransferApprovalTTL),
+		service.WithTransferAcceptance(cfg.TransferAcceptanceTTL),
+		service.WithUndoWindow(cfg.TransferUndoWindow),
+		service.WithCoinRequestTTL(cfg.CoinRequestTTL),
+		service.WithReturnWindow(cfg.ReturnWindow),
+		service.WithScheduleRetry(cfg.ScheduledTransferMaxAttempts, cfg.ScheduledTransferRetryBackoff),
+	)
 
 	h := handlers.NewHandler(svc, cfg.JWTSecret)
 
 	r := mux.NewRouter()
+	r.Use(handlers.RequestMetaMiddleware)
 	r.HandleFunc("/api/auth", h.AuthHandler).Methods("POST")
 	r.HandleFunc("/api/info", h.JWTMiddleware(h.InfoHandler)).Methods("GET")
 	r.HandleFunc("/api/sendCoin", h.JWTMiddleware(h.SendCoinHandler)).Methods("POST")
+	r.HandleFunc("/api/sendCoin/batch", h.JWTMiddleware(h.SendCoinBatchHandler)).Methods("POST")
+	r.HandleFunc("/api/history", h.JWTMiddleware(h.HistoryHandler)).Methods("GET")
+	r.HandleFunc("/api/transfers/pending", h.JWTMiddleware(h.OwnPendingTransfersHandler)).Methods("GET")
+	r.HandleFunc("/api/transfers/incoming", h.JWTMiddleware(h.IncomingTransfersHandler)).Methods("GET")
+	r.HandleFunc("/api/transfers/scheduled", h.JWTMiddleware(h.CreateScheduledTransferHandler)).Methods("POST")
+	r.HandleFunc("/api/transfers/scheduled", h.JWTMiddleware(h.ListScheduledTransfersHandler)).Methods("GET")
+	r.HandleFunc("/api/transfers/scheduled/{id}", h.JWTMiddleware(h.UpdateScheduledTransferHandler)).Methods("PUT")
+	r.HandleFunc("/api/transfers/scheduled/{id}", h.JWTMiddleware(h.CancelScheduledTransferHandler)).Methods("DELETE")
+	r.HandleFunc("/api/transfers/{id}/accept", h.JWTMiddleware(h.AcceptTransferHandler)).Methods("POST")
+	r.HandleFunc("/api/transfers/{id}/decline", h.JWTMiddleware(h.DeclineTransferHandler)).Methods("POST")
+	r.HandleFunc("/api/transactions/{id}/undo", h.JWTMiddleware(h.UndoTransferHandler)).Methods("POST")
+	r.HandleFunc("/api/requests", h.JWTMiddleware(h.CreateCoinRequestHandler)).Methods("POST")
+	r.HandleFunc("/api/requests/incoming", h.JWTMiddleware(h.IncomingCoinRequestsHandler)).Methods("GET")
+	r.HandleFunc("/api/requests/outgoing", h.JWTMiddleware(h.OutgoingCoinRequestsHandler)).Methods("GET")
+	r.HandleFunc("/api/requests/{id}/pay", h.JWTMiddleware(h.PayCoinRequestHandler)).Methods("POST")
+	r.HandleFunc("/api/requests/{id}/decline", h.JWTMiddleware(h.DeclineCoinRequestHandler)).Methods("POST")
+	r.HandleFunc("/api/requests/{id}/cancel", h.JWTMiddleware(h.CancelCoinRequestHandler)).Methods("POST")
+	r.HandleFunc("/api/splits", h.JWTMiddleware(h.CreateBillSplitHandler)).Methods("POST")
+	r.HandleFunc("/api/splits", h.JWTMiddleware(h.ListBillSplitsHandler)).Methods("GET")
+	r.HandleFunc("/api/splits/{id}", h.JWTMiddleware(h.BillSplitHandler)).Methods("GET")
+	r.HandleFunc("/api/approvals", h.JWTMiddleware(h.ApproverMiddleware(h.PendingApprovalsHandler))).Methods("GET")
+	r.HandleFunc("/api/approvals/{id}/approve", h.JWTMiddleware(h.ApproverMiddleware(h.ApproveTransferHandler))).Methods("POST")
+	r.HandleFunc("/api/approvals/{id}/reject", h.JWTMiddleware(h.ApproverMiddleware(h.RejectTransferHandler))).Methods("POST")
+	r.HandleFunc("/api/feed", h.JWTMiddleware(h.FeedHandler)).Methods("GET")
+	r.HandleFunc("/api/feed/{id}/reactions", h.JWTMiddleware(h.AddReactionHandler)).Methods("POST")
+	r.HandleFunc("/api/feed/{id}/reactions/{reaction}", h.JWTMiddleware(h.RemoveReactionHandler)).Methods("DELETE")
+	r.HandleFunc("/api/items", h.JWTMiddleware(h.ListItemsHandler)).Methods("GET")
 	r.HandleFunc("/api/buy/{item}", h.JWTMiddleware(h.BuyHandler)).Methods("GET")
+	r.HandleFunc("/api/purchases", h.JWTMiddleware(h.ListPurchasesHandler)).Methods("GET")
+	r.HandleFunc("/api/purchases/{id}/return", h.JWTMiddleware(h.RequestReturnHandler)).Methods("POST")
+	r.HandleFunc("/api/returns", h.JWTMiddleware(h.OwnReturnsHandler)).Methods("GET")
+	r.HandleFunc("/api/events", h.JWTMiddleware(h.EventsHandler)).Methods("GET")
+
+	r.HandleFunc("/api/admin/adjustments", h.JWTMiddleware(h.AdminMiddleware(h.AdjustBalanceHandler))).Methods("POST")
+	r.HandleFunc("/api/admin/audit", h.JWTMiddleware(h.AdminMiddleware(h.AuditLogHandler))).Methods("GET")
+	r.HandleFunc("/api/admin/limits/roles/{role}", h.JWTMiddleware(h.AdminMiddleware(h.SetRoleTransferLimitsHandler))).Methods("PUT")
+	r.HandleFunc("/api/admin/limits/users/{username}", h.JWTMiddleware(h.AdminMiddleware(h.SetUserTransferLimitsHandler))).Methods("PUT")
+	r.HandleFunc("/api/admin/limits/users/{username}", h.JWTMiddleware(h.AdminMiddleware(h.UserTransferLimitsHandler))).Methods("GET")
+	r.HandleFunc("/api/admin/users/{username}/profile", h.JWTMiddleware(h.AdminMiddleware(h.SetUserProfileHandler))).Methods("PUT")
+	r.HandleFunc("/api/admin/users/{username}/credit-limit", h.JWTMiddleware(h.AdminMiddleware(h.SetCreditLimitHandler))).Methods("PUT")
+	r.HandleFunc("/api/admin/items/{item}/variants", h.JWTMiddleware(h.AdminMiddleware(h.SetItemVariantHandler))).Methods("PUT")
+	r.HandleFunc("/api/admin/sales", h.JWTMiddleware(h.AdminMiddleware(h.CreatePriceScheduleHandler))).Methods("POST")
+	r.HandleFunc("/api/admin/sales", h.JWTMiddleware(h.AdminMiddleware(h.ListPriceSchedulesHandler))).Methods("GET")
+	r.HandleFunc("/api/admin/sales/{id}", h.JWTMiddleware(h.AdminMiddleware(h.DeletePriceScheduleHandler))).Methods("DELETE")
+	r.HandleFunc("/api/admin/returns", h.JWTMiddleware(h.AdminMiddleware(h.ListReturnsHandler))).Methods("GET")
+	r.HandleFunc("/api/admin/returns/{id}/approve", h.JWTMiddleware(h.AdminMiddleware(h.ApproveReturnHandler))).Methods("POST")
+	r.HandleFunc("/api/admin/returns/{id}/reject", h.JWTMiddleware(h.AdminMiddleware(h.RejectReturnHandler))).Methods("POST")
+	r.HandleFunc("/api/admin/promo-codes", h.JWTMiddleware(h.AdminMiddleware(h.CreatePromoCodeHandler))).Methods("POST")
+	r.HandleFunc("/api/admin/promo-codes", h.JWTMiddleware(h.AdminMiddleware(h.ListPromoCodesHandler))).Methods("GET")
+	r.HandleFunc("/api/admin/promo-codes/{id}", h.JWTMiddleware(h.AdminMiddleware(h.DeactivatePromoCodeHandler))).Methods("DELETE")
+	r.HandleFunc("/api/admin/grants", h.JWTMiddleware(h.AdminMiddleware(h.CreateGrantPolicyHandler))).Methods("POST")
+	r.HandleFunc("/api/admin/grants", h.JWTMiddleware(h.AdminMiddleware(h.ListGrantPoliciesHandler))).Methods("GET")
+	r.HandleFunc("/api/admin/grants/{id}", h.JWTMiddleware(h.AdminMiddleware(h.DeactivateGrantPolicyHandler))).Methods("DELETE")
+	r.HandleFunc("/api/admin/grants/{id}/preview", h.JWTMiddleware(h.AdminMiddleware(h.PreviewGrantHandler))).Methods("GET")
+	r.HandleFunc("/api/admin/grants/{id}/run", h.JWTMiddleware(h.AdminMiddleware(h.RunGrantHandler))).Methods("POST")
+	r.HandleFunc("/api/admin/webhooks", h.JWTMiddleware(h.AdminMiddleware(h.CreateWebhookHandler))).Methods("POST")
+	r.HandleFunc("/api/admin/webhooks", h.JWTMiddleware(h.AdminMiddleware(h.ListWebhooksHandler))).Methods("GET")
+	r.HandleFunc("/api/admin/webhooks/{id}", h.JWTMiddleware(h.AdminMiddleware(h.DeleteWebhookHandler))).Methods("DELETE")
+	r.HandleFunc("/api/admin/webhooks/{id}/deliveries", h.JWTMiddleware(h.AdminMiddleware(h.ListWebhookDeliveriesHandler))).Methods("GET")
 
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if _, err := w.Write([]byte("Добро пожаловать в Avito Shop API")); err != nil {
@@ -41,6 +131,44 @@ func main() {
 		}
 	}).Methods("GET")
 
+	// Без ключа внутренние методы gRPC не защищены ничем, кроме сети, поэтому
+	// сервер не поднимается, пока GRPC_API_KEY не задан явно.
+	if cfg.GRPCAPIKey == "" {
+		log.Printf("GRPC_API_KEY не задан, gRPC-сервер отключён")
+	} else {
+		grpcSrv := grpcserver.NewServer(svc, cfg.JWTSecret, cfg.GRPCAPIKey)
+		lis, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+		if err != nil {
+			_ = db.Close()
+			log.Fatalf("Ошибка запуска gRPC-сервера: %v", err)
+		}
+		go func() {
+			log.Printf("gRPC-сервер запущен на порту %s", cfg.GRPCPort)
+			if err := grpcSrv.Serve(lis); err != nil {
+				log.Printf("Ошибка gRPC-сервера: %v", err)
+			}
+		}()
+		defer grpcSrv.GracefulStop()
+	}
+
+	dispatcher := webhooks.NewDispatcher(repoImpl, webhooks.Config{
+		BatchSize:   50,
+		MaxAttempts: cfg.WebhookMaxAttempts,
+		BaseBackoff: 10 * time.Second,
+		MaxBackoff:  time.Hour,
+		Timeout:     10 * time.Second,
+	})
+	go jobs.Every(ctx, cfg.WebhookPollInterval, "webhooks", dispatcher.DeliverDue)
+
+	go jobs.Every(ctx, cfg.TransferExpiryInterval, "pending-transfers", svc.ExpirePendingTransfers)
+	go jobs.Every(ctx, cfg.TransferExpiryInterval, "coin-requests", svc.ExpireCoinRequests)
+	go jobs.Every(ctx, cfg.ScheduledTransferInterval, "scheduled-transfers", svc.RunScheduledTransfers)
+	go jobs.Every(ctx, cfg.GrantInterval, "grants", svc.RunDueGrants)
+	go jobs.Every(ctx, cfg.CoinExpiryInterval, "coin-expiry", svc.ExpireCoins)
+
+	relay := outbox.NewRelay(repoImpl, outboxPublisher(cfg, repoImpl), 100)
+	go jobs.Every(ctx, cfg.OutboxPollInterval, "outbox", relay.Drain)
+
 	srv := http.Server{
 		Handler:      r,
 		Addr:         ":" + cfg.ServerPort,
@@ -53,3 +181,19 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+func outboxPublisher(cfg config.Config, repo repository.PostgresRepository) outbox.Publisher {
+	publishers := outbox.MultiPublisher{webhooks.NewPublisher(repo)}
+	switch cfg.OutboxPublisher {
+	case "log":
+		publishers = append(publishers, outbox.LogPublisher{})
+	case "file":
+		publishers = append(publishers, outbox.NewFilePublisher(cfg.OutboxFilePath))
+	case "http":
+		publishers = append(publishers, outbox.NewHTTPPublisher(cfg.OutboxHTTPURL, 10*time.Second))
+	case "none":
+	default:
+		log.Printf("Неизвестный OUTBOX_PUBLISHER %q, события публикуются только в вебхуки", cfg.OutboxPublisher)
+	}
+	return publishers
+}
@@ -5,6 +5,9 @@ import (
 	"database/sql"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/lib/pq"
 )
@@ -16,7 +19,41 @@ type Config struct {
 	DatabasePassword string
 	DatabaseName     string
 	ServerPort       string
+	GRPCPort         string
+	GRPCAPIKey       string
 	JWTSecret        string
+	AdminUsers       []string
+	ManagerUsers     []string
+
+	WebhookPollInterval time.Duration
+	WebhookMaxAttempts  int
+
+	OutboxPublisher    string
+	OutboxFilePath     string
+	OutboxHTTPURL      string
+	OutboxPollInterval time.Duration
+
+	TransferMaxAmount       int
+	TransferDailyLimit      int
+	TransferMonthlyLimit    int
+	TransferDailyRecipients int
+
+	TransferApprovalThreshold int
+	TransferApprovalTTL       time.Duration
+	TransferAcceptanceTTL     time.Duration
+	TransferExpiryInterval    time.Duration
+	TransferUndoWindow        time.Duration
+	CoinRequestTTL            time.Duration
+	ReturnWindow              time.Duration
+
+	ScheduledTransferInterval     time.Duration
+	ScheduledTransferMaxAttempts  int
+	ScheduledTransferRetryBackoff time.Duration
+
+	GrantInterval time.Duration
+
+	CoinExpiry         time.Duration
+	CoinExpiryInterval time.Duration
 }
 
 func LoadConfig() Config {
@@ -27,7 +64,41 @@ func LoadConfig() Config {
 		DatabasePassword: getEnv("DATABASE_PASSWORD", "password"),
 		DatabaseName:     getEnv("DATABASE_NAME", "shop"),
 		ServerPort:       getEnv("SERVER_PORT", "8080"),
+		GRPCPort:         getEnv("GRPC_PORT", "9090"),
+		GRPCAPIKey:       getEnv("GRPC_API_KEY", ""),
 		JWTSecret:        getEnv("JWT_SECRET", "secret"),
+		AdminUsers:       getEnvList("ADMIN_USERS"),
+		ManagerUsers:     getEnvList("MANAGER_USERS"),
+
+		WebhookPollInterval: getEnvDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second),
+		WebhookMaxAttempts:  getEnvInt("WEBHOOK_MAX_ATTEMPTS", 8),
+
+		OutboxPublisher:    getEnv("OUTBOX_PUBLISHER", "log"),
+		OutboxFilePath:     getEnv("OUTBOX_FILE_PATH", "events.jsonl"),
+		OutboxHTTPURL:      getEnv("OUTBOX_HTTP_URL", ""),
+		OutboxPollInterval: getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+
+		TransferMaxAmount:       getEnvInt("TRANSFER_MAX_AMOUNT", 0),
+		TransferDailyLimit:      getEnvInt("TRANSFER_DAILY_LIMIT", 0),
+		TransferMonthlyLimit:    getEnvInt("TRANSFER_MONTHLY_LIMIT", 0),
+		TransferDailyRecipients: getEnvInt("TRANSFER_DAILY_RECIPIENTS", 0),
+
+		TransferApprovalThreshold: getEnvInt("TRANSFER_APPROVAL_THRESHOLD", 0),
+		TransferApprovalTTL:       getEnvDuration("TRANSFER_APPROVAL_TTL", 72*time.Hour),
+		TransferAcceptanceTTL:     getEnvDuration("TRANSFER_ACCEPTANCE_TTL", 72*time.Hour),
+		TransferExpiryInterval:    getEnvDuration("TRANSFER_EXPIRY_INTERVAL", time.Minute),
+		TransferUndoWindow:        getEnvDuration("TRANSFER_UNDO_WINDOW", time.Minute),
+		CoinRequestTTL:            getEnvDuration("COIN_REQUEST_TTL", 7*24*time.Hour),
+		ReturnWindow:              getEnvDuration("RETURN_WINDOW", 14*24*time.Hour),
+
+		ScheduledTransferInterval:     getEnvDuration("SCHEDULED_TRANSFER_INTERVAL", time.Minute),
+		ScheduledTransferMaxAttempts:  getEnvInt("SCHEDULED_TRANSFER_MAX_ATTEMPTS", 3),
+		ScheduledTransferRetryBackoff: getEnvDuration("SCHEDULED_TRANSFER_RETRY_BACKOFF", 5*time.Minute),
+
+		GrantInterval: getEnvDuration("GRANT_INTERVAL", time.Hour),
+
+		CoinExpiry:         getEnvDuration("COIN_EXPIRY", 365*24*time.Hour),
+		CoinExpiryInterval: getEnvDuration("COIN_EXPIRY_INTERVAL", time.Hour),
 	}
 }
 
@@ -54,6 +125,32 @@ func getEnv(key, fallback string) string {
 	return val
 }
 
+func getEnvInt(key string, fallback int) int {
+	val, err := strconv.Atoi(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return val
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	val, err := time.ParseDuration(os.Getenv(key))
+	if err != nil {
+		return fallback
+	}
+	return val
+}
+
+func getEnvList(key string) []string {
+	var result []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
 func InitDB(ctx context.Context, cfg Config) *sql.DB {
 	db, err := sql.Open("postgres", cfg.PostgresConnStr())
 	if err != nil {
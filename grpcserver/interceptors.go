@@ -0,0 +1,136 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net"
+	"strings"
+
+	"AVTproject/service"
+
+	"github.com/golang-jwt/jwt/v4"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+type contextKey string
+
+const userIDKey contextKey = "user_id"
+
+type accessLevel int
+
+const (
+	accessPublic accessLevel = iota
+	accessUser
+	accessService
+)
+
+var methodAccess = map[string]accessLevel{
+	"/avtshop.v1.AuthService/Authenticate":  accessPublic,
+	"/avtshop.v1.WalletService/GetInfo":     accessUser,
+	"/avtshop.v1.WalletService/SendCoin":    accessUser,
+	"/avtshop.v1.WalletService/GetBalance":  accessService,
+	"/avtshop.v1.WalletService/CreditCoins": accessService,
+	"/avtshop.v1.ShopService/ListItems":     accessPublic,
+	"/avtshop.v1.ShopService/BuyItem":       accessUser,
+}
+
+func RequestMetaInterceptor(
+	ctx context.Context,
+	req interface{},
+	_ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	meta := service.RequestMeta{RequestID: firstMetadataValue(ctx, "x-request-id")}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		meta.IP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(meta.IP); err == nil {
+			meta.IP = host
+		}
+	}
+	return handler(service.WithRequestMeta(ctx, meta), req)
+}
+
+func AuthInterceptor(jwtSecret, apiKey string) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context,
+		req interface{},
+		info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		level, ok := methodAccess[info.FullMethod]
+		if !ok {
+			return nil, status.Error(codes.PermissionDenied, "метод недоступен")
+		}
+		switch level {
+		case accessUser:
+			userID, err := userFromMetadata(ctx, jwtSecret)
+			if err != nil {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+			ctx = context.WithValue(ctx, userIDKey, userID)
+		case accessService:
+			if err := checkAPIKey(ctx, apiKey); err != nil {
+				return nil, status.Error(codes.Unauthenticated, err.Error())
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+func userFromMetadata(ctx context.Context, jwtSecret string) (int, error) {
+	authHeader := firstMetadataValue(ctx, "authorization")
+	if authHeader == "" {
+		return 0, errors.New("отсутствует токен авторизации")
+	}
+	const bearerPrefix = "Bearer "
+	if !strings.HasPrefix(authHeader, bearerPrefix) || len(authHeader) == len(bearerPrefix) {
+		return 0, errors.New("неверный формат токена")
+	}
+
+	token, err := jwt.Parse(authHeader[len(bearerPrefix):], func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New("неожиданный алгоритм подписи")
+		}
+		return []byte(jwtSecret), nil
+	})
+	if err != nil || !token.Valid {
+		return 0, errors.New("неверный токен")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return 0, errors.New("неверные данные токена")
+	}
+	uid, ok := claims["user_id"].(float64)
+	if !ok {
+		return 0, errors.New("неверный идентификатор пользователя в токене")
+	}
+	return int(uid), nil
+}
+
+func checkAPIKey(ctx context.Context, apiKey string) error {
+	if apiKey == "" {
+		return errors.New("доступ для сервисов не настроен")
+	}
+	key := firstMetadataValue(ctx, "x-api-key")
+	if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+		return errors.New("неверный ключ сервиса")
+	}
+	return nil
+}
+
+func firstMetadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	values := md.Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
@@ -0,0 +1,237 @@
+package grpcserver
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	pb "AVTproject/pb/avtshop/v1"
+	"AVTproject/service"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func NewServer(svc service.Service, jwtSecret, apiKey string) *grpc.Server {
+	srv := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		RequestMetaInterceptor,
+		AuthInterceptor(jwtSecret, apiKey),
+	))
+	pb.RegisterAuthServiceServer(srv, authServer{svc: svc})
+	pb.RegisterWalletServiceServer(srv, walletServer{svc: svc})
+	pb.RegisterShopServiceServer(srv, shopServer{svc: svc})
+	return srv
+}
+
+type authServer struct {
+	pb.UnimplementedAuthServiceServer
+	svc service.Service
+}
+
+func (s authServer) Authenticate(
+	ctx context.Context,
+	req *pb.AuthenticateRequest,
+) (*pb.AuthenticateResponse, error) {
+	if req.GetUsername() == "" || req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Неверный запрос")
+	}
+	token, err := s.svc.Authenticate(ctx, req.GetUsername(), req.GetPassword())
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return &pb.AuthenticateResponse{Token: token}, nil
+}
+
+type walletServer struct {
+	pb.UnimplementedWalletServiceServer
+	svc service.Service
+}
+
+func (s walletServer) GetInfo(
+	ctx context.Context,
+	_ *pb.GetInfoRequest,
+) (*pb.GetInfoResponse, error) {
+	userID, ok := ctx.Value(userIDKey).(int)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Пользователь не найден в контексте")
+	}
+	info, err := s.svc.GetInfo(ctx, userID)
+	if err != nil {
+		return nil, toStatus(err, codes.Internal, "пользователь не найден")
+	}
+
+	resp := &pb.GetInfoResponse{
+		Coins:       int64(info.Coins),
+		BonusCoins:  int64(info.BonusCoins),
+		CreditLimit: int64(info.CreditLimit),
+		Debt:        int64(info.Debt),
+		CoinHistory: &pb.CoinHistory{},
+		TransferAllowance: &pb.TransferAllowance{
+			PerTransfer:         optionalInt64(info.TransferAllowance.PerTransfer),
+			RemainingToday:      optionalInt64(info.TransferAllowance.RemainingToday),
+			RemainingThisMonth:  optionalInt64(info.TransferAllowance.RemainingThisMonth),
+			RecipientsLeftToday: optionalInt64(info.TransferAllowance.RecipientsLeftToday),
+		},
+	}
+	for _, item := range info.Inventory {
+		resp.Inventory = append(resp.Inventory, &pb.InventoryItem{
+			Type:     item.Type,
+			Quantity: int64(item.Quantity),
+			Size:     item.Size,
+			Color:    item.Color,
+		})
+	}
+	for _, e := range info.ExpiringCoins {
+		resp.ExpiringCoins = append(resp.ExpiringCoins, &pb.CoinExpiration{
+			Amount:    int64(e.Amount),
+			ExpiresAt: timestamppb.New(e.ExpiresAt),
+		})
+	}
+	resp.CoinHistory.Received = toTransactionInfos(info.CoinHistory.Received)
+	resp.CoinHistory.Sent = toTransactionInfos(info.CoinHistory.Sent)
+	return resp, nil
+}
+
+func (s walletServer) SendCoin(
+	ctx context.Context,
+	req *pb.SendCoinRequest,
+) (*pb.SendCoinResponse, error) {
+	userID, ok := ctx.Value(userIDKey).(int)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Пользователь не найден в контексте")
+	}
+	if req.GetToUser() == "" || req.GetAmount() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Неверные параметры запроса")
+	}
+	opts := service.TransferOptions{
+		Memo:     req.GetMemo(),
+		Category: req.GetCategory(),
+		Public:   req.GetPublic(),
+
+		RequiresAcceptance: req.GetRequiresAcceptance(),
+	}
+	result, err := s.svc.SendCoin(ctx, userID, req.GetToUser(), int(req.GetAmount()), opts)
+	if err != nil {
+		return nil, toStatus(err, codes.FailedPrecondition, "получатель не найден")
+	}
+	return &pb.SendCoinResponse{
+		Status:            result.Status,
+		TransactionId:     int64(result.TransactionID),
+		PendingTransferId: int64(result.PendingTransferID),
+	}, nil
+}
+
+func (s walletServer) GetBalance(
+	ctx context.Context,
+	req *pb.GetBalanceRequest,
+) (*pb.GetBalanceResponse, error) {
+	if req.GetUsername() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Неверные параметры запроса")
+	}
+	coins, err := s.svc.GetBalance(ctx, req.GetUsername())
+	if err != nil {
+		return nil, toStatus(err, codes.Internal, "пользователь не найден")
+	}
+	return &pb.GetBalanceResponse{Username: req.GetUsername(), Coins: int64(coins)}, nil
+}
+
+func (s walletServer) CreditCoins(
+	ctx context.Context,
+	req *pb.CreditCoinsRequest,
+) (*pb.CreditCoinsResponse, error) {
+	if req.GetUsername() == "" || req.GetAmount() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "Неверные параметры запроса")
+	}
+	coins, err := s.svc.CreditCoins(ctx, req.GetUsername(), int(req.GetAmount()))
+	if err != nil {
+		return nil, toStatus(err, codes.FailedPrecondition, "пользователь не найден")
+	}
+	return &pb.CreditCoinsResponse{Coins: int64(coins)}, nil
+}
+
+type shopServer struct {
+	pb.UnimplementedShopServiceServer
+	svc service.Service
+}
+
+func (s shopServer) ListItems(
+	ctx context.Context,
+	_ *pb.ListItemsRequest,
+) (*pb.ListItemsResponse, error) {
+	items, err := s.svc.ListItems(ctx)
+	if err != nil {
+		return nil, toStatus(err, codes.Internal, "товар не найден")
+	}
+	resp := &pb.ListItemsResponse{}
+	for _, item := range items {
+		pbItem := &pb.Item{Name: item.Name, Price: int64(item.Price), OriginalPrice: int64(item.OriginalPrice)}
+		for _, v := range item.Variants {
+			pbItem.Variants = append(pbItem.Variants, &pb.ItemVariant{
+				Size:          v.Size,
+				Color:         v.Color,
+				Price:         int64(v.Price),
+				OriginalPrice: int64(v.OriginalPrice),
+				Stock:         int64(v.Stock),
+			})
+		}
+		resp.Items = append(resp.Items, pbItem)
+	}
+	return resp, nil
+}
+
+func (s shopServer) BuyItem(
+	ctx context.Context,
+	req *pb.BuyItemRequest,
+) (*pb.BuyItemResponse, error) {
+	userID, ok := ctx.Value(userIDKey).(int)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "Пользователь не найден в контексте")
+	}
+	if req.GetItem() == "" {
+		return nil, status.Error(codes.InvalidArgument, "Название товара не указано")
+	}
+	if err := s.svc.BuyItem(ctx, userID, req.GetItem(), service.PurchaseOptions{
+		Size:        req.GetSize(),
+		Color:       req.GetColor(),
+		PromoCode:   req.GetPromoCode(),
+		GiftTo:      req.GetGiftTo(),
+		GiftMessage: req.GetGiftMessage(),
+	}); err != nil {
+		return nil, toStatus(err, codes.FailedPrecondition, "получатель подарка не найден")
+	}
+	return &pb.BuyItemResponse{}, nil
+}
+
+func toTransactionInfos(infos []service.TransactionInfo) []*pb.TransactionInfo {
+	result := make([]*pb.TransactionInfo, 0, len(infos))
+	for _, t := range infos {
+		result = append(result, &pb.TransactionInfo{
+			OtherUser: t.OtherUser,
+			Amount:    int64(t.Amount),
+			Type:      t.Type,
+			Id:        int64(t.ID),
+			Memo:      t.Memo,
+			Category:  t.Category,
+		})
+	}
+	return result
+}
+
+// toStatus переводит ошибку сервиса в gRPC-статус. sql.ErrNoRows не говорит,
+// что именно не найдено, поэтому сообщение передаёт вызывающий метод.
+func toStatus(err error, fallback codes.Code, notFound string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return status.Error(codes.NotFound, notFound)
+	}
+	return status.Error(fallback, err.Error())
+}
+
+func optionalInt64(v *int) *int64 {
+	if v == nil {
+		return nil
+	}
+	n := int64(*v)
+	return &n
+}
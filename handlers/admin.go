@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"AVTproject/models"
+	"AVTproject/service"
+
+	"github.com/gorilla/mux"
+)
+
+type AdjustBalanceRequest struct {
+	Username      string `json:"username"`
+	Amount        int    `json:"amount"`
+	Reason        string `json:"reason"`
+	Reference     string `json:"reference"`
+	Wallet        string `json:"wallet"`
+	AllowNegative bool   `json:"allowNegative"`
+}
+
+func (h Handler) AdjustBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req AdjustBalanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	resp, err := h.svc.AdjustBalance(r.Context(), userID, service.BalanceAdjustment{
+		Username:      req.Username,
+		Amount:        req.Amount,
+		Reason:        req.Reason,
+		Reference:     req.Reference,
+		Wallet:        req.Wallet,
+		AllowNegative: req.AllowNegative,
+	})
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (h Handler) AuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	q := service.AuditQuery{
+		Actor:  query.Get("actor"),
+		Action: query.Get("action"),
+		Target: query.Get("target"),
+	}
+	var err error
+	if v := query.Get("from"); v != "" {
+		if q.From, err = time.Parse(time.RFC3339, v); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Неверный формат параметра from")
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if q.To, err = time.Parse(time.RFC3339, v); err != nil {
+			respondWithError(w, http.StatusBadRequest, "Неверный формат параметра to")
+			return
+		}
+	}
+	q.Limit, _ = strconv.Atoi(query.Get("limit"))
+	q.Offset, _ = strconv.Atoi(query.Get("offset"))
+
+	entries, err := h.svc.ListAuditLog(r.Context(), q)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, entries)
+}
+
+type TransferLimitsRequest struct {
+	PerTransfer      *int `json:"perTransfer"`
+	PerDay           *int `json:"perDay"`
+	PerMonth         *int `json:"perMonth"`
+	RecipientsPerDay *int `json:"recipientsPerDay"`
+}
+
+func (req TransferLimitsRequest) override() models.TransferLimitOverride {
+	return models.TransferLimitOverride{
+		PerTransfer:      req.PerTransfer,
+		PerDay:           req.PerDay,
+		PerMonth:         req.PerMonth,
+		RecipientsPerDay: req.RecipientsPerDay,
+	}
+}
+
+func (h Handler) SetRoleTransferLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req TransferLimitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	if err := h.svc.SetRoleTransferLimits(r.Context(), userID, mux.Vars(r)["role"], req.override()); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h Handler) SetUserTransferLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req TransferLimitsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	if err := h.svc.SetUserTransferLimits(r.Context(), userID, mux.Vars(r)["username"], req.override()); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h Handler) UserTransferLimitsHandler(w http.ResponseWriter, r *http.Request) {
+	limits, err := h.svc.GetUserTransferLimits(r.Context(), mux.Vars(r)["username"])
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, limits)
+}
+
+type CreditLimitRequest struct {
+	Limit int `json:"limit"`
+}
+
+func (h Handler) SetCreditLimitHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req CreditLimitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	if err := h.svc.SetCreditLimit(r.Context(), userID, mux.Vars(r)["username"], req.Limit); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
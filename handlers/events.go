@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const sseHeartbeatInterval = 25 * time.Second
+
+func (h Handler) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	events, unsubscribe, err := h.svc.SubscribeEvents(userID)
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+	// Поток живёт дольше WriteTimeout сервера, поэтому дедлайн записи снимается.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Потоковая передача не поддерживается")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+		case n, ok := <-events:
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", n.Type, n.Data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
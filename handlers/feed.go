@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type ReactionRequest struct {
+	Reaction string `json:"reaction"`
+}
+
+func (h Handler) FeedHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	feed, err := h.svc.GetFeed(r.Context(), userID, limit, offset)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, feed)
+}
+
+func (h Handler) AddReactionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	var req ReactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	if err := h.svc.AddReaction(r.Context(), userID, id, req.Reaction); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h Handler) RemoveReactionHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	if err := h.svc.RemoveReaction(r.Context(), userID, id, mux.Vars(r)["reaction"]); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
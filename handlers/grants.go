@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"AVTproject/service"
+
+	"github.com/gorilla/mux"
+)
+
+type GrantPolicyRequest struct {
+	Name       string `json:"name"`
+	Amount     int    `json:"amount"`
+	Interval   string `json:"interval"`
+	Role       string `json:"role"`
+	Department string `json:"department"`
+}
+
+type UserProfileRequest struct {
+	Department string `json:"department"`
+	Active     *bool  `json:"active"`
+}
+
+func (h Handler) CreateGrantPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req GrantPolicyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	policy, err := h.svc.CreateGrantPolicy(r.Context(), userID, service.GrantPolicyRequest{
+		Name:       req.Name,
+		Amount:     req.Amount,
+		Interval:   req.Interval,
+		Role:       req.Role,
+		Department: req.Department,
+	})
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, policy)
+}
+
+func (h Handler) ListGrantPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	policies, err := h.svc.ListGrantPolicies(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, policies)
+}
+
+func (h Handler) DeactivateGrantPolicyHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	if err := h.svc.DeactivateGrantPolicy(r.Context(), userID, id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h Handler) PreviewGrantHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	preview, err := h.svc.PreviewGrant(r.Context(), id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, preview)
+}
+
+func (h Handler) RunGrantHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	run, err := h.svc.RunGrant(r.Context(), userID, id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, run)
+}
+
+func (h Handler) SetUserProfileHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req UserProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	active := req.Active == nil || *req.Active
+	if err := h.svc.SetUserProfile(r.Context(), userID, mux.Vars(r)["username"], req.Department, active); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -2,11 +2,17 @@ package handlers
 
 import (
 	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"log"
+	"net"
 	"net/http"
 	"strconv"
 
+	"AVTproject/models"
 	"AVTproject/service"
 
 	"github.com/golang-jwt/jwt/v4"
@@ -15,7 +21,9 @@ import (
 
 type contextKey string
 
-const userIDKey contextKey = "user_id"
+const (
+	userIDKey contextKey = "user_id"
+)
 
 type Handler struct {
 	svc       service.Service
@@ -39,8 +47,13 @@ type AuthResponse struct {
 }
 
 type SendCoinRequest struct {
-	ToUser string `json:"toUser"`
-	Amount int    `json:"amount"`
+	ToUser   string `json:"toUser"`
+	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+	Category string `json:"category,omitempty"`
+	Public   bool   `json:"public,omitempty"`
+
+	RequiresAcceptance bool `json:"requiresAcceptance,omitempty"`
 }
 
 type ErrorResponse struct {
@@ -90,13 +103,79 @@ func (h Handler) SendCoinHandler(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Неверные параметры запроса")
 		return
 	}
-	if err := h.svc.SendCoin(r.Context(), userID, req.ToUser, req.Amount); err != nil {
+	opts := service.TransferOptions{
+		Memo:               req.Memo,
+		Category:           req.Category,
+		Public:             req.Public,
+		RequiresAcceptance: req.RequiresAcceptance,
+	}
+	result, err := h.svc.SendCoin(r.Context(), userID, req.ToUser, req.Amount, opts)
+	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	if result.Status != service.TransferStatusCompleted {
+		respondWithJSON(w, http.StatusAccepted, result)
+		return
+	}
 	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
+type SendCoinBatchRequest struct {
+	Transfers []service.BatchTransfer `json:"transfers"`
+	Memo      string                  `json:"memo"`
+	Category  string                  `json:"category"`
+	Public    bool                    `json:"public"`
+}
+
+type BatchErrorResponse struct {
+	Errors  string                        `json:"errors"`
+	Results []service.BatchTransferResult `json:"results"`
+}
+
+func (h Handler) SendCoinBatchHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req SendCoinBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	opts := service.TransferOptions{
+		Memo:     req.Memo,
+		Category: req.Category,
+		Public:   req.Public,
+	}
+	results, err := h.svc.SendCoinBatch(r.Context(), userID, req.Transfers, opts)
+	var batchErr *service.BatchTransferError
+	if errors.As(err, &batchErr) {
+		respondWithJSON(w, http.StatusBadRequest, BatchErrorResponse{Errors: batchErr.Message, Results: batchErr.Results})
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"results": results})
+}
+
+func (h Handler) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	history, err := h.svc.GetHistory(r.Context(), userID, r.URL.Query().Get("category"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, history)
+}
+
 func (h Handler) BuyHandler(w http.ResponseWriter, r *http.Request) {
 	userID, ok := r.Context().Value(userIDKey).(int)
 	if !ok {
@@ -109,7 +188,14 @@ func (h Handler) BuyHandler(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusBadRequest, "Название товара не указано")
 		return
 	}
-	if err := h.svc.BuyItem(r.Context(), userID, item); err != nil {
+	opts := service.PurchaseOptions{
+		Size:        r.URL.Query().Get("size"),
+		Color:       r.URL.Query().Get("color"),
+		PromoCode:   r.URL.Query().Get("promo"),
+		GiftTo:      r.URL.Query().Get("giftTo"),
+		GiftMessage: r.URL.Query().Get("giftMessage"),
+	}
+	if err := h.svc.BuyItem(r.Context(), userID, item, opts); err != nil {
 		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
@@ -154,6 +240,66 @@ func (h Handler) JWTMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+func RequestMetaMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+		w.Header().Set("X-Request-ID", requestID)
+		ctx := service.WithRequestMeta(r.Context(), service.RequestMeta{RequestID: requestID, IP: ip})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+func (h Handler) ApproverMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return h.requireRole(next, models.RoleAdmin, models.RoleManager)
+}
+
+func (h Handler) AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return h.requireRole(next, models.RoleAdmin)
+}
+
+// requireRole проверяет роль по данным сервиса, а не по claim токена: токен
+// живёт сутки, и отозванные права не должны действовать до его истечения.
+func (h Handler) requireRole(next http.HandlerFunc, roles ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, ok := r.Context().Value(userIDKey).(int)
+		if !ok {
+			respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+			return
+		}
+		role, err := h.svc.UserRole(r.Context(), userID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				respondWithError(w, http.StatusUnauthorized, "Пользователь не найден")
+				return
+			}
+			respondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, allowed := range roles {
+			if role == allowed {
+				next(w, r)
+				return
+			}
+		}
+		respondWithError(w, http.StatusForbidden, "Недостаточно прав")
+	}
+}
+
 func respondWithError(w http.ResponseWriter, code int, message string) {
 	w.WriteHeader(code)
 	if err := json.NewEncoder(w).Encode(ErrorResponse{Errors: message}); err != nil {
@@ -179,3 +325,19 @@ func stringify(val interface{}) string {
 		return ""
 	}
 }
+
+func respondWithServiceError(w http.ResponseWriter, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Не найдено")
+		return
+	}
+	respondWithError(w, http.StatusBadRequest, err.Error())
+}
+
+func pathID(r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
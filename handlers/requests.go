@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"AVTproject/service"
+)
+
+type CoinRequestRequest struct {
+	FromUser string `json:"fromUser"`
+	Amount   int    `json:"amount"`
+	Memo     string `json:"memo"`
+}
+
+func (h Handler) CreateCoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req CoinRequestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	if req.FromUser == "" || req.Amount <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Неверные параметры запроса")
+		return
+	}
+	request, err := h.svc.CreateCoinRequest(r.Context(), userID, req.FromUser, req.Amount, req.Memo)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, request)
+}
+
+func (h Handler) IncomingCoinRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	requests, err := h.svc.ListIncomingCoinRequests(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, requests)
+}
+
+func (h Handler) OutgoingCoinRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	requests, err := h.svc.ListOutgoingCoinRequests(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, requests)
+}
+
+func (h Handler) PayCoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	result, err := h.svc.PayCoinRequest(r.Context(), userID, id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	if result.Status != service.TransferStatusCompleted {
+		respondWithJSON(w, http.StatusAccepted, result)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+func (h Handler) DeclineCoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	if err := h.svc.DeclineCoinRequest(r.Context(), userID, id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h Handler) CancelCoinRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	if err := h.svc.CancelCoinRequest(r.Context(), userID, id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+type ReturnRequest struct {
+	Reason string `json:"reason"`
+}
+
+func (h Handler) ListPurchasesHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	purchases, err := h.svc.ListPurchases(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, purchases)
+}
+
+func (h Handler) RequestReturnHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	var req ReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	ret, err := h.svc.RequestReturn(r.Context(), userID, id, req.Reason)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, ret)
+}
+
+func (h Handler) OwnReturnsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	returns, err := h.svc.ListOwnReturns(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, returns)
+}
+
+func (h Handler) ListReturnsHandler(w http.ResponseWriter, r *http.Request) {
+	returns, err := h.svc.ListReturns(r.Context(), r.URL.Query().Get("status"))
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, returns)
+}
+
+func (h Handler) ApproveReturnHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	if err := h.svc.ApproveReturn(r.Context(), userID, id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h Handler) RejectReturnHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	var req ReturnRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	if err := h.svc.RejectReturn(r.Context(), userID, id, req.Reason); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"AVTproject/service"
+)
+
+type ScheduleTransferRequest struct {
+	ToUser   string    `json:"toUser"`
+	Amount   int       `json:"amount"`
+	Memo     string    `json:"memo"`
+	Category string    `json:"category"`
+	RunAt    time.Time `json:"runAt"`
+	Interval string    `json:"interval"`
+}
+
+func (req ScheduleTransferRequest) toService() service.ScheduleRequest {
+	return service.ScheduleRequest{
+		ToUser:   req.ToUser,
+		Amount:   req.Amount,
+		Memo:     req.Memo,
+		Category: req.Category,
+		RunAt:    req.RunAt,
+		Interval: req.Interval,
+	}
+}
+
+func (h Handler) CreateScheduledTransferHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req ScheduleTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	scheduled, err := h.svc.CreateScheduledTransfer(r.Context(), userID, req.toService())
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, scheduled)
+}
+
+func (h Handler) ListScheduledTransfersHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	scheduled, err := h.svc.ListScheduledTransfers(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, scheduled)
+}
+
+func (h Handler) UpdateScheduledTransferHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	var req ScheduleTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	scheduled, err := h.svc.UpdateScheduledTransfer(r.Context(), userID, id, req.toService())
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, scheduled)
+}
+
+func (h Handler) CancelScheduledTransferHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	if err := h.svc.CancelScheduledTransfer(r.Context(), userID, id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
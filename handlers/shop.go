@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"AVTproject/service"
+
+	"github.com/gorilla/mux"
+)
+
+type ItemVariantRequest struct {
+	Size  string `json:"size"`
+	Color string `json:"color"`
+	Stock int    `json:"stock"`
+	Price *int   `json:"price"`
+}
+
+type PromoCodeRequest struct {
+	Code           string     `json:"code"`
+	Kind           string     `json:"kind"`
+	Value          int        `json:"value"`
+	Item           string     `json:"item"`
+	StartsAt       time.Time  `json:"startsAt"`
+	EndsAt         *time.Time `json:"endsAt"`
+	MaxRedemptions int        `json:"maxRedemptions"`
+	PerUserLimit   int        `json:"perUserLimit"`
+}
+
+type PriceScheduleRequest struct {
+	Item      string    `json:"item"`
+	SalePrice int       `json:"salePrice"`
+	StartsAt  time.Time `json:"startsAt"`
+	EndsAt    time.Time `json:"endsAt"`
+}
+
+func (h Handler) ListItemsHandler(w http.ResponseWriter, r *http.Request) {
+	items, err := h.svc.ListItems(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, items)
+}
+
+func (h Handler) SetItemVariantHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req ItemVariantRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	variant, err := h.svc.SetItemVariant(r.Context(), userID, mux.Vars(r)["item"], service.ItemVariantRequest{
+		Size:  req.Size,
+		Color: req.Color,
+		Stock: req.Stock,
+		Price: req.Price,
+	})
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, variant)
+}
+
+func (h Handler) CreatePromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req PromoCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	promo, err := h.svc.CreatePromoCode(r.Context(), userID, service.PromoCodeRequest{
+		Code:           req.Code,
+		Kind:           req.Kind,
+		Value:          req.Value,
+		Item:           req.Item,
+		StartsAt:       req.StartsAt,
+		EndsAt:         req.EndsAt,
+		MaxRedemptions: req.MaxRedemptions,
+		PerUserLimit:   req.PerUserLimit,
+	})
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, promo)
+}
+
+func (h Handler) ListPromoCodesHandler(w http.ResponseWriter, r *http.Request) {
+	codes, err := h.svc.ListPromoCodes(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, codes)
+}
+
+func (h Handler) DeactivatePromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	if err := h.svc.DeactivatePromoCode(r.Context(), userID, id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h Handler) CreatePriceScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req PriceScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	schedule, err := h.svc.CreatePriceSchedule(r.Context(), userID, service.PriceScheduleRequest{
+		Item:      req.Item,
+		SalePrice: req.SalePrice,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+	})
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, schedule)
+}
+
+func (h Handler) ListPriceSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.svc.ListPriceSchedules(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, schedules)
+}
+
+func (h Handler) DeletePriceScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	if err := h.svc.DeletePriceSchedule(r.Context(), userID, id); err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"AVTproject/service"
+)
+
+type BillSplitRequest struct {
+	Total          int                  `json:"total"`
+	Memo           string               `json:"memo"`
+	Participants   []service.SplitShare `json:"participants"`
+	IncludeCreator bool                 `json:"includeCreator"`
+}
+
+func (h Handler) CreateBillSplitHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	var req BillSplitRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "Неверный запрос")
+		return
+	}
+	split, err := h.svc.CreateBillSplit(r.Context(), userID, service.BillSplitRequest{
+		Total:          req.Total,
+		Memo:           req.Memo,
+		Participants:   req.Participants,
+		IncludeCreator: req.IncludeCreator,
+	})
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, split)
+}
+
+func (h Handler) ListBillSplitsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	splits, err := h.svc.ListBillSplits(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, splits)
+}
+
+func (h Handler) BillSplitHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := r.Context().Value(userIDKey).(int)
+	if !ok {
+		respondWithError(w, http.StatusUnauthorized, "Пользователь не найден в контексте")
+		return
+	}
+	id, ok := pathID(r)
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "Неверный идентификатор")
+		return
+	}
+	split, err := h.svc.GetBillSplit(r.Context(), userID, id)
+	if err != nil {
+		respondWithServiceError(w, err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, split)
+}
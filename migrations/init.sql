@@ -2,24 +2,385 @@ CREATE TABLE IF NOT EXISTS users (
                                      id SERIAL PRIMARY KEY,
                                      username VARCHAR(50) UNIQUE NOT NULL,
     password VARCHAR(255) NOT NULL,
-    coins INTEGER NOT NULL DEFAULT 1000
+    coins INTEGER NOT NULL DEFAULT 1000,
+    bonus_coins INTEGER NOT NULL DEFAULT 0 CHECK (bonus_coins >= 0),
+    credit_limit INTEGER NOT NULL DEFAULT 0 CHECK (credit_limit >= 0),
+    role VARCHAR(20) NOT NULL DEFAULT 'user',
+    department VARCHAR(50),
+    active BOOLEAN NOT NULL DEFAULT TRUE
     );
 
+CREATE TABLE IF NOT EXISTS coin_lots (
+                                         id SERIAL PRIMARY KEY,
+                                         user_id INTEGER NOT NULL,
+                                         amount INTEGER NOT NULL,
+                                         remaining INTEGER NOT NULL,
+                                         granted_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+                                         expires_at TIMESTAMP,
+                                         FOREIGN KEY (user_id) REFERENCES users(id),
+    CHECK (remaining >= 0 AND remaining <= amount)
+    );
+
+CREATE INDEX IF NOT EXISTS coin_lots_user_idx ON coin_lots (user_id, expires_at) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS coin_lots_expiry_idx ON coin_lots (expires_at) WHERE remaining > 0;
+
 CREATE TABLE IF NOT EXISTS transactions (
                                             id SERIAL PRIMARY KEY,
                                             from_user_id INTEGER,
                                             to_user_id INTEGER,
                                             amount INTEGER NOT NULL,
+                                            type VARCHAR(20) NOT NULL DEFAULT 'transfer',
+                                            wallet VARCHAR(20) NOT NULL DEFAULT 'regular',
+                                            reason TEXT,
+                                            reference VARCHAR(100),
+                                            actor_id INTEGER,
+                                            memo VARCHAR(200),
+                                            category VARCHAR(30),
+                                            public BOOLEAN NOT NULL DEFAULT FALSE,
+                                            reversal_of INTEGER,
                                             created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
                                             FOREIGN KEY (from_user_id) REFERENCES users(id),
-    FOREIGN KEY (to_user_id) REFERENCES users(id)
+    FOREIGN KEY (to_user_id) REFERENCES users(id),
+    FOREIGN KEY (actor_id) REFERENCES users(id),
+    FOREIGN KEY (reversal_of) REFERENCES transactions(id)
+    );
+
+CREATE UNIQUE INDEX IF NOT EXISTS transactions_reversal_of_idx ON transactions (reversal_of) WHERE reversal_of IS NOT NULL;
+CREATE INDEX IF NOT EXISTS transactions_category_idx ON transactions (category);
+CREATE INDEX IF NOT EXISTS transactions_public_idx ON transactions (id) WHERE public;
+
+CREATE TABLE IF NOT EXISTS kudos_reactions (
+                                               transaction_id INTEGER NOT NULL,
+                                               user_id INTEGER NOT NULL,
+                                               reaction VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (transaction_id, user_id, reaction),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    );
+
+CREATE INDEX IF NOT EXISTS transactions_sender_idx ON transactions (from_user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS role_transfer_limits (
+                                                    role VARCHAR(20) PRIMARY KEY,
+                                                    per_transfer INTEGER,
+                                                    per_day INTEGER,
+    per_month INTEGER,
+    recipients_per_day INTEGER
+    );
+
+CREATE TABLE IF NOT EXISTS user_transfer_limits (
+                                                    user_id INTEGER PRIMARY KEY,
+                                                    per_transfer INTEGER,
+                                                    per_day INTEGER,
+    per_month INTEGER,
+    recipients_per_day INTEGER,
+    FOREIGN KEY (user_id) REFERENCES users(id)
+    );
+
+CREATE TABLE IF NOT EXISTS pending_transfers (
+                                                 id SERIAL PRIMARY KEY,
+                                                 kind VARCHAR(20) NOT NULL DEFAULT 'approval',
+                                                 from_user_id INTEGER NOT NULL,
+                                                 to_user_id INTEGER NOT NULL,
+                                                 amount INTEGER NOT NULL,
+                                                 memo VARCHAR(200),
+    category VARCHAR(30),
+    public BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    approver_id INTEGER,
+    decision_reason TEXT,
+    transaction_id INTEGER,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    decided_at TIMESTAMP,
+    FOREIGN KEY (from_user_id) REFERENCES users(id),
+    FOREIGN KEY (to_user_id) REFERENCES users(id),
+    FOREIGN KEY (approver_id) REFERENCES users(id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id)
+    );
+
+CREATE INDEX IF NOT EXISTS pending_transfers_status_idx ON pending_transfers (status, expires_at);
+CREATE INDEX IF NOT EXISTS pending_transfers_recipient_idx ON pending_transfers (to_user_id, kind, status);
+
+-- Партии монет удержанного перевода: сроки действия переходят получателю
+-- или возвращаются отправителю вместе с монетами.
+CREATE TABLE IF NOT EXISTS pending_transfer_lots (
+                                                     id SERIAL PRIMARY KEY,
+                                                     pending_transfer_id INTEGER NOT NULL,
+                                                     amount INTEGER NOT NULL,
+                                                     granted_at TIMESTAMP NOT NULL,
+                                                     expires_at TIMESTAMP,
+                                                     FOREIGN KEY (pending_transfer_id) REFERENCES pending_transfers(id)
+    );
+
+CREATE INDEX IF NOT EXISTS pending_transfer_lots_transfer_idx ON pending_transfer_lots (pending_transfer_id);
+
+CREATE TABLE IF NOT EXISTS bill_splits (
+                                           id SERIAL PRIMARY KEY,
+                                           creator_id INTEGER NOT NULL,
+                                           total INTEGER NOT NULL,
+                                           memo VARCHAR(200),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (creator_id) REFERENCES users(id)
+    );
+
+CREATE INDEX IF NOT EXISTS bill_splits_creator_idx ON bill_splits (creator_id);
+
+CREATE TABLE IF NOT EXISTS coin_requests (
+                                             id SERIAL PRIMARY KEY,
+                                             split_id INTEGER,
+                                             requester_id INTEGER NOT NULL,
+                                             payer_id INTEGER NOT NULL,
+                                             amount INTEGER NOT NULL,
+                                             memo VARCHAR(200),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    transaction_id INTEGER,
+    pending_transfer_id INTEGER,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    decided_at TIMESTAMP,
+    FOREIGN KEY (requester_id) REFERENCES users(id),
+    FOREIGN KEY (payer_id) REFERENCES users(id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+    FOREIGN KEY (pending_transfer_id) REFERENCES pending_transfers(id),
+    FOREIGN KEY (split_id) REFERENCES bill_splits(id)
+    );
+
+CREATE INDEX IF NOT EXISTS coin_requests_split_idx ON coin_requests (split_id) WHERE split_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS coin_requests_payer_idx ON coin_requests (payer_id, status);
+CREATE INDEX IF NOT EXISTS coin_requests_requester_idx ON coin_requests (requester_id, status);
+CREATE INDEX IF NOT EXISTS coin_requests_expiry_idx ON coin_requests (expires_at) WHERE status = 'pending';
+
+CREATE TABLE IF NOT EXISTS scheduled_transfers (
+                                                   id SERIAL PRIMARY KEY,
+                                                   from_user_id INTEGER NOT NULL,
+                                                   to_user_id INTEGER NOT NULL,
+                                                   amount INTEGER NOT NULL,
+                                                   memo VARCHAR(200),
+    category VARCHAR(20),
+    interval VARCHAR(20) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'active',
+    next_run_at TIMESTAMP NOT NULL,
+    retry_at TIMESTAMP NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    last_transaction_id INTEGER,
+    last_run_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (from_user_id) REFERENCES users(id),
+    FOREIGN KEY (to_user_id) REFERENCES users(id),
+    FOREIGN KEY (last_transaction_id) REFERENCES transactions(id)
+    );
+
+CREATE INDEX IF NOT EXISTS scheduled_transfers_due_idx ON scheduled_transfers (retry_at) WHERE status = 'active';
+CREATE INDEX IF NOT EXISTS scheduled_transfers_owner_idx ON scheduled_transfers (from_user_id);
+
+CREATE TABLE IF NOT EXISTS grant_policies (
+                                              id SERIAL PRIMARY KEY,
+                                              name VARCHAR(100) NOT NULL,
+                                              amount INTEGER NOT NULL,
+                                              interval VARCHAR(20) NOT NULL,
+    role VARCHAR(20),
+    department VARCHAR(50),
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+    );
+
+CREATE TABLE IF NOT EXISTS grant_runs (
+                                          id SERIAL PRIMARY KEY,
+                                          policy_id INTEGER NOT NULL,
+                                          period VARCHAR(20) NOT NULL,
+    recipients INTEGER NOT NULL,
+    total INTEGER NOT NULL,
+    actor_id INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (policy_id) REFERENCES grant_policies(id),
+    FOREIGN KEY (actor_id) REFERENCES users(id),
+    UNIQUE (policy_id, period)
+    );
+
+CREATE TABLE IF NOT EXISTS item_variants (
+                                             id SERIAL PRIMARY KEY,
+                                             item VARCHAR(50) NOT NULL,
+    size VARCHAR(20) NOT NULL DEFAULT '',
+    color VARCHAR(30) NOT NULL DEFAULT '',
+    stock INTEGER NOT NULL DEFAULT 0 CHECK (stock >= 0),
+    price INTEGER CHECK (price > 0),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (item, size, color)
+    );
+
+CREATE TABLE IF NOT EXISTS price_schedules (
+                                               id SERIAL PRIMARY KEY,
+                                               item VARCHAR(50) NOT NULL,
+    sale_price INTEGER NOT NULL CHECK (sale_price > 0),
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP NOT NULL,
+    actor_id INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CHECK (ends_at > starts_at),
+    FOREIGN KEY (actor_id) REFERENCES users(id)
+    );
+
+CREATE INDEX IF NOT EXISTS price_schedules_item_idx ON price_schedules (item, ends_at);
+
+CREATE TABLE IF NOT EXISTS promo_codes (
+                                           id SERIAL PRIMARY KEY,
+                                           code VARCHAR(32) UNIQUE NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    value INTEGER NOT NULL CHECK (value > 0),
+    item VARCHAR(50),
+    starts_at TIMESTAMP NOT NULL,
+    ends_at TIMESTAMP,
+    max_redemptions INTEGER NOT NULL DEFAULT 0,
+    per_user_limit INTEGER NOT NULL DEFAULT 0,
+    redemptions INTEGER NOT NULL DEFAULT 0,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
     );
 
 CREATE TABLE IF NOT EXISTS purchases (
                                          id SERIAL PRIMARY KEY,
                                          user_id INTEGER NOT NULL,
                                          item VARCHAR(50) NOT NULL,
+    variant_id INTEGER,
     quantity INTEGER NOT NULL,
+    price INTEGER NOT NULL,
+    paid_bonus INTEGER NOT NULL DEFAULT 0,
+    original_price INTEGER NOT NULL,
+    buyer_id INTEGER,
+    gift_message TEXT,
     created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-    FOREIGN KEY (user_id) REFERENCES users(id)
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (buyer_id) REFERENCES users(id),
+    FOREIGN KEY (variant_id) REFERENCES item_variants(id)
+    );
+
+CREATE INDEX IF NOT EXISTS purchases_buyer_idx ON purchases (buyer_id) WHERE buyer_id IS NOT NULL;
+
+-- Партии монет, которыми оплачена покупка: при возврате монеты получают
+-- прежние сроки действия.
+CREATE TABLE IF NOT EXISTS purchase_lots (
+                                             id SERIAL PRIMARY KEY,
+                                             purchase_id INTEGER NOT NULL,
+                                             amount INTEGER NOT NULL,
+                                             granted_at TIMESTAMP NOT NULL,
+                                             expires_at TIMESTAMP,
+                                             FOREIGN KEY (purchase_id) REFERENCES purchases(id)
+    );
+
+CREATE INDEX IF NOT EXISTS purchase_lots_purchase_idx ON purchase_lots (purchase_id);
+
+CREATE TABLE IF NOT EXISTS promo_redemptions (
+                                                 id SERIAL PRIMARY KEY,
+                                                 promo_id INTEGER NOT NULL,
+                                                 user_id INTEGER NOT NULL,
+                                                 item VARCHAR(50) NOT NULL,
+    discount INTEGER NOT NULL,
+    purchase_id INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    released_at TIMESTAMP,
+    FOREIGN KEY (promo_id) REFERENCES promo_codes(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (purchase_id) REFERENCES purchases(id)
+    );
+
+CREATE INDEX IF NOT EXISTS promo_redemptions_user_idx ON promo_redemptions (promo_id, user_id) WHERE released_at IS NULL;
+CREATE INDEX IF NOT EXISTS promo_redemptions_purchase_idx ON promo_redemptions (purchase_id) WHERE purchase_id IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS purchase_returns (
+                                                id SERIAL PRIMARY KEY,
+                                                purchase_id INTEGER NOT NULL,
+                                                user_id INTEGER NOT NULL,
+                                                reason TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    approver_id INTEGER,
+    decision_reason TEXT,
+    transaction_id INTEGER,
+    bonus_transaction_id INTEGER,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    decided_at TIMESTAMP,
+    FOREIGN KEY (purchase_id) REFERENCES purchases(id),
+    FOREIGN KEY (user_id) REFERENCES users(id),
+    FOREIGN KEY (approver_id) REFERENCES users(id),
+    FOREIGN KEY (transaction_id) REFERENCES transactions(id),
+    FOREIGN KEY (bonus_transaction_id) REFERENCES transactions(id)
     );
+
+CREATE UNIQUE INDEX IF NOT EXISTS purchase_returns_active_idx ON purchase_returns (purchase_id)
+    WHERE status IN ('pending', 'approved');
+CREATE INDEX IF NOT EXISTS purchase_returns_status_idx ON purchase_returns (status, created_at);
+
+CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+                                                     id SERIAL PRIMARY KEY,
+                                                     url TEXT NOT NULL,
+                                                     secret VARCHAR(128) NOT NULL,
+    event_types TEXT[] NOT NULL,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    );
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+                                                  id SERIAL PRIMARY KEY,
+                                                  subscription_id INTEGER NOT NULL,
+                                                  event_id VARCHAR(36) NOT NULL,
+                                                  event_type VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_code INTEGER,
+    last_error TEXT,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP,
+    FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id)
+    );
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (status, next_attempt_at);
+CREATE UNIQUE INDEX IF NOT EXISTS webhook_deliveries_event_idx ON webhook_deliveries (subscription_id, event_id);
+
+CREATE TABLE IF NOT EXISTS outbox_events (
+                                             id BIGSERIAL PRIMARY KEY,
+                                             event_id VARCHAR(36) UNIQUE NOT NULL,
+                                             event_type VARCHAR(50) NOT NULL,
+    user_id INTEGER NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    published_at TIMESTAMP
+    );
+
+CREATE INDEX IF NOT EXISTS outbox_events_unpublished_idx ON outbox_events (id) WHERE published_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS audit_log (
+                                         id BIGSERIAL PRIMARY KEY,
+                                         actor_id INTEGER,
+                                         action VARCHAR(50) NOT NULL,
+    target VARCHAR(100) NOT NULL,
+    before JSONB,
+    after JSONB,
+    request_id VARCHAR(64),
+    ip VARCHAR(64),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (actor_id) REFERENCES users(id)
+    );
+
+CREATE INDEX IF NOT EXISTS audit_log_actor_idx ON audit_log (actor_id, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_action_idx ON audit_log (action, created_at);
+CREATE INDEX IF NOT EXISTS audit_log_target_idx ON audit_log (target, created_at);
+
+CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_append_only();
+
+DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log;
+CREATE TRIGGER audit_log_no_truncate
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only();
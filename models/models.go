@@ -1,12 +1,131 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	RoleUser    = "user"
+	RoleManager = "manager"
+	RoleAdmin   = "admin"
+)
+
+const (
+	TransactionTypeTransfer   = "transfer"
+	TransactionTypeCredit     = "credit"
+	TransactionTypeAdjustment = "adjustment"
+	TransactionTypeReversal   = "reversal"
+	TransactionTypeGrant      = "grant"
+	TransactionTypeExpiry     = "expiry"
+	TransactionTypeRefund     = "refund"
+)
+
+const (
+	CategoryThanks        = "thanks"
+	CategoryReimbursement = "reimbursement"
+	CategoryGift          = "gift"
+	CategoryBonus         = "bonus"
+	CategoryOther         = "other"
+)
+
+const (
+	ReactionLike  = "like"
+	ReactionClap  = "clap"
+	ReactionHeart = "heart"
+	ReactionFire  = "fire"
+	ReactionParty = "party"
+)
+
+const (
+	EventCoinSent      = "coin.sent"
+	EventCoinReceived  = "coin.received"
+	EventItemPurchased = "item.purchased"
+	EventCoinReversed  = "coin.reversed"
+)
+
+const (
+	NotificationCoinReceived      = "coin.received"
+	NotificationBalanceChanged    = "balance.changed"
+	NotificationPurchaseCompleted = "purchase.completed"
+	NotificationTransferStatus    = "transfer.status"
+	NotificationTransferOffered   = "transfer.offered"
+	NotificationCoinRequested     = "coin_request.created"
+	NotificationCoinRequestStatus = "coin_request.status"
+	NotificationScheduledFailed   = "scheduled_transfer.failed"
+	NotificationReturnStatus      = "purchase_return.status"
+	NotificationGiftReceived      = "gift.received"
+)
+
+const (
+	PendingStatusPending  = "pending"
+	PendingStatusApproved = "approved"
+	PendingStatusRejected = "rejected"
+	PendingStatusExpired  = "expired"
+	PendingStatusAccepted = "accepted"
+	PendingStatusDeclined = "declined"
+)
+
+const (
+	CoinRequestPending        = "pending"
+	CoinRequestPaymentPending = "payment_pending"
+	CoinRequestPaid           = "paid"
+	CoinRequestDeclined       = "declined"
+	CoinRequestCancelled      = "cancelled"
+	CoinRequestExpired        = "expired"
+)
+
+const (
+	ReturnPending  = "pending"
+	ReturnApproved = "approved"
+	ReturnRejected = "rejected"
+)
+
+// Кошельки пользователя: обычные монеты можно переводить и тратить, бонусные
+// — только тратить в магазине.
+const (
+	WalletRegular = "regular"
+	WalletBonus   = "bonus"
+)
+
+const (
+	ScheduleActive    = "active"
+	ScheduleCompleted = "completed"
+	ScheduleFailed    = "failed"
+	ScheduleCancelled = "cancelled"
+)
+
+const (
+	IntervalOnce    = ""
+	IntervalDaily   = "daily"
+	IntervalWeekly  = "weekly"
+	IntervalMonthly = "monthly"
+)
+
+const (
+	DiscountPercent = "percent"
+	DiscountFixed   = "fixed"
+)
+
+const (
+	PendingKindApproval = "approval"
+	PendingKindEscrow   = "escrow"
+)
+
+const (
+	DeliveryStatusPending   = "pending"
+	DeliveryStatusDelivered = "delivered"
+	DeliveryStatusFailed    = "failed"
+)
 
 type User struct {
-	ID       int
-	Username string
-	Password string
-	Coins    int
+	ID          int
+	Username    string
+	Password    string
+	Coins       int
+	BonusCoins  int
+	CreditLimit int
+	Role        string
 }
 
 type Transaction struct {
@@ -14,13 +133,327 @@ type Transaction struct {
 	FromUserID int
 	ToUserID   int
 	Amount     int
+	Type       string
+	Wallet     string
+	Reason     string
+	Reference  string
+	ActorID    int
+	Memo       string
+	Category   string
+	Public     bool
+	ReversalOf int
 	CreatedAt  time.Time
 }
 
-type Purchase struct {
+// TransferLimitOverride хранит лимиты роли или пользователя. nil означает,
+// что значение наследуется с более общего уровня.
+type TransferLimitOverride struct {
+	PerTransfer      *int
+	PerDay           *int
+	PerMonth         *int
+	RecipientsPerDay *int
+}
+
+type PendingTransfer struct {
+	ID             int
+	Kind           string
+	FromUserID     int
+	ToUserID       int
+	FromUsername   string
+	ToUsername     string
+	Amount         int
+	Memo           string
+	Category       string
+	Public         bool
+	Status         string
+	ApproverID     int
+	DecisionReason string
+	TransactionID  int
+	ExpiresAt      time.Time
+	CreatedAt      time.Time
+	DecidedAt      *time.Time
+}
+
+type PendingTransferFilter struct {
+	FromUserID int
+	ToUserID   int
+	Kind       string
+	Status     string
+}
+
+type BillSplit struct {
+	ID              int
+	CreatorID       int
+	CreatorUsername string
+	Total           int
+	Memo            string
+	CreatedAt       time.Time
+}
+
+type CoinRequest struct {
+	ID                int
+	SplitID           int
+	RequesterID       int
+	PayerID           int
+	RequesterUsername string
+	PayerUsername     string
+	Amount            int
+	Memo              string
+	Status            string
+	TransactionID     int
+	PendingTransferID int
+	ExpiresAt         time.Time
+	CreatedAt         time.Time
+	DecidedAt         *time.Time
+}
+
+type CoinRequestFilter struct {
+	RequesterID int
+	PayerID     int
+	SplitID     int
+	Status      string
+}
+
+// ScheduledTransfer описывает отложенный или повторяющийся перевод.
+// NextRunAt — плановое время следующего перевода, RetryAt — когда
+// планировщик возьмёт его в работу (сдвигается при повторах и захвате).
+type ScheduledTransfer struct {
+	ID                int
+	FromUserID        int
+	ToUserID          int
+	FromUsername      string
+	ToUsername        string
+	Amount            int
+	Memo              string
+	Category          string
+	Interval          string
+	Status            string
+	NextRunAt         time.Time
+	RetryAt           time.Time
+	Attempts          int
+	LastError         string
+	LastTransactionID int
+	LastRunAt         *time.Time
+	CreatedAt         time.Time
+}
+
+// GrantPolicy описывает регулярное начисление монет. Пустые Role и
+// Department означают «все активные пользователи».
+type GrantPolicy struct {
+	ID         int
+	Name       string
+	Amount     int
+	Interval   string
+	Role       string
+	Department string
+	Active     bool
+	LastPeriod string
+	CreatedAt  time.Time
+}
+
+type GrantRun struct {
+	ID         int
+	PolicyID   int
+	Period     string
+	Recipients int
+	Total      int
+	ActorID    int
+	CreatedAt  time.Time
+}
+
+// CoinLot — партия начисленных монет. Списания расходуют партии в порядке
+// сгорания, остаток истёкших партий списывается фоновой задачей.
+type CoinLot struct {
 	ID        int
 	UserID    int
+	Amount    int
+	Remaining int
+	GrantedAt time.Time
+	ExpiresAt *time.Time
+}
+
+type TransferUsage struct {
+	SentToday       int
+	SentThisMonth   int
+	RecipientsToday []int
+}
+
+type FeedEntry struct {
+	TransactionID int
+	FromUsername  string
+	ToUsername    string
+	Amount        int
+	Memo          string
+	Category      string
+	CreatedAt     time.Time
+}
+
+type KudosReaction struct {
+	TransactionID int
+	UserID        int
+	Reaction      string
+}
+
+// ItemVariant — вариант мерча (размер, цвет) со своим остатком. Price
+// переопределяет цену из каталога, если задан.
+type ItemVariant struct {
+	ID    int
+	Item  string
+	Size  string
+	Color string
+	Stock int
+	Price *int
+}
+
+// PromoCode — скидка на покупку. Пустой Item означает любой товар, нулевые
+// MaxRedemptions и PerUserLimit — отсутствие ограничения.
+type PromoCode struct {
+	ID             int
+	Code           string
+	Kind           string
+	Value          int
+	Item           string
+	StartsAt       time.Time
+	EndsAt         *time.Time
+	MaxRedemptions int
+	PerUserLimit   int
+	Redemptions    int
+	Active         bool
+	CreatedAt      time.Time
+}
+
+type PromoRedemption struct {
+	ID         int
+	PromoID    int
+	UserID     int
+	Item       string
+	Discount   int
+	PurchaseID int
+	CreatedAt  time.Time
+}
+
+// PriceSchedule — временная цена товара (распродажа) на период
+// [StartsAt, EndsAt).
+type PriceSchedule struct {
+	ID        int
 	Item      string
-	Quantity  int
+	SalePrice int
+	StartsAt  time.Time
+	EndsAt    time.Time
+	ActorID   int
 	CreatedAt time.Time
 }
+
+// Purchase — одна покупка. Price — списанная цена (PaidBonus из неё
+// оплачено бонусными монетами), OriginalPrice — цена каталога до распродажи
+// и скидок; у покупок до их появления они нулевые. Для подарка UserID —
+// получатель, BuyerID — оплативший покупку.
+type Purchase struct {
+	ID            int
+	UserID        int
+	Username      string
+	BuyerID       int
+	BuyerUsername string
+	GiftMessage   string
+	Item          string
+	VariantID     int
+	Size          string
+	Color         string
+	Quantity      int
+	Price         int
+	PaidBonus     int
+	OriginalPrice int
+	CreatedAt     time.Time
+}
+
+// PurchaseReturn — заявка на возврат покупки. Amount — сумма к возврату,
+// равная списанной при покупке цене.
+type PurchaseReturn struct {
+	ID             int
+	PurchaseID     int
+	UserID         int
+	Username       string
+	Item           string
+	Size           string
+	Color          string
+	Amount         int
+	Reason         string
+	Status         string
+	ApproverID     int
+	DecisionReason string
+	// TransactionID и BonusTransactionID — возвраты в обычный и бонусный кошельки.
+	TransactionID      int
+	BonusTransactionID int
+	CreatedAt          time.Time
+	DecidedAt          *time.Time
+}
+
+type PurchaseReturnFilter struct {
+	UserID     int
+	PurchaseID int
+	Status     string
+}
+
+type WebhookSubscription struct {
+	ID         int
+	URL        string
+	Secret     string
+	EventTypes []string
+	Active     bool
+	CreatedAt  time.Time
+}
+
+type WebhookDelivery struct {
+	ID             int
+	SubscriptionID int
+	URL            string
+	Secret         string
+	EventType      string
+	Payload        []byte
+	Status         string
+	Attempts       int
+	ResponseCode   int
+	LastError      string
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+}
+
+type OutboxEvent struct {
+	ID          int64
+	EventID     string
+	Type        string
+	UserID      int
+	Payload     []byte
+	CreatedAt   time.Time
+	PublishedAt *time.Time
+}
+
+type UserNotification struct {
+	UserID int             `json:"userId"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type AuditEntry struct {
+	ID            int64
+	ActorID       int
+	ActorUsername string
+	Action        string
+	Target        string
+	Before        []byte
+	After         []byte
+	RequestID     string
+	IP            string
+	CreatedAt     time.Time
+}
+
+type AuditFilter struct {
+	ActorID int
+	Action  string
+	Target  string
+	From    time.Time
+	To      time.Time
+	Limit   int
+	Offset  int
+}
@@ -0,0 +1,98 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"AVTproject/models"
+
+	"github.com/lib/pq"
+)
+
+const subscriberBuffer = 16
+
+// Hub раздаёт уведомления, полученные через LISTEN, подписчикам этого экземпляра сервиса.
+type Hub struct {
+	mu   sync.RWMutex
+	subs map[int]map[chan models.UserNotification]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[int]map[chan models.UserNotification]struct{})}
+}
+
+func (h *Hub) Subscribe(userID int) (<-chan models.UserNotification, func()) {
+	ch := make(chan models.UserNotification, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[chan models.UserNotification]struct{})
+	}
+	h.subs[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subs[userID], ch)
+			if len(h.subs[userID]) == 0 {
+				delete(h.subs, userID)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
+
+func (h *Hub) Dispatch(n models.UserNotification) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for ch := range h.subs[n.UserID] {
+		select {
+		case ch <- n:
+		default:
+			// Медленный клиент не должен блокировать остальных: уведомление пропускается.
+		}
+	}
+}
+
+func (h *Hub) Listen(ctx context.Context, connStr, channel string) error {
+	listener := pq.NewListener(connStr, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Ошибка подключения LISTEN: %v", err)
+		}
+	})
+	defer func() { _ = listener.Close() }()
+
+	if err := listener.Listen(channel); err != nil {
+		return err
+	}
+
+	ping := time.NewTicker(90 * time.Second)
+	defer ping.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case n := <-listener.Notify:
+			if n == nil {
+				continue
+			}
+			var notification models.UserNotification
+			if err := json.Unmarshal([]byte(n.Extra), &notification); err != nil {
+				log.Printf("Неверный формат уведомления: %v", err)
+				continue
+			}
+			h.Dispatch(notification)
+		case <-ping.C:
+			if err := listener.Ping(); err != nil {
+				log.Printf("Ошибка проверки соединения LISTEN: %v", err)
+			}
+		}
+	}
+}
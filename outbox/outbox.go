@@ -0,0 +1,95 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"AVTproject/models"
+)
+
+const EnvelopeVersion = 1
+
+type Envelope struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Version    int             `json:"version"`
+	Key        string          `json:"key"`
+	UserID     int             `json:"userId"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+func NewEnvelope(e models.OutboxEvent) Envelope {
+	return Envelope{
+		ID:         e.EventID,
+		Type:       e.Type,
+		Version:    EnvelopeVersion,
+		Key:        "user:" + strconv.Itoa(e.UserID),
+		UserID:     e.UserID,
+		OccurredAt: e.CreatedAt,
+		Data:       e.Payload,
+	}
+}
+
+type Publisher interface {
+	Publish(ctx context.Context, env Envelope) error
+}
+
+type Store interface {
+	TryLockOutbox(ctx context.Context) (unlock func(), locked bool, err error)
+	FetchUnpublishedOutboxEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error)
+	MarkOutboxEventPublished(ctx context.Context, id int64) error
+}
+
+// Relay переносит события из таблицы outbox в Publisher. Доставка — как минимум
+// один раз: событие помечается опубликованным только после успешной публикации,
+// поэтому издатели должны быть идемпотентны по Envelope.ID.
+// Публикация идёт вне транзакции БД, каждое событие отмечается сразу после
+// отправки. Порядок событий одного пользователя сохраняется: после ошибки
+// публикации остальные события этого пользователя в пачке откладываются до
+// следующего запуска.
+type Relay struct {
+	store     Store
+	publisher Publisher
+	batchSize int
+}
+
+func NewRelay(store Store, publisher Publisher, batchSize int) Relay {
+	return Relay{
+		store:     store,
+		publisher: publisher,
+		batchSize: batchSize,
+	}
+}
+
+func (r Relay) Drain(ctx context.Context) error {
+	unlock, locked, err := r.store.TryLockOutbox(ctx)
+	if err != nil || !locked {
+		return err
+	}
+	defer unlock()
+
+	events, err := r.store.FetchUnpublishedOutboxEvents(ctx, r.batchSize)
+	if err != nil {
+		return err
+	}
+
+	blocked := make(map[int]bool)
+	for _, e := range events {
+		if blocked[e.UserID] {
+			continue
+		}
+		if err := r.publisher.Publish(ctx, NewEnvelope(e)); err != nil {
+			log.Printf("Ошибка публикации события %s: %v", e.EventID, err)
+			blocked[e.UserID] = true
+			continue
+		}
+		if err := r.store.MarkOutboxEventPublished(ctx, e.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
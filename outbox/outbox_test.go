@@ -0,0 +1,95 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"AVTproject/models"
+	"AVTproject/outbox"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	locked    bool
+	events    []models.OutboxEvent
+	published []int64
+	unlocked  bool
+}
+
+func (s *fakeStore) TryLockOutbox(ctx context.Context) (func(), bool, error) {
+	if !s.locked {
+		return nil, false, nil
+	}
+	return func() { s.unlocked = true }, true, nil
+}
+
+func (s *fakeStore) FetchUnpublishedOutboxEvents(ctx context.Context, limit int) ([]models.OutboxEvent, error) {
+	return s.events, nil
+}
+
+func (s *fakeStore) MarkOutboxEventPublished(ctx context.Context, id int64) error {
+	s.published = append(s.published, id)
+	return nil
+}
+
+type fakePublisher struct {
+	failEventID string
+	received    []string
+}
+
+func (p *fakePublisher) Publish(ctx context.Context, env outbox.Envelope) error {
+	if env.ID == p.failEventID {
+		return errors.New("брокер недоступен")
+	}
+	p.received = append(p.received, env.ID)
+	return nil
+}
+
+func TestRelay_Drain(t *testing.T) {
+	events := []models.OutboxEvent{
+		{ID: 1, EventID: "a1", Type: models.EventCoinSent, UserID: 1, Payload: []byte(`{}`)},
+		{ID: 2, EventID: "b1", Type: models.EventCoinReceived, UserID: 2, Payload: []byte(`{}`)},
+		{ID: 3, EventID: "a2", Type: models.EventItemPurchased, UserID: 1, Payload: []byte(`{}`)},
+		{ID: 4, EventID: "b2", Type: models.EventItemPurchased, UserID: 2, Payload: []byte(`{}`)},
+	}
+	tests := []struct {
+		name          string
+		locked        bool
+		failEventID   string
+		wantReceived  []string
+		wantPublished []int64
+	}{
+		{
+			name:          "All events published in order",
+			locked:        true,
+			wantReceived:  []string{"a1", "b1", "a2", "b2"},
+			wantPublished: []int64{1, 2, 3, 4},
+		},
+		{
+			name:          "Failure holds back later events of the same user",
+			locked:        true,
+			failEventID:   "a1",
+			wantReceived:  []string{"b1", "b2"},
+			wantPublished: []int64{2, 4},
+		},
+		{
+			name:   "Another relay holds the lock",
+			locked: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{locked: tt.locked, events: events}
+			publisher := &fakePublisher{failEventID: tt.failEventID}
+			relay := outbox.NewRelay(store, publisher, 10)
+
+			require.NoError(t, relay.Drain(context.Background()))
+			require.Equal(t, tt.wantReceived, publisher.received)
+			require.Equal(t, tt.wantPublished, store.published)
+			require.Equal(t, tt.locked, store.unlocked)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(_ context.Context, env Envelope) error {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	log.Printf("Событие: %s", body)
+	return nil
+}
+
+type FilePublisher struct {
+	mu   *sync.Mutex
+	path string
+}
+
+func NewFilePublisher(path string) FilePublisher {
+	return FilePublisher{mu: &sync.Mutex{}, path: path}
+}
+
+func (p FilePublisher) Publish(_ context.Context, env Envelope) error {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	f, err := os.OpenFile(p.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(body, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+type HTTPPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewHTTPPublisher(url string, timeout time.Duration) HTTPPublisher {
+	return HTTPPublisher{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (p HTTPPublisher) Publish(ctx context.Context, env Envelope) error {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Idempotency-Key", env.ID)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("неуспешный ответ: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type MultiPublisher []Publisher
+
+func (m MultiPublisher) Publish(ctx context.Context, env Envelope) error {
+	for _, p := range m {
+		if err := p.Publish(ctx, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
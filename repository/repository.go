@@ -3,31 +3,90 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"AVTproject/models"
+
+	"github.com/lib/pq"
 )
 
 type PostgresRepository struct {
-	db *sql.DB
+	db         *sql.DB
+	coinLotTTL time.Duration
+}
+
+type Option func(*PostgresRepository)
+
+// WithCoinLotTTL задаёт срок действия начисленных монет. Нулевое значение
+// означает, что монеты не сгорают.
+func WithCoinLotTTL(ttl time.Duration) Option {
+	return func(r *PostgresRepository) {
+		r.coinLotTTL = ttl
+	}
+}
+
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+type txKey struct{}
+
+func NewPostgresRepository(db *sql.DB, opts ...Option) PostgresRepository {
+	r := PostgresRepository{db: db}
+	for _, opt := range opts {
+		opt(&r)
+	}
+	return r
+}
+
+// WithTx выполняет fn в транзакции. Методы репозитория, вызванные с переданным
+// в fn контекстом, работают в этой же транзакции; вложенные вызовы используют
+// уже открытую транзакцию.
+func (r PostgresRepository) WithTx(
+	ctx context.Context,
+	fn func(ctx context.Context) error,
+) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func NewPostgresRepository(db *sql.DB) PostgresRepository {
-	return PostgresRepository{db: db}
+func (r PostgresRepository) conn(ctx context.Context) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return r.db
 }
 
 func (r PostgresRepository) GetUserByUsername(
 	ctx context.Context,
 	username string,
 ) (models.User, error) {
-	row := r.db.QueryRowContext(
+	row := r.conn(ctx).QueryRowContext(
 		ctx,
-		"SELECT id, username, password, coins FROM users WHERE username=$1",
+		"SELECT id, username, password, coins, bonus_coins, credit_limit, role FROM users WHERE username=$1",
 		username,
 	)
 	var u models.User
-	err := row.Scan(&u.ID, &u.Username, &u.Password, &u.Coins)
+	err := row.Scan(&u.ID, &u.Username, &u.Password, &u.Coins, &u.BonusCoins, &u.CreditLimit, &u.Role)
 	if err != nil {
 		return models.User{}, err
 	}
@@ -38,13 +97,13 @@ func (r PostgresRepository) GetUserByID(
 	ctx context.Context,
 	id int,
 ) (models.User, error) {
-	row := r.db.QueryRowContext(
+	row := r.conn(ctx).QueryRowContext(
 		ctx,
-		"SELECT id, username, password, coins FROM users WHERE id=$1",
+		"SELECT id, username, password, coins, bonus_coins, credit_limit, role FROM users WHERE id=$1",
 		id,
 	)
 	var u models.User
-	err := row.Scan(&u.ID, &u.Username, &u.Password, &u.Coins)
+	err := row.Scan(&u.ID, &u.Username, &u.Password, &u.Coins, &u.BonusCoins, &u.CreditLimit, &u.Role)
 	if err != nil {
 		return models.User{}, err
 	}
@@ -56,11 +115,17 @@ func (r PostgresRepository) CreateUser(
 	username, password string,
 ) (int, error) {
 	var id int
-	err := r.db.QueryRowContext(
-		ctx,
-		"INSERT INTO users (username, password, coins) VALUES ($1, $2, 1000) RETURNING id",
-		username, password,
-	).Scan(&id)
+	err := r.WithTx(ctx, func(ctx context.Context) error {
+		err := r.conn(ctx).QueryRowContext(
+			ctx,
+			"INSERT INTO users (username, password, coins) VALUES ($1, $2, 1000) RETURNING id",
+			username, password,
+		).Scan(&id)
+		if err != nil {
+			return err
+		}
+		return r.addCoinLot(ctx, id, 1000)
+	})
 	if err != nil {
 		return 0, err
 	}
@@ -70,121 +135,434 @@ func (r PostgresRepository) CreateUser(
 func (r PostgresRepository) UpdateUserCoins(
 	ctx context.Context,
 	id, delta int,
-) error {
-	tx, err := r.db.BeginTx(ctx, nil)
+) (int, error) {
+	return r.AdjustUserCoins(ctx, id, delta, false)
+}
+
+func (r PostgresRepository) AdjustUserCoins(
+	ctx context.Context,
+	id, delta int,
+	allowNegative bool,
+) (int, error) {
+	floor := 0
+	if allowNegative {
+		floor = math.MinInt
+	}
+	return r.changeUserCoins(ctx, id, delta, floor)
+}
+
+// ChargeUserCoins списывает amount, позволяя балансу опуститься до
+// -creditLimit, и возвращает израсходованные части партий.
+func (r PostgresRepository) ChargeUserCoins(
+	ctx context.Context,
+	id, amount, creditLimit int,
+) (int, []models.CoinLot, error) {
+	return r.withdrawUserCoins(ctx, id, amount, -creditLimit)
+}
+
+func (r PostgresRepository) changeUserCoins(
+	ctx context.Context,
+	id, delta, floor int,
+) (int, error) {
+	var newCoins int
+	err := r.WithTx(ctx, func(ctx context.Context) error {
+		coins, err := r.setUserCoins(ctx, id, delta, floor)
+		if err != nil {
+			return err
+		}
+		newCoins = coins + delta
+		return r.syncCoinLots(ctx, id, coins, newCoins)
+	})
 	if err != nil {
+		return 0, err
+	}
+	return newCoins, nil
+}
+
+func (r PostgresRepository) UpdateUserBonusCoins(
+	ctx context.Context,
+	id, delta int,
+) (int, error) {
+	var bonus int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		"UPDATE users SET bonus_coins = bonus_coins + $1 WHERE id=$2 AND bonus_coins + $1 >= 0 RETURNING bonus_coins",
+		delta, id,
+	).Scan(&bonus)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errors.New("недостаточно бонусных монет")
+	}
+	if err != nil {
+		return 0, err
+	}
+	return bonus, nil
+}
+
+// WithdrawUserCoins списывает amount и возвращает израсходованные части
+// партий, чтобы перевод мог передать получателю их сроки действия, а возврат
+// покупки — восстановить их.
+func (r PostgresRepository) WithdrawUserCoins(
+	ctx context.Context,
+	id, amount int,
+) (int, []models.CoinLot, error) {
+	return r.withdrawUserCoins(ctx, id, amount, 0)
+}
+
+func (r PostgresRepository) withdrawUserCoins(
+	ctx context.Context,
+	id, amount, floor int,
+) (int, []models.CoinLot, error) {
+	var (
+		newCoins int
+		consumed []models.CoinLot
+	)
+	err := r.WithTx(ctx, func(ctx context.Context) error {
+		coins, err := r.setUserCoins(ctx, id, -amount, floor)
+		if err != nil {
+			return err
+		}
+		newCoins = coins - amount
+		consumed, err = r.consumeCoinLots(ctx, id, max(coins, 0)-max(newCoins, 0))
 		return err
+	})
+	if err != nil {
+		return 0, nil, err
 	}
-	defer func() { _ = tx.Rollback() }()
+	return newCoins, consumed, nil
+}
+
+// DepositUserCoins зачисляет amount, сохраняя сроки действия переданных
+// партий. Часть суммы, не покрытая партиями, считается новым начислением.
+func (r PostgresRepository) DepositUserCoins(
+	ctx context.Context,
+	id, amount int,
+	lots []models.CoinLot,
+) (int, error) {
+	var newCoins int
+	err := r.WithTx(ctx, func(ctx context.Context) error {
+		coins, err := r.setUserCoins(ctx, id, amount, 0)
+		if err != nil {
+			return err
+		}
+		newCoins = coins + amount
+		// Сначала гасится долг: на него уходят партии с ближайшим сроком.
+		skip := amount - (max(newCoins, 0) - max(coins, 0))
+		for _, l := range lots {
+			take := min(l.Remaining, amount)
+			amount -= take
+			if skip >= take {
+				skip -= take
+				continue
+			}
+			if err := r.insertCoinLot(ctx, id, take-skip, l.GrantedAt, l.ExpiresAt); err != nil {
+				return err
+			}
+			skip = 0
+		}
+		if amount > skip {
+			return r.addCoinLot(ctx, id, amount-skip)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return newCoins, nil
+}
+
+// ParkCoinLots сохраняет партии удержанного перевода до его исхода.
+func (r PostgresRepository) ParkCoinLots(
+	ctx context.Context,
+	pendingTransferID int,
+	lots []models.CoinLot,
+) error {
+	return r.saveCoinLots(
+		ctx,
+		`INSERT INTO pending_transfer_lots (pending_transfer_id, amount, granted_at, expires_at)
+		 VALUES ($1, $2, $3, $4)`,
+		pendingTransferID, lots,
+	)
+}
+
+// TakeParkedCoinLots забирает партии удержанного перевода для зачисления
+// получателю или возврата отправителю.
+func (r PostgresRepository) TakeParkedCoinLots(
+	ctx context.Context,
+	pendingTransferID int,
+) ([]models.CoinLot, error) {
+	return r.takeCoinLots(
+		ctx,
+		`DELETE FROM pending_transfer_lots WHERE pending_transfer_id=$1
+		 RETURNING amount, granted_at, expires_at`,
+		pendingTransferID,
+	)
+}
+
+// AddPurchaseCoinLots запоминает партии, которыми оплачена покупка, чтобы при
+// возврате монеты получили прежние сроки действия.
+func (r PostgresRepository) AddPurchaseCoinLots(
+	ctx context.Context,
+	purchaseID int,
+	lots []models.CoinLot,
+) error {
+	return r.saveCoinLots(
+		ctx,
+		`INSERT INTO purchase_lots (purchase_id, amount, granted_at, expires_at)
+		 VALUES ($1, $2, $3, $4)`,
+		purchaseID, lots,
+	)
+}
+
+// TakePurchaseCoinLots забирает партии покупки для возврата монет.
+func (r PostgresRepository) TakePurchaseCoinLots(
+	ctx context.Context,
+	purchaseID int,
+) ([]models.CoinLot, error) {
+	return r.takeCoinLots(
+		ctx,
+		`DELETE FROM purchase_lots WHERE purchase_id=$1
+		 RETURNING amount, granted_at, expires_at`,
+		purchaseID,
+	)
+}
+
+func (r PostgresRepository) saveCoinLots(
+	ctx context.Context,
+	query string,
+	ownerID int,
+	lots []models.CoinLot,
+) error {
+	for _, l := range lots {
+		if _, err := r.conn(ctx).ExecContext(
+			ctx,
+			query,
+			ownerID, l.Remaining, l.GrantedAt, l.ExpiresAt,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r PostgresRepository) takeCoinLots(
+	ctx context.Context,
+	query string,
+	ownerID int,
+) ([]models.CoinLot, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var lots []models.CoinLot
+	for rows.Next() {
+		var l models.CoinLot
+		if err := rows.Scan(&l.Amount, &l.GrantedAt, &l.ExpiresAt); err != nil {
+			return nil, err
+		}
+		l.Remaining = l.Amount
+		lots = append(lots, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortCoinLots(lots)
+	return lots, nil
+}
 
+// setUserCoins меняет баланс на delta и возвращает баланс до изменения.
+func (r PostgresRepository) setUserCoins(ctx context.Context, id, delta, floor int) (int, error) {
 	var coins int
-	err = tx.QueryRowContext(
+	err := r.conn(ctx).QueryRowContext(
 		ctx,
 		"SELECT coins FROM users WHERE id=$1 FOR UPDATE",
 		id,
 	).Scan(&coins)
 	if err != nil {
-		return err
+		return 0, err
 	}
-
-	newCoins := coins + delta
-	if newCoins < 0 {
-		return errors.New("недостаточно монет")
+	if delta < 0 && coins+delta < floor {
+		return 0, errors.New("недостаточно монет")
 	}
-
-	_, err = tx.ExecContext(
+	_, err = r.conn(ctx).ExecContext(
 		ctx,
 		"UPDATE users SET coins=$1 WHERE id=$2",
-		newCoins, id,
+		coins+delta, id,
 	)
 	if err != nil {
+		return 0, err
+	}
+	return coins, nil
+}
+
+// syncCoinLots поддерживает сумму остатков партий равной положительной части
+// баланса: списание расходует партии в порядке FIFO (по сроку действия),
+// начисление создаёт новую партию с полным сроком. Погашение отрицательного
+// баланса партий не создаёт. Переводы и покупки используют WithdrawUserCoins
+// и DepositUserCoins, чтобы не продлевать срок действия переданных и
+// возвращённых монет.
+func (r PostgresRepository) syncCoinLots(ctx context.Context, userID, before, after int) error {
+	before, after = max(before, 0), max(after, 0)
+	switch {
+	case after > before:
+		return r.addCoinLot(ctx, userID, after-before)
+	case after < before:
+		_, err := r.consumeCoinLots(ctx, userID, before-after)
 		return err
 	}
-	return tx.Commit()
+	return nil
 }
 
-func (r PostgresRepository) AddTransaction(
+func (r PostgresRepository) addCoinLot(ctx context.Context, userID, amount int) error {
+	now := time.Now()
+	var expiresAt *time.Time
+	if r.coinLotTTL > 0 {
+		t := now.Add(r.coinLotTTL)
+		expiresAt = &t
+	}
+	return r.insertCoinLot(ctx, userID, amount, now, expiresAt)
+}
+
+func (r PostgresRepository) insertCoinLot(
 	ctx context.Context,
-	fromUserID, toUserID, amount int,
+	userID, amount int,
+	grantedAt time.Time,
+	expiresAt *time.Time,
 ) error {
-	_, err := r.db.ExecContext(
+	_, err := r.conn(ctx).ExecContext(
 		ctx,
-		"INSERT INTO transactions (from_user_id, to_user_id, amount, created_at) "+
-			"VALUES ($1, $2, $3, $4)",
-		fromUserID, toUserID, amount, time.Now(),
+		"INSERT INTO coin_lots (user_id, amount, remaining, granted_at, expires_at) VALUES ($1, $2, $2, $3, $4)",
+		userID, amount, grantedAt, expiresAt,
 	)
 	return err
 }
 
-func (r PostgresRepository) GetUserTransactions(
-	ctx context.Context,
-	userID int,
-) ([]models.Transaction, []models.Transaction, error) {
-	rows, err := r.db.QueryContext(
+// consumeCoinLots расходует amount из партий пользователя и возвращает
+// израсходованные части в порядке сгорания.
+func (r PostgresRepository) consumeCoinLots(ctx context.Context, userID, amount int) ([]models.CoinLot, error) {
+	if amount <= 0 {
+		return nil, nil
+	}
+	rows, err := r.conn(ctx).QueryContext(
 		ctx,
-		`SELECT id, from_user_id, to_user_id, amount, created_at 
-		 FROM transactions 
-		 WHERE to_user_id=$1 
-		 ORDER BY created_at DESC`,
+		`SELECT id, remaining, granted_at, expires_at FROM coin_lots
+		 WHERE user_id=$1 AND remaining > 0
+		 ORDER BY expires_at NULLS LAST, id
+		 FOR UPDATE`,
 		userID,
 	)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	defer func() { _ = rows.Close() }()
-
-	var received []models.Transaction
+	var lots []models.CoinLot
 	for rows.Next() {
-		var t models.Transaction
-		if err := rows.Scan(
-			&t.ID,
-			&t.FromUserID,
-			&t.ToUserID,
-			&t.Amount,
-			&t.CreatedAt,
+		var l models.CoinLot
+		if err := rows.Scan(&l.ID, &l.Remaining, &l.GrantedAt, &l.ExpiresAt); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		lots = append(lots, l)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	var consumed []models.CoinLot
+	for _, l := range lots {
+		if amount == 0 {
+			break
+		}
+		take := min(amount, l.Remaining)
+		if _, err := r.conn(ctx).ExecContext(
+			ctx,
+			"UPDATE coin_lots SET remaining = remaining - $1 WHERE id=$2",
+			take, l.ID,
 		); err != nil {
-			return nil, nil, err
+			return nil, err
 		}
-		received = append(received, t)
+		consumed = append(consumed, models.CoinLot{
+			UserID:    userID,
+			Amount:    take,
+			Remaining: take,
+			GrantedAt: l.GrantedAt,
+			ExpiresAt: l.ExpiresAt,
+		})
+		amount -= take
 	}
+	return consumed, nil
+}
+
+// sortCoinLots упорядочивает партии по сроку сгорания, бессрочные — в конце.
+func sortCoinLots(lots []models.CoinLot) {
+	sort.SliceStable(lots, func(i, j int) bool {
+		a, b := lots[i].ExpiresAt, lots[j].ExpiresAt
+		if a == nil || b == nil {
+			return a != nil && b == nil
+		}
+		return a.Before(*b)
+	})
+}
 
-	rows2, err := r.db.QueryContext(
+// ListUsersWithExpiredCoinLots возвращает пользователей, у которых есть
+// истёкшие партии с остатком.
+func (r PostgresRepository) ListUsersWithExpiredCoinLots(
+	ctx context.Context,
+	now time.Time,
+	limit int,
+) ([]int, error) {
+	rows, err := r.conn(ctx).QueryContext(
 		ctx,
-		`SELECT id, from_user_id, to_user_id, amount, created_at 
-		 FROM transactions 
-		 WHERE from_user_id=$1 
-		 ORDER BY created_at DESC`,
-		userID,
+		`SELECT DISTINCT user_id FROM coin_lots
+		 WHERE remaining > 0 AND expires_at <= $1
+		 ORDER BY user_id
+		 LIMIT $2`,
+		now, limit,
 	)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	defer func() { _ = rows2.Close() }()
+	defer func() { _ = rows.Close() }()
 
-	var sent []models.Transaction
-	for rows2.Next() {
-		var t models.Transaction
-		if err := rows2.Scan(
-			&t.ID,
-			&t.FromUserID,
-			&t.ToUserID,
-			&t.Amount,
-			&t.CreatedAt,
-		); err != nil {
-			return nil, nil, err
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
 		}
-		sent = append(sent, t)
+		ids = append(ids, id)
 	}
-	return received, sent, nil
+	return ids, rows.Err()
 }
 
-func (r PostgresRepository) GetUserPurchases(
+func (r PostgresRepository) SumExpiredCoinLots(
 	ctx context.Context,
 	userID int,
-) ([]models.Purchase, error) {
-	rows, err := r.db.QueryContext(
+	now time.Time,
+) (int, error) {
+	var sum int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		"SELECT COALESCE(SUM(remaining), 0) FROM coin_lots WHERE user_id=$1 AND remaining > 0 AND expires_at <= $2",
+		userID, now,
+	).Scan(&sum)
+	if err != nil {
+		return 0, err
+	}
+	return sum, nil
+}
+
+// ListCoinLots возвращает непотраченные партии пользователя со сроком
+// действия в порядке сгорания.
+func (r PostgresRepository) ListCoinLots(
+	ctx context.Context,
+	userID int,
+) ([]models.CoinLot, error) {
+	rows, err := r.conn(ctx).QueryContext(
 		ctx,
-		"SELECT id, user_id, item, quantity, created_at FROM purchases WHERE user_id=$1",
+		`SELECT id, user_id, amount, remaining, granted_at, expires_at FROM coin_lots
+		 WHERE user_id=$1 AND remaining > 0 AND expires_at IS NOT NULL
+		 ORDER BY expires_at, id`,
 		userID,
 	)
 	if err != nil {
@@ -192,49 +570,2045 @@ func (r PostgresRepository) GetUserPurchases(
 	}
 	defer func() { _ = rows.Close() }()
 
-	var purchases []models.Purchase
+	var lots []models.CoinLot
 	for rows.Next() {
-		var p models.Purchase
-		if err := rows.Scan(
-			&p.ID,
-			&p.UserID,
-			&p.Item,
-			&p.Quantity,
-			&p.CreatedAt,
-		); err != nil {
+		var l models.CoinLot
+		if err := rows.Scan(&l.ID, &l.UserID, &l.Amount, &l.Remaining, &l.GrantedAt, &l.ExpiresAt); err != nil {
 			return nil, err
 		}
-		purchases = append(purchases, p)
+		lots = append(lots, l)
 	}
-	return purchases, nil
+	return lots, rows.Err()
 }
 
-func (r PostgresRepository) AddPurchase(
+func (r PostgresRepository) LockUsers(
 	ctx context.Context,
-	userID int,
-	item string,
+	ids ...int,
 ) error {
-	var quantity int
-	err := r.db.QueryRowContext(
+	rows, err := r.conn(ctx).QueryContext(
 		ctx,
-		"SELECT quantity FROM purchases WHERE user_id=$1 AND item=$2",
-		userID, item,
-	).Scan(&quantity)
+		"SELECT id FROM users WHERE id = ANY($1) ORDER BY id FOR UPDATE",
+		pq.Array(ids),
+	)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			_, err = r.db.ExecContext(
-				ctx,
-				"INSERT INTO purchases (user_id, item, quantity, created_at) VALUES ($1, $2, 1, $3)",
-				userID, item, time.Now(),
-			)
-			return err
-		}
 		return err
 	}
-	_, err = r.db.ExecContext(
+	return rows.Close()
+}
+
+func (r PostgresRepository) AddTransaction(
+	ctx context.Context,
+	t models.Transaction,
+) (int, error) {
+	var id int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		"INSERT INTO transactions (from_user_id, to_user_id, amount, type, wallet, reason, reference, actor_id, memo, category, public, reversal_of, created_at) "+
+			"VALUES ($1, $2, $3, $4, COALESCE($5, 'regular'), $6, $7, $8, $9, $10, $11, $12, $13) RETURNING id",
+		nullableID(t.FromUserID), nullableID(t.ToUserID), t.Amount, t.Type, nullableString(t.Wallet),
+		nullableString(t.Reason), nullableString(t.Reference), nullableID(t.ActorID),
+		nullableString(t.Memo), nullableString(t.Category), t.Public, nullableID(t.ReversalOf), time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+const transactionColumns = `id, COALESCE(from_user_id, 0), COALESCE(to_user_id, 0), amount, type, wallet,
+	COALESCE(reason, ''), COALESCE(reference, ''), COALESCE(actor_id, 0),
+	COALESCE(memo, ''), COALESCE(category, ''), public, COALESCE(reversal_of, 0), created_at`
+
+func scanTransactions(rows *sql.Rows) ([]models.Transaction, error) {
+	defer func() { _ = rows.Close() }()
+
+	var transactions []models.Transaction
+	for rows.Next() {
+		var t models.Transaction
+		if err := rows.Scan(
+			&t.ID,
+			&t.FromUserID,
+			&t.ToUserID,
+			&t.Amount,
+			&t.Type,
+			&t.Wallet,
+			&t.Reason,
+			&t.Reference,
+			&t.ActorID,
+			&t.Memo,
+			&t.Category,
+			&t.Public,
+			&t.ReversalOf,
+			&t.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
+
+func (r PostgresRepository) GetUserTransactions(
+	ctx context.Context,
+	userID int,
+	category string,
+) ([]models.Transaction, []models.Transaction, error) {
+	rows, err := r.conn(ctx).QueryContext(
 		ctx,
-		"UPDATE purchases SET quantity = quantity + 1 WHERE user_id=$1 AND item=$2",
-		userID, item,
+		`SELECT `+transactionColumns+`
+		 FROM transactions
+		 WHERE to_user_id=$1 AND ($2 = '' OR category = $2)
+		 ORDER BY created_at DESC`,
+		userID, category,
 	)
-	return err
+	if err != nil {
+		return nil, nil, err
+	}
+	received, err := scanTransactions(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows, err = r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT `+transactionColumns+`
+		 FROM transactions
+		 WHERE from_user_id=$1 AND ($2 = '' OR category = $2)
+		 ORDER BY created_at DESC`,
+		userID, category,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	sent, err := scanTransactions(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	return received, sent, nil
+}
+
+func (r PostgresRepository) GetTransactionByID(
+	ctx context.Context,
+	id int,
+) (models.Transaction, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT `+transactionColumns+` FROM transactions WHERE id=$1`,
+		id,
+	)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	transactions, err := scanTransactions(rows)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	if len(transactions) == 0 {
+		return models.Transaction{}, sql.ErrNoRows
+	}
+	return transactions[0], nil
+}
+
+func (r PostgresRepository) HasReversal(
+	ctx context.Context,
+	transactionID int,
+) (bool, error) {
+	var exists bool
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		"SELECT EXISTS (SELECT 1 FROM transactions WHERE reversal_of=$1)",
+		transactionID,
+	).Scan(&exists)
+	return exists, err
+}
+
+func (r PostgresRepository) ListPublicTransfers(
+	ctx context.Context,
+	limit, offset int,
+) ([]models.FeedEntry, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT t.id, f.username, u.username, t.amount, COALESCE(t.memo, ''), COALESCE(t.category, ''), t.created_at
+		 FROM transactions t
+		 JOIN users f ON f.id = t.from_user_id
+		 JOIN users u ON u.id = t.to_user_id
+		 WHERE t.public AND t.type = $1
+		   AND NOT EXISTS (SELECT 1 FROM transactions r WHERE r.reversal_of = t.id)
+		 ORDER BY t.id DESC
+		 LIMIT $2 OFFSET $3`,
+		models.TransactionTypeTransfer, limit, offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []models.FeedEntry
+	for rows.Next() {
+		var e models.FeedEntry
+		if err := rows.Scan(
+			&e.TransactionID,
+			&e.FromUsername,
+			&e.ToUsername,
+			&e.Amount,
+			&e.Memo,
+			&e.Category,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func (r PostgresRepository) ListKudosReactions(
+	ctx context.Context,
+	transactionIDs []int,
+) ([]models.KudosReaction, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT transaction_id, user_id, reaction
+		 FROM kudos_reactions
+		 WHERE transaction_id = ANY($1)
+		 ORDER BY transaction_id, reaction, user_id`,
+		pq.Array(transactionIDs),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var reactions []models.KudosReaction
+	for rows.Next() {
+		var kr models.KudosReaction
+		if err := rows.Scan(&kr.TransactionID, &kr.UserID, &kr.Reaction); err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, kr)
+	}
+	return reactions, rows.Err()
+}
+
+func (r PostgresRepository) AddKudosReaction(
+	ctx context.Context,
+	kr models.KudosReaction,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`INSERT INTO kudos_reactions (transaction_id, user_id, reaction)
+		 VALUES ($1, $2, $3)
+		 ON CONFLICT DO NOTHING`,
+		kr.TransactionID, kr.UserID, kr.Reaction,
+	)
+	return err
+}
+
+func (r PostgresRepository) RemoveKudosReaction(
+	ctx context.Context,
+	kr models.KudosReaction,
+) error {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		"DELETE FROM kudos_reactions WHERE transaction_id=$1 AND user_id=$2 AND reaction=$3",
+		kr.TransactionID, kr.UserID, kr.Reaction,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r PostgresRepository) GetTransferLimitOverride(
+	ctx context.Context,
+	userID int,
+	role string,
+) (models.TransferLimitOverride, error) {
+	var perTransfer, perDay, perMonth, recipients sql.NullInt64
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT COALESCE(u.per_transfer, r.per_transfer),
+		        COALESCE(u.per_day, r.per_day),
+		        COALESCE(u.per_month, r.per_month),
+		        COALESCE(u.recipients_per_day, r.recipients_per_day)
+		 FROM (SELECT 1) AS one
+		 LEFT JOIN user_transfer_limits u ON u.user_id = $1
+		 LEFT JOIN role_transfer_limits r ON r.role = $2`,
+		userID, role,
+	).Scan(&perTransfer, &perDay, &perMonth, &recipients)
+	if err != nil {
+		return models.TransferLimitOverride{}, err
+	}
+	return models.TransferLimitOverride{
+		PerTransfer:      nullIntPtr(perTransfer),
+		PerDay:           nullIntPtr(perDay),
+		PerMonth:         nullIntPtr(perMonth),
+		RecipientsPerDay: nullIntPtr(recipients),
+	}, nil
+}
+
+func (r PostgresRepository) SetRoleTransferLimits(
+	ctx context.Context,
+	role string,
+	o models.TransferLimitOverride,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`INSERT INTO role_transfer_limits (role, per_transfer, per_day, per_month, recipients_per_day)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (role) DO UPDATE SET per_transfer = $2, per_day = $3, per_month = $4, recipients_per_day = $5`,
+		role, o.PerTransfer, o.PerDay, o.PerMonth, o.RecipientsPerDay,
+	)
+	return err
+}
+
+func (r PostgresRepository) SetUserTransferLimits(
+	ctx context.Context,
+	userID int,
+	o models.TransferLimitOverride,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`INSERT INTO user_transfer_limits (user_id, per_transfer, per_day, per_month, recipients_per_day)
+		 VALUES ($1, $2, $3, $4, $5)
+		 ON CONFLICT (user_id) DO UPDATE SET per_transfer = $2, per_day = $3, per_month = $4, recipients_per_day = $5`,
+		userID, o.PerTransfer, o.PerDay, o.PerMonth, o.RecipientsPerDay,
+	)
+	return err
+}
+
+// transferSpendQuery объединяет проведённые переводы и переводы, ожидающие
+// согласования: удержанные монеты тоже расходуют лимиты отправителя.
+const transferSpendQuery = `WITH spent AS (
+	SELECT to_user_id, amount, created_at FROM transactions t
+	WHERE from_user_id = $1 AND type = $2 AND created_at >= $3
+	  AND NOT EXISTS (SELECT 1 FROM transactions r WHERE r.reversal_of = t.id)
+	UNION ALL
+	SELECT to_user_id, amount, created_at FROM pending_transfers
+	WHERE from_user_id = $1 AND status = $4 AND created_at >= $3
+)`
+
+func (r PostgresRepository) GetTransferUsage(
+	ctx context.Context,
+	userID int,
+	dayStart, monthStart time.Time,
+) (models.TransferUsage, error) {
+	var usage models.TransferUsage
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		transferSpendQuery+`
+		 SELECT COALESCE(SUM(amount) FILTER (WHERE created_at >= $5), 0), COALESCE(SUM(amount), 0)
+		 FROM spent`,
+		userID, models.TransactionTypeTransfer, monthStart, models.PendingStatusPending, dayStart,
+	).Scan(&usage.SentToday, &usage.SentThisMonth)
+	if err != nil {
+		return models.TransferUsage{}, err
+	}
+
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		transferSpendQuery+`
+		 SELECT DISTINCT to_user_id FROM spent`,
+		userID, models.TransactionTypeTransfer, dayStart, models.PendingStatusPending,
+	)
+	if err != nil {
+		return models.TransferUsage{}, err
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return models.TransferUsage{}, err
+		}
+		usage.RecipientsToday = append(usage.RecipientsToday, id)
+	}
+	return usage, rows.Err()
+}
+
+func (r PostgresRepository) CreatePendingTransfer(
+	ctx context.Context,
+	p models.PendingTransfer,
+) (int, error) {
+	var id int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO pending_transfers (kind, from_user_id, to_user_id, amount, memo, category, public, status, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`,
+		p.Kind, p.FromUserID, p.ToUserID, p.Amount, nullableString(p.Memo), nullableString(p.Category),
+		p.Public, models.PendingStatusPending, p.ExpiresAt, time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+const pendingTransferQuery = `SELECT p.id, p.kind, p.from_user_id, p.to_user_id, f.username, t.username, p.amount,
+	       COALESCE(p.memo, ''), COALESCE(p.category, ''), p.public, p.status,
+	       COALESCE(p.approver_id, 0), COALESCE(p.decision_reason, ''), COALESCE(p.transaction_id, 0),
+	       p.expires_at, p.created_at, p.decided_at
+	FROM pending_transfers p
+	JOIN users f ON f.id = p.from_user_id
+	JOIN users t ON t.id = p.to_user_id`
+
+func scanPendingTransfers(rows *sql.Rows) ([]models.PendingTransfer, error) {
+	defer func() { _ = rows.Close() }()
+
+	var transfers []models.PendingTransfer
+	for rows.Next() {
+		var p models.PendingTransfer
+		if err := rows.Scan(
+			&p.ID,
+			&p.Kind,
+			&p.FromUserID,
+			&p.ToUserID,
+			&p.FromUsername,
+			&p.ToUsername,
+			&p.Amount,
+			&p.Memo,
+			&p.Category,
+			&p.Public,
+			&p.Status,
+			&p.ApproverID,
+			&p.DecisionReason,
+			&p.TransactionID,
+			&p.ExpiresAt,
+			&p.CreatedAt,
+			&p.DecidedAt,
+		); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, p)
+	}
+	return transfers, rows.Err()
+}
+
+func (r PostgresRepository) GetPendingTransferForUpdate(
+	ctx context.Context,
+	id int,
+) (models.PendingTransfer, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, pendingTransferQuery+` WHERE p.id = $1 FOR UPDATE OF p`, id)
+	if err != nil {
+		return models.PendingTransfer{}, err
+	}
+	transfers, err := scanPendingTransfers(rows)
+	if err != nil {
+		return models.PendingTransfer{}, err
+	}
+	if len(transfers) == 0 {
+		return models.PendingTransfer{}, sql.ErrNoRows
+	}
+	return transfers[0], nil
+}
+
+func (r PostgresRepository) ListPendingTransfers(
+	ctx context.Context,
+	f models.PendingTransferFilter,
+) ([]models.PendingTransfer, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		pendingTransferQuery+`
+		 WHERE ($1 = 0 OR p.from_user_id = $1) AND ($2 = 0 OR p.to_user_id = $2)
+		   AND ($3 = '' OR p.kind = $3) AND ($4 = '' OR p.status = $4)
+		 ORDER BY p.id DESC`,
+		f.FromUserID, f.ToUserID, f.Kind, f.Status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanPendingTransfers(rows)
+}
+
+func (r PostgresRepository) ClaimExpiredPendingTransfers(
+	ctx context.Context,
+	now time.Time,
+	limit int,
+) ([]models.PendingTransfer, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		pendingTransferQuery+`
+		 WHERE p.status = $1 AND p.expires_at <= $2
+		 ORDER BY p.id
+		 LIMIT $3
+		 FOR UPDATE OF p SKIP LOCKED`,
+		models.PendingStatusPending, now, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanPendingTransfers(rows)
+}
+
+func (r PostgresRepository) UpdatePendingTransfer(
+	ctx context.Context,
+	p models.PendingTransfer,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE pending_transfers
+		 SET status=$1, approver_id=$2, decision_reason=$3, transaction_id=$4, decided_at=$5
+		 WHERE id=$6`,
+		p.Status, nullableID(p.ApproverID), nullableString(p.DecisionReason),
+		nullableID(p.TransactionID), p.DecidedAt, p.ID,
+	)
+	return err
+}
+
+func (r PostgresRepository) CreateBillSplit(
+	ctx context.Context,
+	b models.BillSplit,
+) (int, error) {
+	var id int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		"INSERT INTO bill_splits (creator_id, total, memo, created_at) VALUES ($1, $2, $3, $4) RETURNING id",
+		b.CreatorID, b.Total, nullableString(b.Memo), time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r PostgresRepository) GetBillSplit(
+	ctx context.Context,
+	id int,
+) (models.BillSplit, error) {
+	var b models.BillSplit
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`SELECT b.id, b.creator_id, u.username, b.total, COALESCE(b.memo, ''), b.created_at
+		 FROM bill_splits b
+		 JOIN users u ON u.id = b.creator_id
+		 WHERE b.id = $1`,
+		id,
+	).Scan(&b.ID, &b.CreatorID, &b.CreatorUsername, &b.Total, &b.Memo, &b.CreatedAt)
+	if err != nil {
+		return models.BillSplit{}, err
+	}
+	return b, nil
+}
+
+func (r PostgresRepository) ListBillSplits(
+	ctx context.Context,
+	creatorID int,
+) ([]models.BillSplit, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT b.id, b.creator_id, u.username, b.total, COALESCE(b.memo, ''), b.created_at
+		 FROM bill_splits b
+		 JOIN users u ON u.id = b.creator_id
+		 WHERE b.creator_id = $1
+		 ORDER BY b.id DESC`,
+		creatorID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var splits []models.BillSplit
+	for rows.Next() {
+		var b models.BillSplit
+		if err := rows.Scan(&b.ID, &b.CreatorID, &b.CreatorUsername, &b.Total, &b.Memo, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		splits = append(splits, b)
+	}
+	return splits, rows.Err()
+}
+
+func (r PostgresRepository) CreateCoinRequest(
+	ctx context.Context,
+	cr models.CoinRequest,
+) (int, error) {
+	var id int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO coin_requests (split_id, requester_id, payer_id, amount, memo, status, expires_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		nullableID(cr.SplitID), cr.RequesterID, cr.PayerID, cr.Amount, nullableString(cr.Memo),
+		models.CoinRequestPending, cr.ExpiresAt, time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+const coinRequestQuery = `SELECT c.id, COALESCE(c.split_id, 0), c.requester_id, c.payer_id, rq.username, p.username, c.amount,
+	       COALESCE(c.memo, ''), c.status, COALESCE(c.transaction_id, 0), COALESCE(c.pending_transfer_id, 0),
+	       c.expires_at, c.created_at, c.decided_at
+	FROM coin_requests c
+	JOIN users rq ON rq.id = c.requester_id
+	JOIN users p ON p.id = c.payer_id`
+
+func scanCoinRequests(rows *sql.Rows) ([]models.CoinRequest, error) {
+	defer func() { _ = rows.Close() }()
+
+	var requests []models.CoinRequest
+	for rows.Next() {
+		var cr models.CoinRequest
+		if err := rows.Scan(
+			&cr.ID,
+			&cr.SplitID,
+			&cr.RequesterID,
+			&cr.PayerID,
+			&cr.RequesterUsername,
+			&cr.PayerUsername,
+			&cr.Amount,
+			&cr.Memo,
+			&cr.Status,
+			&cr.TransactionID,
+			&cr.PendingTransferID,
+			&cr.ExpiresAt,
+			&cr.CreatedAt,
+			&cr.DecidedAt,
+		); err != nil {
+			return nil, err
+		}
+		requests = append(requests, cr)
+	}
+	return requests, rows.Err()
+}
+
+func (r PostgresRepository) GetCoinRequestForUpdate(
+	ctx context.Context,
+	id int,
+) (models.CoinRequest, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, coinRequestQuery+` WHERE c.id = $1 FOR UPDATE OF c`, id)
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+	requests, err := scanCoinRequests(rows)
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+	if len(requests) == 0 {
+		return models.CoinRequest{}, sql.ErrNoRows
+	}
+	return requests[0], nil
+}
+
+// GetCoinRequestByPaymentForUpdate ищет запрос, оплаченный транзакцией
+// transactionID или удержанным переводом pendingTransferID. Нулевой
+// идентификатор ни с чем не совпадает.
+func (r PostgresRepository) GetCoinRequestByPaymentForUpdate(
+	ctx context.Context,
+	transactionID, pendingTransferID int,
+) (models.CoinRequest, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		coinRequestQuery+` WHERE c.transaction_id = $1 OR c.pending_transfer_id = $2 FOR UPDATE OF c`,
+		transactionID, pendingTransferID,
+	)
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+	requests, err := scanCoinRequests(rows)
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+	if len(requests) == 0 {
+		return models.CoinRequest{}, sql.ErrNoRows
+	}
+	return requests[0], nil
+}
+
+func (r PostgresRepository) ListCoinRequests(
+	ctx context.Context,
+	f models.CoinRequestFilter,
+) ([]models.CoinRequest, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		coinRequestQuery+`
+		 WHERE ($1 = 0 OR c.requester_id = $1) AND ($2 = 0 OR c.payer_id = $2)
+		   AND ($3 = 0 OR c.split_id = $3) AND ($4 = '' OR c.status = $4)
+		 ORDER BY c.id DESC`,
+		f.RequesterID, f.PayerID, f.SplitID, f.Status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCoinRequests(rows)
+}
+
+func (r PostgresRepository) UpdateCoinRequest(
+	ctx context.Context,
+	cr models.CoinRequest,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE coin_requests
+		 SET status=$1, transaction_id=$2, pending_transfer_id=$3, decided_at=$4
+		 WHERE id=$5`,
+		cr.Status, nullableID(cr.TransactionID), nullableID(cr.PendingTransferID), cr.DecidedAt, cr.ID,
+	)
+	return err
+}
+
+func (r PostgresRepository) ExpireCoinRequests(
+	ctx context.Context,
+	now time.Time,
+) ([]models.CoinRequest, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`UPDATE coin_requests
+		 SET status=$1, decided_at=$2
+		 WHERE status=$3 AND expires_at <= $2
+		 RETURNING id, requester_id, payer_id, amount, status`,
+		models.CoinRequestExpired, now, models.CoinRequestPending,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var requests []models.CoinRequest
+	for rows.Next() {
+		var cr models.CoinRequest
+		if err := rows.Scan(&cr.ID, &cr.RequesterID, &cr.PayerID, &cr.Amount, &cr.Status); err != nil {
+			return nil, err
+		}
+		requests = append(requests, cr)
+	}
+	return requests, rows.Err()
+}
+
+func (r PostgresRepository) CreateScheduledTransfer(
+	ctx context.Context,
+	st models.ScheduledTransfer,
+) (int, error) {
+	var id int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO scheduled_transfers
+		 (from_user_id, to_user_id, amount, memo, category, interval, status, next_run_at, retry_at, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, $9) RETURNING id`,
+		st.FromUserID, st.ToUserID, st.Amount, nullableString(st.Memo), nullableString(st.Category),
+		st.Interval, models.ScheduleActive, st.NextRunAt, time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+const scheduledTransferQuery = `SELECT t.id, t.from_user_id, t.to_user_id, f.username, tu.username, t.amount,
+	       COALESCE(t.memo, ''), COALESCE(t.category, ''), t.interval, t.status, t.next_run_at, t.retry_at,
+	       t.attempts, COALESCE(t.last_error, ''), COALESCE(t.last_transaction_id, 0), t.last_run_at, t.created_at
+	FROM scheduled_transfers t
+	JOIN users f ON f.id = t.from_user_id
+	JOIN users tu ON tu.id = t.to_user_id`
+
+func scanScheduledTransfers(rows *sql.Rows) ([]models.ScheduledTransfer, error) {
+	defer func() { _ = rows.Close() }()
+
+	var transfers []models.ScheduledTransfer
+	for rows.Next() {
+		var st models.ScheduledTransfer
+		if err := rows.Scan(
+			&st.ID,
+			&st.FromUserID,
+			&st.ToUserID,
+			&st.FromUsername,
+			&st.ToUsername,
+			&st.Amount,
+			&st.Memo,
+			&st.Category,
+			&st.Interval,
+			&st.Status,
+			&st.NextRunAt,
+			&st.RetryAt,
+			&st.Attempts,
+			&st.LastError,
+			&st.LastTransactionID,
+			&st.LastRunAt,
+			&st.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, st)
+	}
+	return transfers, rows.Err()
+}
+
+func (r PostgresRepository) GetScheduledTransferForUpdate(
+	ctx context.Context,
+	id int,
+) (models.ScheduledTransfer, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, scheduledTransferQuery+` WHERE t.id = $1 FOR UPDATE OF t`, id)
+	if err != nil {
+		return models.ScheduledTransfer{}, err
+	}
+	transfers, err := scanScheduledTransfers(rows)
+	if err != nil {
+		return models.ScheduledTransfer{}, err
+	}
+	if len(transfers) == 0 {
+		return models.ScheduledTransfer{}, sql.ErrNoRows
+	}
+	return transfers[0], nil
+}
+
+func (r PostgresRepository) ListScheduledTransfers(
+	ctx context.Context,
+	fromUserID int,
+) ([]models.ScheduledTransfer, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		scheduledTransferQuery+` WHERE t.from_user_id = $1 ORDER BY t.id DESC`,
+		fromUserID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanScheduledTransfers(rows)
+}
+
+func (r PostgresRepository) UpdateScheduledTransfer(
+	ctx context.Context,
+	st models.ScheduledTransfer,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE scheduled_transfers
+		 SET amount=$1, memo=$2, category=$3, interval=$4, status=$5, next_run_at=$6, retry_at=$7,
+		     attempts=$8, last_error=$9, last_transaction_id=$10, last_run_at=$11
+		 WHERE id=$12`,
+		st.Amount, nullableString(st.Memo), nullableString(st.Category), st.Interval, st.Status,
+		st.NextRunAt, st.RetryAt, st.Attempts, nullableString(st.LastError),
+		nullableID(st.LastTransactionID), st.LastRunAt, st.ID,
+	)
+	return err
+}
+
+// ClaimDueScheduledTransfers сдвигает retry_at наступивших переводов на время
+// аренды, чтобы другие экземпляры не взяли их повторно. Возвращаются только
+// id и новый retry_at, по которому исполнитель проверяет, что аренда за ним.
+func (r PostgresRepository) ClaimDueScheduledTransfers(
+	ctx context.Context,
+	now time.Time,
+	lease time.Duration,
+	limit int,
+) ([]models.ScheduledTransfer, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`UPDATE scheduled_transfers
+		 SET retry_at = $3
+		 WHERE id IN (
+		     SELECT id FROM scheduled_transfers
+		     WHERE status = $1 AND retry_at <= $2
+		     ORDER BY retry_at
+		     LIMIT $4
+		     FOR UPDATE SKIP LOCKED
+		 )
+		 RETURNING id, retry_at`,
+		models.ScheduleActive, now, now.Add(lease), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var transfers []models.ScheduledTransfer
+	for rows.Next() {
+		var st models.ScheduledTransfer
+		if err := rows.Scan(&st.ID, &st.RetryAt); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, st)
+	}
+	return transfers, rows.Err()
+}
+
+func (r PostgresRepository) SetUserCreditLimit(
+	ctx context.Context,
+	userID, limit int,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		"UPDATE users SET credit_limit=$1 WHERE id=$2",
+		limit, userID,
+	)
+	return err
+}
+
+func (r PostgresRepository) SetUserProfile(
+	ctx context.Context,
+	userID int,
+	department string,
+	active bool,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		"UPDATE users SET department=$1, active=$2 WHERE id=$3",
+		nullableString(department), active, userID,
+	)
+	return err
+}
+
+// ListGrantRecipients возвращает активных пользователей (при необходимости
+// только из отдела department) в порядке id, чтобы блокировки при начислении
+// брались в том же порядке, что и в LockUsers.
+func (r PostgresRepository) ListGrantRecipients(
+	ctx context.Context,
+	department string,
+) ([]models.User, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT id, username, role FROM users
+		 WHERE active AND ($1 = '' OR department = $1)
+		 ORDER BY id`,
+		department,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Role); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (r PostgresRepository) CreateGrantPolicy(
+	ctx context.Context,
+	p models.GrantPolicy,
+) (int, error) {
+	var id int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO grant_policies (name, amount, interval, role, department, active, created_at)
+		 VALUES ($1, $2, $3, $4, $5, TRUE, $6) RETURNING id`,
+		p.Name, p.Amount, p.Interval, nullableString(p.Role), nullableString(p.Department), time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+const grantPolicyQuery = `SELECT p.id, p.name, p.amount, p.interval, COALESCE(p.role, ''), COALESCE(p.department, ''),
+	       p.active, COALESCE((SELECT MAX(g.period) FROM grant_runs g WHERE g.policy_id = p.id), ''), p.created_at
+	FROM grant_policies p`
+
+func scanGrantPolicies(rows *sql.Rows) ([]models.GrantPolicy, error) {
+	defer func() { _ = rows.Close() }()
+
+	var policies []models.GrantPolicy
+	for rows.Next() {
+		var p models.GrantPolicy
+		if err := rows.Scan(
+			&p.ID,
+			&p.Name,
+			&p.Amount,
+			&p.Interval,
+			&p.Role,
+			&p.Department,
+			&p.Active,
+			&p.LastPeriod,
+			&p.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+func (r PostgresRepository) GetGrantPolicy(
+	ctx context.Context,
+	id int,
+) (models.GrantPolicy, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, grantPolicyQuery+` WHERE p.id = $1`, id)
+	if err != nil {
+		return models.GrantPolicy{}, err
+	}
+	policies, err := scanGrantPolicies(rows)
+	if err != nil {
+		return models.GrantPolicy{}, err
+	}
+	if len(policies) == 0 {
+		return models.GrantPolicy{}, sql.ErrNoRows
+	}
+	return policies[0], nil
+}
+
+func (r PostgresRepository) ListGrantPolicies(
+	ctx context.Context,
+	activeOnly bool,
+) ([]models.GrantPolicy, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		grantPolicyQuery+` WHERE (NOT $1 OR p.active) ORDER BY p.id`,
+		activeOnly,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanGrantPolicies(rows)
+}
+
+func (r PostgresRepository) DeactivateGrantPolicy(
+	ctx context.Context,
+	id int,
+) error {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		"UPDATE grant_policies SET active=FALSE WHERE id=$1 AND active",
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CreateGrantRun фиксирует начисление за период. Если за этот период
+// начисление уже было, возвращает false: уникальный ключ (policy_id, period)
+// защищает от повторного начисления при нескольких экземплярах.
+func (r PostgresRepository) CreateGrantRun(
+	ctx context.Context,
+	run models.GrantRun,
+) (int, bool, error) {
+	var id int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO grant_runs (policy_id, period, recipients, total, actor_id, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (policy_id, period) DO NOTHING
+		 RETURNING id`,
+		run.PolicyID, run.Period, run.Recipients, run.Total, nullableID(run.ActorID), time.Now(),
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return id, true, nil
+}
+
+// GetUserPurchases возвращает инвентарь пользователя: покупки, сгруппированные
+// по товару и варианту.
+func (r PostgresRepository) GetUserPurchases(
+	ctx context.Context,
+	userID int,
+) ([]models.Purchase, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT MIN(p.id), p.user_id, p.item, p.variant_id, COALESCE(v.size, ''), COALESCE(v.color, ''),
+		        SUM(p.quantity), MIN(p.created_at)
+		 FROM purchases p LEFT JOIN item_variants v ON v.id = p.variant_id
+		 WHERE p.user_id=$1
+		 GROUP BY p.user_id, p.item, p.variant_id, v.size, v.color
+		 HAVING SUM(p.quantity) > 0
+		 ORDER BY p.item, v.size, v.color`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var purchases []models.Purchase
+	for rows.Next() {
+		var p models.Purchase
+		var variantID sql.NullInt64
+		if err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Item,
+			&variantID,
+			&p.Size,
+			&p.Color,
+			&p.Quantity,
+			&p.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		p.VariantID = int(variantID.Int64)
+		purchases = append(purchases, p)
+	}
+	return purchases, nil
+}
+
+// AddPurchase записывает покупку одной единицы товара вместе с применённой
+// ценой; VariantID == 0 для товаров без вариантов.
+func (r PostgresRepository) AddPurchase(
+	ctx context.Context,
+	p models.Purchase,
+) (int, error) {
+	var id int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO purchases (user_id, item, variant_id, quantity, price, paid_bonus, original_price,
+		                        buyer_id, gift_message, created_at)
+		 VALUES ($1, $2, $3, 1, $4, $5, $6, $7, $8, $9) RETURNING id`,
+		p.UserID, p.Item, nullableID(p.VariantID), p.Price, p.PaidBonus, p.OriginalPrice,
+		nullableID(p.BuyerID), nullableString(p.GiftMessage), time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+const purchaseQuery = `SELECT p.id, p.user_id, u.username, COALESCE(p.buyer_id, 0), COALESCE(b.username, ''),
+	       COALESCE(p.gift_message, ''), p.item, COALESCE(p.variant_id, 0), COALESCE(v.size, ''), COALESCE(v.color, ''),
+	       p.quantity, p.price, p.paid_bonus, p.original_price, p.created_at
+	FROM purchases p
+	JOIN users u ON u.id = p.user_id
+	LEFT JOIN users b ON b.id = p.buyer_id
+	LEFT JOIN item_variants v ON v.id = p.variant_id`
+
+func scanPurchases(rows *sql.Rows) ([]models.Purchase, error) {
+	defer func() { _ = rows.Close() }()
+
+	var purchases []models.Purchase
+	for rows.Next() {
+		var p models.Purchase
+		if err := rows.Scan(
+			&p.ID,
+			&p.UserID,
+			&p.Username,
+			&p.BuyerID,
+			&p.BuyerUsername,
+			&p.GiftMessage,
+			&p.Item,
+			&p.VariantID,
+			&p.Size,
+			&p.Color,
+			&p.Quantity,
+			&p.Price,
+			&p.PaidBonus,
+			&p.OriginalPrice,
+			&p.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		purchases = append(purchases, p)
+	}
+	return purchases, rows.Err()
+}
+
+// ListPurchases возвращает покупки пользователя по одной, новые первыми,
+// включая подарки, которые он получил или оплатил.
+func (r PostgresRepository) ListPurchases(
+	ctx context.Context,
+	userID int,
+) ([]models.Purchase, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		purchaseQuery+` WHERE p.user_id=$1 OR p.buyer_id=$1 ORDER BY p.created_at DESC, p.id DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanPurchases(rows)
+}
+
+func (r PostgresRepository) GetPurchaseForUpdate(
+	ctx context.Context,
+	id int,
+) (models.Purchase, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, purchaseQuery+` WHERE p.id=$1 FOR UPDATE OF p`, id)
+	if err != nil {
+		return models.Purchase{}, err
+	}
+	purchases, err := scanPurchases(rows)
+	if err != nil {
+		return models.Purchase{}, err
+	}
+	if len(purchases) == 0 {
+		return models.Purchase{}, sql.ErrNoRows
+	}
+	return purchases[0], nil
+}
+
+func (r PostgresRepository) DecrementPurchase(
+	ctx context.Context,
+	id int,
+) error {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		"UPDATE purchases SET quantity = quantity - 1 WHERE id=$1 AND quantity > 0",
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("покупка уже возвращена")
+	}
+	return nil
+}
+
+func (r PostgresRepository) RestockItemVariant(
+	ctx context.Context,
+	id, quantity int,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		"UPDATE item_variants SET stock = stock + $2 WHERE id=$1",
+		id, quantity,
+	)
+	return err
+}
+
+func (r PostgresRepository) CreatePurchaseReturn(
+	ctx context.Context,
+	ret models.PurchaseReturn,
+) (int, error) {
+	var id int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO purchase_returns (purchase_id, user_id, reason, status, created_at)
+		 VALUES ($1, $2, $3, $4, $5) RETURNING id`,
+		ret.PurchaseID, ret.UserID, nullableString(ret.Reason), ret.Status, ret.CreatedAt,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+const purchaseReturnQuery = `SELECT r.id, r.purchase_id, r.user_id, u.username, p.item, COALESCE(v.size, ''), COALESCE(v.color, ''),
+	       p.price, COALESCE(r.reason, ''), r.status, COALESCE(r.approver_id, 0),
+	       COALESCE(r.decision_reason, ''), COALESCE(r.transaction_id, 0), COALESCE(r.bonus_transaction_id, 0),
+	       r.created_at, r.decided_at
+	FROM purchase_returns r
+	JOIN users u ON u.id = r.user_id
+	JOIN purchases p ON p.id = r.purchase_id
+	LEFT JOIN item_variants v ON v.id = p.variant_id`
+
+func scanPurchaseReturns(rows *sql.Rows) ([]models.PurchaseReturn, error) {
+	defer func() { _ = rows.Close() }()
+
+	var returns []models.PurchaseReturn
+	for rows.Next() {
+		var ret models.PurchaseReturn
+		var decidedAt sql.NullTime
+		if err := rows.Scan(
+			&ret.ID,
+			&ret.PurchaseID,
+			&ret.UserID,
+			&ret.Username,
+			&ret.Item,
+			&ret.Size,
+			&ret.Color,
+			&ret.Amount,
+			&ret.Reason,
+			&ret.Status,
+			&ret.ApproverID,
+			&ret.DecisionReason,
+			&ret.TransactionID,
+			&ret.BonusTransactionID,
+			&ret.CreatedAt,
+			&decidedAt,
+		); err != nil {
+			return nil, err
+		}
+		if decidedAt.Valid {
+			ret.DecidedAt = &decidedAt.Time
+		}
+		returns = append(returns, ret)
+	}
+	return returns, rows.Err()
+}
+
+func (r PostgresRepository) GetPurchaseReturnForUpdate(
+	ctx context.Context,
+	id int,
+) (models.PurchaseReturn, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, purchaseReturnQuery+` WHERE r.id=$1 FOR UPDATE OF r`, id)
+	if err != nil {
+		return models.PurchaseReturn{}, err
+	}
+	returns, err := scanPurchaseReturns(rows)
+	if err != nil {
+		return models.PurchaseReturn{}, err
+	}
+	if len(returns) == 0 {
+		return models.PurchaseReturn{}, sql.ErrNoRows
+	}
+	return returns[0], nil
+}
+
+func (r PostgresRepository) ListPurchaseReturns(
+	ctx context.Context,
+	f models.PurchaseReturnFilter,
+) ([]models.PurchaseReturn, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		purchaseReturnQuery+`
+		 WHERE ($1 = 0 OR r.user_id = $1) AND ($2 = 0 OR r.purchase_id = $2) AND ($3 = '' OR r.status = $3)
+		 ORDER BY r.created_at DESC, r.id DESC`,
+		f.UserID, f.PurchaseID, f.Status,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanPurchaseReturns(rows)
+}
+
+func (r PostgresRepository) UpdatePurchaseReturn(
+	ctx context.Context,
+	ret models.PurchaseReturn,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE purchase_returns
+		 SET status=$2, approver_id=$3, decision_reason=$4, transaction_id=$5, bonus_transaction_id=$6, decided_at=$7
+		 WHERE id=$1`,
+		ret.ID, ret.Status, nullableID(ret.ApproverID), nullableString(ret.DecisionReason),
+		nullableID(ret.TransactionID), nullableID(ret.BonusTransactionID), ret.DecidedAt,
+	)
+	return err
+}
+
+func (r PostgresRepository) CreatePriceSchedule(
+	ctx context.Context,
+	p models.PriceSchedule,
+) (int, error) {
+	var id int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO price_schedules (item, sale_price, starts_at, ends_at, actor_id, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		p.Item, p.SalePrice, p.StartsAt, p.EndsAt, nullableID(p.ActorID), time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// ListPriceSchedules возвращает распродажи, которые ещё не закончились к
+// моменту from. Пустой item — распродажи всего каталога.
+func (r PostgresRepository) ListPriceSchedules(
+	ctx context.Context,
+	item string,
+	from time.Time,
+) ([]models.PriceSchedule, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT id, item, sale_price, starts_at, ends_at, COALESCE(actor_id, 0), created_at
+		 FROM price_schedules WHERE ($1 = '' OR item = $1) AND ends_at > $2
+		 ORDER BY starts_at, id`,
+		item, from,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var schedules []models.PriceSchedule
+	for rows.Next() {
+		var p models.PriceSchedule
+		if err := rows.Scan(
+			&p.ID,
+			&p.Item,
+			&p.SalePrice,
+			&p.StartsAt,
+			&p.EndsAt,
+			&p.ActorID,
+			&p.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, p)
+	}
+	return schedules, rows.Err()
+}
+
+func (r PostgresRepository) DeletePriceSchedule(
+	ctx context.Context,
+	id int,
+) error {
+	res, err := r.conn(ctx).ExecContext(ctx, "DELETE FROM price_schedules WHERE id=$1", id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+const itemVariantColumns = "id, item, size, color, stock, price"
+
+func scanItemVariant(row interface{ Scan(...interface{}) error }) (models.ItemVariant, error) {
+	var v models.ItemVariant
+	var price sql.NullInt64
+	if err := row.Scan(&v.ID, &v.Item, &v.Size, &v.Color, &v.Stock, &price); err != nil {
+		return models.ItemVariant{}, err
+	}
+	v.Price = nullIntPtr(price)
+	return v, nil
+}
+
+// ListItemVariants возвращает варианты товара, для пустого item — варианты
+// всего каталога.
+func (r PostgresRepository) ListItemVariants(
+	ctx context.Context,
+	item string,
+) ([]models.ItemVariant, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		"SELECT "+itemVariantColumns+" FROM item_variants WHERE ($1 = '' OR item = $1) ORDER BY item, id",
+		item,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var variants []models.ItemVariant
+	for rows.Next() {
+		v, err := scanItemVariant(rows)
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, v)
+	}
+	return variants, rows.Err()
+}
+
+func (r PostgresRepository) UpsertItemVariant(
+	ctx context.Context,
+	v models.ItemVariant,
+) (models.ItemVariant, error) {
+	return scanItemVariant(r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO item_variants (item, size, color, stock, price, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)
+		 ON CONFLICT (item, size, color) DO UPDATE SET stock = $4, price = $5
+		 RETURNING `+itemVariantColumns,
+		v.Item, v.Size, v.Color, v.Stock, v.Price, time.Now(),
+	))
+}
+
+// TakeItemVariantStock списывает единицу остатка варианта. sql.ErrNoRows
+// означает, что вариант закончился.
+func (r PostgresRepository) TakeItemVariantStock(
+	ctx context.Context,
+	id int,
+) (models.ItemVariant, error) {
+	return scanItemVariant(r.conn(ctx).QueryRowContext(
+		ctx,
+		"UPDATE item_variants SET stock = stock - 1 WHERE id=$1 AND stock > 0 RETURNING "+itemVariantColumns,
+		id,
+	))
+}
+
+func (r PostgresRepository) CreatePromoCode(
+	ctx context.Context,
+	p models.PromoCode,
+) (int, error) {
+	var id int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		`INSERT INTO promo_codes (code, kind, value, item, starts_at, ends_at, max_redemptions, per_user_limit, active, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, TRUE, $9) RETURNING id`,
+		p.Code, p.Kind, p.Value, nullableString(p.Item), p.StartsAt, p.EndsAt,
+		p.MaxRedemptions, p.PerUserLimit, time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+const promoCodeColumns = `id, code, kind, value, COALESCE(item, ''), starts_at, ends_at,
+	max_redemptions, per_user_limit, redemptions, active, created_at`
+
+func scanPromoCodes(rows *sql.Rows) ([]models.PromoCode, error) {
+	defer func() { _ = rows.Close() }()
+
+	var codes []models.PromoCode
+	for rows.Next() {
+		var p models.PromoCode
+		var endsAt sql.NullTime
+		if err := rows.Scan(
+			&p.ID,
+			&p.Code,
+			&p.Kind,
+			&p.Value,
+			&p.Item,
+			&p.StartsAt,
+			&endsAt,
+			&p.MaxRedemptions,
+			&p.PerUserLimit,
+			&p.Redemptions,
+			&p.Active,
+			&p.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if endsAt.Valid {
+			p.EndsAt = &endsAt.Time
+		}
+		codes = append(codes, p)
+	}
+	return codes, rows.Err()
+}
+
+// GetPromoCodeForUpdate блокирует промокод до конца транзакции, чтобы
+// проверка лимитов и погашение выполнялись атомарно.
+func (r PostgresRepository) GetPromoCodeForUpdate(
+	ctx context.Context,
+	code string,
+) (models.PromoCode, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		"SELECT "+promoCodeColumns+" FROM promo_codes WHERE code=$1 FOR UPDATE",
+		code,
+	)
+	if err != nil {
+		return models.PromoCode{}, err
+	}
+	codes, err := scanPromoCodes(rows)
+	if err != nil {
+		return models.PromoCode{}, err
+	}
+	if len(codes) == 0 {
+		return models.PromoCode{}, sql.ErrNoRows
+	}
+	return codes[0], nil
+}
+
+func (r PostgresRepository) ListPromoCodes(ctx context.Context) ([]models.PromoCode, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, "SELECT "+promoCodeColumns+" FROM promo_codes ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	return scanPromoCodes(rows)
+}
+
+func (r PostgresRepository) DeactivatePromoCode(
+	ctx context.Context,
+	id int,
+) error {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		"UPDATE promo_codes SET active=FALSE WHERE id=$1 AND active",
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r PostgresRepository) CountPromoRedemptions(
+	ctx context.Context,
+	promoID, userID int,
+) (int, error) {
+	var n int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		"SELECT COUNT(*) FROM promo_redemptions WHERE promo_id=$1 AND user_id=$2 AND released_at IS NULL",
+		promoID, userID,
+	).Scan(&n)
+	return n, err
+}
+
+// AddPromoRedemption записывает погашение и увеличивает счётчик промокода.
+func (r PostgresRepository) AddPromoRedemption(
+	ctx context.Context,
+	red models.PromoRedemption,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`INSERT INTO promo_redemptions (promo_id, user_id, item, discount, purchase_id, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6)`,
+		red.PromoID, red.UserID, red.Item, red.Discount, nullableID(red.PurchaseID), time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = r.conn(ctx).ExecContext(
+		ctx,
+		"UPDATE promo_codes SET redemptions = redemptions + 1 WHERE id=$1",
+		red.PromoID,
+	)
+	return err
+}
+
+// ReleasePromoRedemption отменяет погашение промокода при возврате покупки
+// и уменьшает счётчик промокода. Покупка без промокода ничего не меняет.
+func (r PostgresRepository) ReleasePromoRedemption(
+	ctx context.Context,
+	purchaseID int,
+) error {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`UPDATE promo_redemptions SET released_at=$2
+		 WHERE purchase_id=$1 AND released_at IS NULL
+		 RETURNING promo_id`,
+		purchaseID, time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+	var promoIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		promoIDs = append(promoIDs, id)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	for _, id := range promoIDs {
+		if _, err := r.conn(ctx).ExecContext(
+			ctx,
+			"UPDATE promo_codes SET redemptions = redemptions - 1 WHERE id=$1",
+			id,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r PostgresRepository) CreateWebhookSubscription(
+	ctx context.Context,
+	sub models.WebhookSubscription,
+) (int, error) {
+	var id int
+	err := r.conn(ctx).QueryRowContext(
+		ctx,
+		"INSERT INTO webhook_subscriptions (url, secret, event_types, active, created_at) "+
+			"VALUES ($1, $2, $3, TRUE, $4) RETURNING id",
+		sub.URL, sub.Secret, pq.Array(sub.EventTypes), time.Now(),
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r PostgresRepository) ListWebhookSubscriptions(
+	ctx context.Context,
+) ([]models.WebhookSubscription, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT id, url, secret, event_types, active, created_at
+		 FROM webhook_subscriptions
+		 ORDER BY id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var subs []models.WebhookSubscription
+	for rows.Next() {
+		var sub models.WebhookSubscription
+		if err := rows.Scan(
+			&sub.ID,
+			&sub.URL,
+			&sub.Secret,
+			pq.Array(&sub.EventTypes),
+			&sub.Active,
+			&sub.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (r PostgresRepository) DeactivateWebhookSubscription(
+	ctx context.Context,
+	id int,
+) error {
+	res, err := r.conn(ctx).ExecContext(
+		ctx,
+		"UPDATE webhook_subscriptions SET active=FALSE WHERE id=$1 AND active",
+		id,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// EnqueueWebhookDeliveries идемпотентна по событию: повторная публикация того же
+// события из outbox не создаёт подписчикам дублирующих доставок.
+func (r PostgresRepository) EnqueueWebhookDeliveries(
+	ctx context.Context,
+	eventID, eventType string,
+	payload []byte,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+		 SELECT id, $1, $2, $3, $4, $5, $5
+		 FROM webhook_subscriptions
+		 WHERE active AND $2 = ANY(event_types)
+		 ON CONFLICT (subscription_id, event_id) DO NOTHING`,
+		eventID, eventType, string(payload), models.DeliveryStatusPending, time.Now(),
+	)
+	return err
+}
+
+func (r PostgresRepository) ListWebhookDeliveries(
+	ctx context.Context,
+	subscriptionID, limit int,
+) ([]models.WebhookDelivery, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT id, subscription_id, event_type, payload, status, attempts,
+		        COALESCE(response_code, 0), COALESCE(last_error, ''), next_attempt_at, created_at, delivered_at
+		 FROM webhook_deliveries
+		 WHERE subscription_id=$1
+		 ORDER BY id DESC
+		 LIMIT $2`,
+		subscriptionID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(
+			&d.ID,
+			&d.SubscriptionID,
+			&d.EventType,
+			&d.Payload,
+			&d.Status,
+			&d.Attempts,
+			&d.ResponseCode,
+			&d.LastError,
+			&d.NextAttemptAt,
+			&d.CreatedAt,
+			&d.DeliveredAt,
+		); err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r PostgresRepository) ClaimDueWebhookDeliveries(
+	ctx context.Context,
+	limit int,
+	lease time.Duration,
+) ([]models.WebhookDelivery, error) {
+	now := time.Now()
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`UPDATE webhook_deliveries d
+		 SET next_attempt_at = $3
+		 FROM webhook_subscriptions s
+		 WHERE s.id = d.subscription_id
+		   AND d.id IN (
+		       SELECT wd.id
+		       FROM webhook_deliveries wd
+		       JOIN webhook_subscriptions ws ON ws.id = wd.subscription_id
+		       WHERE wd.status = $1 AND wd.next_attempt_at <= $2 AND ws.active
+		       ORDER BY wd.id
+		       LIMIT $4
+		       FOR UPDATE OF wd SKIP LOCKED
+		   )
+		 RETURNING d.id, d.subscription_id, s.url, s.secret, d.event_type, d.payload, d.attempts, d.created_at`,
+		models.DeliveryStatusPending, now, now.Add(lease), limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		if err := rows.Scan(
+			&d.ID,
+			&d.SubscriptionID,
+			&d.URL,
+			&d.Secret,
+			&d.EventType,
+			&d.Payload,
+			&d.Attempts,
+			&d.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		d.Status = models.DeliveryStatusPending
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r PostgresRepository) UpdateWebhookDelivery(
+	ctx context.Context,
+	d models.WebhookDelivery,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		`UPDATE webhook_deliveries
+		 SET status=$1, attempts=$2, response_code=$3, last_error=$4, next_attempt_at=$5, delivered_at=$6
+		 WHERE id=$7`,
+		d.Status, d.Attempts, d.ResponseCode, d.LastError, d.NextAttemptAt, d.DeliveredAt, d.ID,
+	)
+	return err
+}
+
+func (r PostgresRepository) AddOutboxEvent(
+	ctx context.Context,
+	e models.OutboxEvent,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		"INSERT INTO outbox_events (event_id, event_type, user_id, payload, created_at) "+
+			"VALUES ($1, $2, $3, $4, $5)",
+		e.EventID, e.Type, e.UserID, string(e.Payload), e.CreatedAt,
+	)
+	return err
+}
+
+// TryLockOutbox берёт сессионную advisory-блокировку на выделенном соединении,
+// чтобы relay не держал открытой транзакцию, пока публикует события. unlock
+// снимает блокировку и возвращает соединение в пул; если снять её не удалось,
+// соединение закрывается, и Postgres освобождает блокировку сам.
+func (r PostgresRepository) TryLockOutbox(ctx context.Context) (func(), bool, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	var locked bool
+	if err := conn.QueryRowContext(
+		ctx,
+		"SELECT pg_try_advisory_lock(hashtext('outbox_relay'))",
+	).Scan(&locked); err != nil || !locked {
+		_ = conn.Close()
+		return nil, false, err
+	}
+	unlock := func() {
+		if _, err := conn.ExecContext(
+			context.Background(),
+			"SELECT pg_advisory_unlock(hashtext('outbox_relay'))",
+		); err != nil {
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		_ = conn.Close()
+	}
+	return unlock, true, nil
+}
+
+func (r PostgresRepository) FetchUnpublishedOutboxEvents(
+	ctx context.Context,
+	limit int,
+) ([]models.OutboxEvent, error) {
+	rows, err := r.conn(ctx).QueryContext(
+		ctx,
+		`SELECT id, event_id, event_type, user_id, payload, created_at
+		 FROM outbox_events
+		 WHERE published_at IS NULL
+		 ORDER BY id
+		 LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var e models.OutboxEvent
+		if err := rows.Scan(
+			&e.ID,
+			&e.EventID,
+			&e.Type,
+			&e.UserID,
+			&e.Payload,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (r PostgresRepository) MarkOutboxEventPublished(
+	ctx context.Context,
+	id int64,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		"UPDATE outbox_events SET published_at=$1 WHERE id=$2",
+		time.Now(), id,
+	)
+	return err
+}
+
+const NotificationChannel = "user_events"
+
+func (r PostgresRepository) NotifyUser(
+	ctx context.Context,
+	payload []byte,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		"SELECT pg_notify($1, $2)",
+		NotificationChannel, string(payload),
+	)
+	return err
+}
+
+func (r PostgresRepository) AddAuditEntry(
+	ctx context.Context,
+	e models.AuditEntry,
+) error {
+	_, err := r.conn(ctx).ExecContext(
+		ctx,
+		"INSERT INTO audit_log (actor_id, action, target, before, after, request_id, ip, created_at) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		nullableID(e.ActorID), e.Action, e.Target, nullableJSON(e.Before), nullableJSON(e.After),
+		nullableString(e.RequestID), nullableString(e.IP), time.Now(),
+	)
+	return err
+}
+
+func (r PostgresRepository) ListAuditEntries(
+	ctx context.Context,
+	f models.AuditFilter,
+) ([]models.AuditEntry, error) {
+	var (
+		conds []string
+		args  []interface{}
+	)
+	addCond := func(cond string, arg interface{}) {
+		args = append(args, arg)
+		conds = append(conds, strings.Replace(cond, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+	if f.ActorID != 0 {
+		addCond("a.actor_id = ?", f.ActorID)
+	}
+	if f.Action != "" {
+		addCond("a.action = ?", f.Action)
+	}
+	if f.Target != "" {
+		addCond("a.target = ?", f.Target)
+	}
+	if !f.From.IsZero() {
+		addCond("a.created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		addCond("a.created_at < ?", f.To)
+	}
+
+	query := `SELECT a.id, COALESCE(a.actor_id, 0), COALESCE(u.username, ''), a.action, a.target,
+	                 a.before, a.after, COALESCE(a.request_id, ''), COALESCE(a.ip, ''), a.created_at
+	          FROM audit_log a
+	          LEFT JOIN users u ON u.id = a.actor_id`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	args = append(args, f.Limit, f.Offset)
+	query += " ORDER BY a.id DESC LIMIT $" + strconv.Itoa(len(args)-1) + " OFFSET $" + strconv.Itoa(len(args))
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []models.AuditEntry
+	for rows.Next() {
+		var e models.AuditEntry
+		if err := rows.Scan(
+			&e.ID,
+			&e.ActorID,
+			&e.ActorUsername,
+			&e.Action,
+			&e.Target,
+			&e.Before,
+			&e.After,
+			&e.RequestID,
+			&e.IP,
+			&e.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
+
+func nullableID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id != 0}
+}
+
+func nullableString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullableJSON(b []byte) sql.NullString {
+	return sql.NullString{String: string(b), Valid: len(b) > 0}
 }
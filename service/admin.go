@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"AVTproject/models"
+)
+
+const (
+	maxReasonLength     = 500
+	maxReferenceLength  = 100
+	maxDepartmentLength = 50
+)
+
+type BalanceAdjustment struct {
+	Username      string
+	Amount        int
+	Reason        string
+	Reference     string
+	Wallet        string
+	AllowNegative bool
+}
+
+type AdjustmentResponse struct {
+	TransactionID int    `json:"transactionId"`
+	Username      string `json:"username"`
+	Amount        int    `json:"amount"`
+	Wallet        string `json:"wallet,omitempty"`
+	Coins         int    `json:"coins"`
+}
+
+func (s Service) AdjustBalance(
+	ctx context.Context,
+	actorID int,
+	adj BalanceAdjustment,
+) (AdjustmentResponse, error) {
+	adj.Reason = strings.TrimSpace(adj.Reason)
+	adj.Reference = strings.TrimSpace(adj.Reference)
+	if adj.Username == "" || adj.Amount == 0 {
+		return AdjustmentResponse{}, errors.New("неверные параметры корректировки")
+	}
+	if adj.Reason == "" || utf8.RuneCountInString(adj.Reason) > maxReasonLength {
+		return AdjustmentResponse{}, errors.New("необходимо указать причину корректировки")
+	}
+	if adj.Reference == "" || utf8.RuneCountInString(adj.Reference) > maxReferenceLength {
+		return AdjustmentResponse{}, errors.New("необходимо указать основание корректировки")
+	}
+	switch adj.Wallet {
+	case "", models.WalletRegular:
+		adj.Wallet = ""
+	case models.WalletBonus:
+		if adj.AllowNegative {
+			return AdjustmentResponse{}, errors.New("бонусный баланс не может быть отрицательным")
+		}
+	default:
+		return AdjustmentResponse{}, errors.New("неизвестный тип кошелька")
+	}
+
+	var resp AdjustmentResponse
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		user, err := s.repo.GetUserByUsername(ctx, adj.Username)
+		if err != nil {
+			return err
+		}
+		var coins int
+		if adj.Wallet == models.WalletBonus {
+			coins, err = s.repo.UpdateUserBonusCoins(ctx, user.ID, adj.Amount)
+		} else {
+			coins, err = s.repo.AdjustUserCoins(ctx, user.ID, adj.Amount, adj.AllowNegative)
+		}
+		if err != nil {
+			return err
+		}
+
+		t := models.Transaction{
+			Amount:    adj.Amount,
+			Type:      models.TransactionTypeAdjustment,
+			Wallet:    adj.Wallet,
+			Reason:    adj.Reason,
+			Reference: adj.Reference,
+			ActorID:   actorID,
+		}
+		if adj.Amount > 0 {
+			t.ToUserID = user.ID
+		} else {
+			t.FromUserID = user.ID
+			t.Amount = -adj.Amount
+		}
+		transactionID, err := s.repo.AddTransaction(ctx, t)
+		if err != nil {
+			return err
+		}
+		balanceKey := "coins"
+		if adj.Wallet == models.WalletBonus {
+			balanceKey = "bonusCoins"
+		}
+		if err := s.audit(
+			ctx,
+			actorID,
+			AuditBalanceAdjustment,
+			userTarget(user.Username),
+			map[string]int{balanceKey: coins - adj.Amount},
+			map[string]interface{}{
+				balanceKey:      coins,
+				"amount":        adj.Amount,
+				"reason":        adj.Reason,
+				"reference":     adj.Reference,
+				"allowNegative": adj.AllowNegative,
+				"transactionId": transactionID,
+			},
+		); err != nil {
+			return err
+		}
+
+		resp = AdjustmentResponse{
+			TransactionID: transactionID,
+			Username:      user.Username,
+			Amount:        adj.Amount,
+			Wallet:        adj.Wallet,
+			Coins:         coins,
+		}
+		if adj.Wallet == models.WalletBonus {
+			return nil
+		}
+		return s.notifyBalance(ctx, user.ID, coins, adj.Amount)
+	})
+	if err != nil {
+		return AdjustmentResponse{}, err
+	}
+	return resp, nil
+}
+
+// SetUserProfile задаёт отдел пользователя и признак активности. Неактивные
+// пользователи не получают регулярных начислений.
+func (s Service) SetUserProfile(
+	ctx context.Context,
+	actorID int,
+	username, department string,
+	active bool,
+) error {
+	department = strings.TrimSpace(department)
+	if utf8.RuneCountInString(department) > maxDepartmentLength {
+		return errors.New("слишком длинное название отдела")
+	}
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		user, err := s.repo.GetUserByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.SetUserProfile(ctx, user.ID, department, active); err != nil {
+			return err
+		}
+		return s.audit(
+			ctx,
+			actorID,
+			AuditUserProfile,
+			userTarget(user.Username),
+			nil,
+			map[string]interface{}{"department": department, "active": active},
+		)
+	})
+}
+
+// SetCreditLimit задаёт, насколько обычный баланс пользователя может уйти в
+// минус при покупках. Уменьшение лимита ниже текущего долга не списывает
+// монеты, а только запрещает новые покупки в долг.
+func (s Service) SetCreditLimit(ctx context.Context, actorID int, username string, limit int) error {
+	if limit < 0 {
+		return errors.New("кредитный лимит не может быть отрицательным")
+	}
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		user, err := s.repo.GetUserByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.SetUserCreditLimit(ctx, user.ID, limit); err != nil {
+			return err
+		}
+		return s.audit(
+			ctx,
+			actorID,
+			AuditCreditLimit,
+			userTarget(user.Username),
+			map[string]int{"creditLimit": user.CreditLimit},
+			map[string]int{"creditLimit": limit},
+		)
+	})
+}
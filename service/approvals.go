@@ -0,0 +1,347 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"AVTproject/models"
+)
+
+type PendingTransferInfo struct {
+	ID             int        `json:"id"`
+	Kind           string     `json:"kind"`
+	FromUser       string     `json:"fromUser"`
+	ToUser         string     `json:"toUser"`
+	Amount         int        `json:"amount"`
+	Memo           string     `json:"memo,omitempty"`
+	Category       string     `json:"category,omitempty"`
+	Status         string     `json:"status"`
+	DecisionReason string     `json:"decisionReason,omitempty"`
+	ExpiresAt      time.Time  `json:"expiresAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	DecidedAt      *time.Time `json:"decidedAt,omitempty"`
+}
+
+type TransferStatusNotification struct {
+	ID     int    `json:"id"`
+	ToUser string `json:"toUser"`
+	Amount int    `json:"amount"`
+	Status string `json:"status"`
+}
+
+type TransferOfferedNotification struct {
+	ID        int       `json:"id"`
+	FromUser  string    `json:"fromUser"`
+	Amount    int       `json:"amount"`
+	Memo      string    `json:"memo,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// WithTransferApproval включает согласование переводов больше threshold монет.
+// Неподтверждённые за ttl переводы возвращаются отправителю.
+func WithTransferApproval(threshold int, ttl time.Duration) Option {
+	return func(s *Service) {
+		s.approvalThreshold = threshold
+		s.approvalTTL = ttl
+	}
+}
+
+// holdTransfer списывает монеты у отправителя и создаёт отложенный перевод:
+// на согласование руководителю или на подтверждение получателю. Получатель
+// получит монеты только после одобрения или подтверждения.
+func (s Service) holdTransfer(
+	ctx context.Context,
+	kind string,
+	sender, receiver models.User,
+	amount int,
+	opts TransferOptions,
+) (TransferResult, error) {
+	ttl, action, status := s.approvalTTL, AuditTransferHold, TransferStatusPending
+	if kind == models.PendingKindEscrow {
+		ttl, action, status = s.acceptanceTTL, AuditTransferEscrow, TransferStatusAwaitingAcceptance
+	}
+
+	spent, err := s.spend(ctx, sender, operationTransfer, amount)
+	if err != nil {
+		return TransferResult{}, err
+	}
+	senderCoins := spent.Coins
+	expiresAt := time.Now().Add(ttl)
+	id, err := s.repo.CreatePendingTransfer(ctx, models.PendingTransfer{
+		Kind:       kind,
+		FromUserID: sender.ID,
+		ToUserID:   receiver.ID,
+		Amount:     amount,
+		Memo:       opts.Memo,
+		Category:   opts.Category,
+		Public:     opts.Public,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		return TransferResult{}, err
+	}
+	if err := s.repo.ParkCoinLots(ctx, id, spent.Lots); err != nil {
+		return TransferResult{}, err
+	}
+	if err := s.audit(
+		ctx,
+		sender.ID,
+		action,
+		userTarget(receiver.Username),
+		map[string]int{"senderCoins": senderCoins + amount},
+		map[string]int{"senderCoins": senderCoins, "amount": amount, "pendingTransferId": id},
+	); err != nil {
+		return TransferResult{}, err
+	}
+	if err := s.notifyBalance(ctx, sender.ID, senderCoins, -amount); err != nil {
+		return TransferResult{}, err
+	}
+	if kind == models.PendingKindEscrow {
+		if err := s.notifyUser(ctx, receiver.ID, models.NotificationTransferOffered, TransferOfferedNotification{
+			ID:        id,
+			FromUser:  sender.Username,
+			Amount:    amount,
+			Memo:      opts.Memo,
+			ExpiresAt: expiresAt,
+		}); err != nil {
+			return TransferResult{}, err
+		}
+	}
+	return TransferResult{Status: status, PendingTransferID: id}, nil
+}
+
+func (s Service) ListPendingApprovals(ctx context.Context, approverID int) ([]PendingTransferInfo, error) {
+	if err := s.checkApprover(ctx, approverID); err != nil {
+		return nil, err
+	}
+	transfers, err := s.repo.ListPendingTransfers(ctx, models.PendingTransferFilter{
+		Kind:   models.PendingKindApproval,
+		Status: models.PendingStatusPending,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toPendingTransferInfos(transfers), nil
+}
+
+func (s Service) ListOwnPendingTransfers(ctx context.Context, userID int) ([]PendingTransferInfo, error) {
+	transfers, err := s.repo.ListPendingTransfers(ctx, models.PendingTransferFilter{FromUserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	return toPendingTransferInfos(transfers), nil
+}
+
+func (s Service) ApproveTransfer(ctx context.Context, approverID, id int) error {
+	if err := s.checkApprover(ctx, approverID); err != nil {
+		return err
+	}
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		p, err := s.pendingForApproval(ctx, approverID, id)
+		if err != nil {
+			return err
+		}
+		if time.Now().After(p.ExpiresAt) {
+			return errors.New("срок согласования перевода истёк")
+		}
+		if err := s.repo.LockUsers(ctx, p.FromUserID, p.ToUserID); err != nil {
+			return err
+		}
+		sender, err := s.repo.GetUserByID(ctx, p.FromUserID)
+		if err != nil {
+			return err
+		}
+		receiver, err := s.repo.GetUserByID(ctx, p.ToUserID)
+		if err != nil {
+			return err
+		}
+		lots, err := s.repo.TakeParkedCoinLots(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		_, transactionID, err := s.settleTransfer(ctx, sender, receiver, p.Amount, lots, TransferOptions{
+			Memo:     p.Memo,
+			Category: p.Category,
+			Public:   p.Public,
+		})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		p.Status = models.PendingStatusApproved
+		p.ApproverID = approverID
+		p.TransactionID = transactionID
+		p.DecidedAt = &now
+		if err := s.repo.UpdatePendingTransfer(ctx, p); err != nil {
+			return err
+		}
+		if err := s.completeCoinRequestPayment(ctx, p.ID, transactionID); err != nil {
+			return err
+		}
+		if err := s.audit(
+			ctx,
+			approverID,
+			AuditTransferApprove,
+			pendingTransferTarget(p.ID),
+			map[string]string{"status": models.PendingStatusPending},
+			map[string]interface{}{"status": p.Status, "transactionId": transactionID},
+		); err != nil {
+			return err
+		}
+		return s.notifyTransferStatus(ctx, p)
+	})
+}
+
+func (s Service) RejectTransfer(ctx context.Context, approverID, id int, reason string) error {
+	if err := s.checkApprover(ctx, approverID); err != nil {
+		return err
+	}
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		p, err := s.pendingForApproval(ctx, approverID, id)
+		if err != nil {
+			return err
+		}
+		p.ApproverID = approverID
+		p.DecisionReason = reason
+		return s.releaseTransfer(ctx, p, models.PendingStatusRejected, approverID, AuditTransferReject)
+	})
+}
+
+// ExpirePendingTransfers возвращает отправителям монеты по переводам, которые
+// не были согласованы вовремя. Строки блокируются с SKIP LOCKED, поэтому
+// задачу можно запускать на нескольких экземплярах одновременно.
+func (s Service) ExpirePendingTransfers(ctx context.Context) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		transfers, err := s.repo.ClaimExpiredPendingTransfers(ctx, time.Now(), 100)
+		if err != nil || len(transfers) == 0 {
+			return err
+		}
+		// Отправители блокируются сразу все и в порядке id, как в SendCoin:
+		// блокировка по очереди переводов могла бы встать в deadlock с переводами.
+		senderIDs := make([]int, 0, len(transfers))
+		for _, p := range transfers {
+			senderIDs = append(senderIDs, p.FromUserID)
+		}
+		if err := s.repo.LockUsers(ctx, senderIDs...); err != nil {
+			return err
+		}
+		for _, p := range transfers {
+			if err := s.releaseTransfer(ctx, p, models.PendingStatusExpired, 0, AuditTransferExpire); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s Service) releaseTransfer(
+	ctx context.Context,
+	p models.PendingTransfer,
+	status string,
+	actorID int,
+	action string,
+) error {
+	if err := s.repo.LockUsers(ctx, p.FromUserID); err != nil {
+		return err
+	}
+	lots, err := s.repo.TakeParkedCoinLots(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	coins, err := s.repo.DepositUserCoins(ctx, p.FromUserID, p.Amount, lots)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	p.Status = status
+	p.DecidedAt = &now
+	if err := s.repo.UpdatePendingTransfer(ctx, p); err != nil {
+		return err
+	}
+	if err := s.reopenCoinRequest(ctx, 0, p.ID, models.CoinRequestPaymentPending); err != nil {
+		return err
+	}
+	if err := s.audit(
+		ctx,
+		actorID,
+		action,
+		pendingTransferTarget(p.ID),
+		map[string]string{"status": models.PendingStatusPending},
+		map[string]interface{}{"status": status, "refunded": p.Amount, "senderCoins": coins},
+	); err != nil {
+		return err
+	}
+	if err := s.notifyBalance(ctx, p.FromUserID, coins, p.Amount); err != nil {
+		return err
+	}
+	return s.notifyTransferStatus(ctx, p)
+}
+
+func (s Service) pendingForApproval(ctx context.Context, approverID, id int) (models.PendingTransfer, error) {
+	p, err := s.repo.GetPendingTransferForUpdate(ctx, id)
+	if err != nil {
+		return models.PendingTransfer{}, err
+	}
+	if p.Kind != models.PendingKindApproval {
+		return models.PendingTransfer{}, sql.ErrNoRows
+	}
+	if p.Status != models.PendingStatusPending {
+		return models.PendingTransfer{}, errors.New("перевод уже обработан")
+	}
+	if p.FromUserID == approverID {
+		return models.PendingTransfer{}, errors.New("нельзя согласовать собственный перевод")
+	}
+	if p.ToUserID == approverID {
+		return models.PendingTransfer{}, errors.New("нельзя согласовать перевод самому себе")
+	}
+	return p, nil
+}
+
+func (s Service) checkApprover(ctx context.Context, userID int) error {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if role := s.roleOf(user); role != models.RoleAdmin && role != models.RoleManager {
+		return errors.New("недостаточно прав")
+	}
+	return nil
+}
+
+func (s Service) notifyTransferStatus(ctx context.Context, p models.PendingTransfer) error {
+	return s.notifyUser(ctx, p.FromUserID, models.NotificationTransferStatus, TransferStatusNotification{
+		ID:     p.ID,
+		ToUser: p.ToUsername,
+		Amount: p.Amount,
+		Status: p.Status,
+	})
+}
+
+func toPendingTransferInfos(transfers []models.PendingTransfer) []PendingTransferInfo {
+	result := make([]PendingTransferInfo, 0, len(transfers))
+	for _, p := range transfers {
+		result = append(result, PendingTransferInfo{
+			ID:             p.ID,
+			Kind:           p.Kind,
+			FromUser:       p.FromUsername,
+			ToUser:         p.ToUsername,
+			Amount:         p.Amount,
+			Memo:           p.Memo,
+			Category:       p.Category,
+			Status:         p.Status,
+			DecisionReason: p.DecisionReason,
+			ExpiresAt:      p.ExpiresAt,
+			CreatedAt:      p.CreatedAt,
+			DecidedAt:      p.DecidedAt,
+		})
+	}
+	return result
+}
+
+func pendingTransferTarget(id int) string {
+	return "pending_transfer:" + strconv.Itoa(id)
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"AVTproject/models"
+)
+
+const (
+	AuditAuthRegister      = "auth.register"
+	AuditAuthLogin         = "auth.login"
+	AuditAuthLoginFailed   = "auth.login_failed"
+	AuditTransfer          = "wallet.transfer"
+	AuditTransferHold      = "wallet.transfer_hold"
+	AuditTransferApprove   = "wallet.transfer_approve"
+	AuditTransferReject    = "wallet.transfer_reject"
+	AuditTransferExpire    = "wallet.transfer_expire"
+	AuditTransferEscrow    = "wallet.transfer_escrow"
+	AuditTransferAccept    = "wallet.transfer_accept"
+	AuditTransferDecline   = "wallet.transfer_decline"
+	AuditTransferUndo      = "wallet.transfer_undo"
+	AuditCredit            = "wallet.credit"
+	AuditPurchase          = "shop.purchase"
+	AuditReturnRequest     = "shop.return_request"
+	AuditReturnApprove     = "shop.return_approve"
+	AuditReturnReject      = "shop.return_reject"
+	AuditBalanceAdjustment = "admin.balance_adjustment"
+	AuditWebhookCreate     = "admin.webhook_create"
+	AuditWebhookDelete     = "admin.webhook_delete"
+	AuditTransferLimits    = "admin.transfer_limits"
+	AuditUserProfile       = "admin.user_profile"
+	AuditCreditLimit       = "admin.credit_limit"
+	AuditItemVariant       = "admin.item_variant"
+	AuditPromoCode         = "admin.promo_code"
+	AuditPriceSchedule     = "admin.price_schedule"
+	AuditGrantPolicy       = "admin.grant_policy"
+	AuditGrantRun          = "admin.grant_run"
+)
+
+type RequestMeta struct {
+	RequestID string
+	IP        string
+}
+
+type requestMetaKey struct{}
+
+func WithRequestMeta(ctx context.Context, meta RequestMeta) context.Context {
+	return context.WithValue(ctx, requestMetaKey{}, meta)
+}
+
+func requestMetaFrom(ctx context.Context) RequestMeta {
+	meta, _ := ctx.Value(requestMetaKey{}).(RequestMeta)
+	return meta
+}
+
+type AuditQuery struct {
+	Actor  string
+	Action string
+	Target string
+	From   time.Time
+	To     time.Time
+	Limit  int
+	Offset int
+}
+
+type AuditEntryInfo struct {
+	ID        int64           `json:"id"`
+	Actor     string          `json:"actor,omitempty"`
+	ActorID   int             `json:"actorId,omitempty"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	RequestID string          `json:"requestId,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+func (s Service) ListAuditLog(ctx context.Context, q AuditQuery) ([]AuditEntryInfo, error) {
+	filter := models.AuditFilter{
+		Action: q.Action,
+		Target: q.Target,
+		From:   q.From,
+		To:     q.To,
+		Limit:  q.Limit,
+		Offset: q.Offset,
+	}
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 100
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	if q.Actor != "" {
+		actor, err := s.repo.GetUserByUsername(ctx, q.Actor)
+		if err != nil {
+			return nil, err
+		}
+		filter.ActorID = actor.ID
+	}
+
+	entries, err := s.repo.ListAuditEntries(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]AuditEntryInfo, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, AuditEntryInfo{
+			ID:        e.ID,
+			Actor:     e.ActorUsername,
+			ActorID:   e.ActorID,
+			Action:    e.Action,
+			Target:    e.Target,
+			Before:    e.Before,
+			After:     e.After,
+			RequestID: e.RequestID,
+			IP:        e.IP,
+			CreatedAt: e.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// audit записывает действие в журнал аудита. Вызывается внутри транзакции
+// изменяющей операции, чтобы запись появлялась только вместе с изменением.
+func (s Service) audit(
+	ctx context.Context,
+	actorID int,
+	action, target string,
+	before, after interface{},
+) error {
+	entry := models.AuditEntry{
+		ActorID: actorID,
+		Action:  action,
+		Target:  target,
+	}
+	var err error
+	if before != nil {
+		if entry.Before, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if entry.After, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+	meta := requestMetaFrom(ctx)
+	entry.RequestID = meta.RequestID
+	entry.IP = meta.IP
+	return s.repo.AddAuditEntry(ctx, entry)
+}
+
+func userTarget(username string) string {
+	return "user:" + username
+}
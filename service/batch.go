@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"AVTproject/models"
+)
+
+const maxBatchRecipients = 100
+
+const (
+	BatchItemFailed  = "failed"
+	BatchItemSkipped = "skipped"
+)
+
+type BatchTransfer struct {
+	ToUser string `json:"toUser"`
+	Amount int    `json:"amount"`
+}
+
+type BatchTransferResult struct {
+	ToUser            string `json:"toUser"`
+	Amount            int    `json:"amount"`
+	Status            string `json:"status"`
+	TransactionID     int    `json:"transactionId,omitempty"`
+	PendingTransferID int    `json:"pendingTransferId,omitempty"`
+	Error             string `json:"error,omitempty"`
+}
+
+// BatchTransferError возвращается, когда пакет откатан целиком; Results
+// содержит итог по каждому получателю.
+type BatchTransferError struct {
+	Message string
+	Results []BatchTransferResult
+}
+
+func (e *BatchTransferError) Error() string {
+	return e.Message
+}
+
+// SendCoinBatch выполняет переводы нескольким получателям в одной транзакции:
+// либо проходят все, либо ни один. Каждый перевод идёт через SendCoin, поэтому
+// лимиты, согласование и уведомления работают так же, как для одиночного.
+func (s Service) SendCoinBatch(
+	ctx context.Context,
+	fromUserID int,
+	transfers []BatchTransfer,
+	opts TransferOptions,
+) ([]BatchTransferResult, error) {
+	if len(transfers) == 0 {
+		return nil, errors.New("не указаны получатели")
+	}
+	if len(transfers) > maxBatchRecipients {
+		return nil, errors.New("слишком много получателей")
+	}
+	if _, err := opts.normalize(); err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchTransferResult, len(transfers))
+	for i, t := range transfers {
+		results[i] = BatchTransferResult{ToUser: t.ToUser, Amount: t.Amount, Status: BatchItemSkipped}
+	}
+
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		ids := []int{fromUserID}
+		seen := make(map[string]bool, len(transfers))
+		total, failed := 0, false
+		for i, t := range transfers {
+			receiver, err := s.validateBatchTransfer(ctx, fromUserID, t, seen)
+			if err != nil {
+				results[i].Status, results[i].Error = BatchItemFailed, err.Error()
+				failed = true
+				continue
+			}
+			ids = append(ids, receiver.ID)
+			total += t.Amount
+		}
+		if failed {
+			return &BatchTransferError{Message: "пакет содержит ошибки", Results: results}
+		}
+
+		if err := s.repo.LockUsers(ctx, ids...); err != nil {
+			return err
+		}
+		sender, err := s.repo.GetUserByID(ctx, fromUserID)
+		if err != nil {
+			return err
+		}
+		if sender.Coins < total {
+			return errors.New("недостаточно монет")
+		}
+
+		for i, t := range transfers {
+			result, err := s.SendCoin(ctx, fromUserID, t.ToUser, t.Amount, opts)
+			if err != nil {
+				results[i].Status, results[i].Error = BatchItemFailed, err.Error()
+				return &BatchTransferError{Message: "перевод пользователю " + t.ToUser + " не выполнен", Results: results}
+			}
+			results[i].Status = result.Status
+			results[i].TransactionID = result.TransactionID
+			results[i].PendingTransferID = result.PendingTransferID
+		}
+		return nil
+	})
+	if err != nil {
+		var batchErr *BatchTransferError
+		if errors.As(err, &batchErr) {
+			for i := range batchErr.Results {
+				if batchErr.Results[i].Status != BatchItemFailed {
+					batchErr.Results[i] = BatchTransferResult{
+						ToUser: transfers[i].ToUser,
+						Amount: transfers[i].Amount,
+						Status: BatchItemSkipped,
+					}
+				}
+			}
+		}
+		return nil, err
+	}
+	return results, nil
+}
+
+func (s Service) validateBatchTransfer(
+	ctx context.Context,
+	fromUserID int,
+	t BatchTransfer,
+	seen map[string]bool,
+) (models.User, error) {
+	if t.ToUser == "" || t.Amount <= 0 {
+		return models.User{}, errors.New("неверные параметры перевода")
+	}
+	if seen[t.ToUser] {
+		return models.User{}, errors.New("получатель указан повторно")
+	}
+	seen[t.ToUser] = true
+	receiver, err := s.repo.GetUserByUsername(ctx, t.ToUser)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, errors.New("пользователь не найден")
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+	if receiver.ID == fromUserID {
+		return models.User{}, errors.New("нельзя перевести монеты самому себе")
+	}
+	return receiver, nil
+}
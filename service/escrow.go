@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"AVTproject/models"
+)
+
+// WithTransferAcceptance задаёт срок, в течение которого получатель должен
+// подтвердить перевод. После него монеты возвращаются отправителю.
+func WithTransferAcceptance(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.acceptanceTTL = ttl
+	}
+}
+
+func (s Service) ListIncomingTransfers(ctx context.Context, userID int) ([]PendingTransferInfo, error) {
+	transfers, err := s.repo.ListPendingTransfers(ctx, models.PendingTransferFilter{
+		ToUserID: userID,
+		Kind:     models.PendingKindEscrow,
+		Status:   models.PendingStatusPending,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return toPendingTransferInfos(transfers), nil
+}
+
+func (s Service) AcceptTransfer(ctx context.Context, userID, id int) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		p, err := s.escrowForRecipient(ctx, userID, id)
+		if err != nil {
+			return err
+		}
+		if time.Now().After(p.ExpiresAt) {
+			return errors.New("срок подтверждения перевода истёк")
+		}
+		if err := s.repo.LockUsers(ctx, p.FromUserID, p.ToUserID); err != nil {
+			return err
+		}
+		sender, err := s.repo.GetUserByID(ctx, p.FromUserID)
+		if err != nil {
+			return err
+		}
+		receiver, err := s.repo.GetUserByID(ctx, p.ToUserID)
+		if err != nil {
+			return err
+		}
+		lots, err := s.repo.TakeParkedCoinLots(ctx, p.ID)
+		if err != nil {
+			return err
+		}
+		_, transactionID, err := s.settleTransfer(ctx, sender, receiver, p.Amount, lots, TransferOptions{
+			Memo:     p.Memo,
+			Category: p.Category,
+			Public:   p.Public,
+		})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		p.Status = models.PendingStatusAccepted
+		p.TransactionID = transactionID
+		p.DecidedAt = &now
+		if err := s.repo.UpdatePendingTransfer(ctx, p); err != nil {
+			return err
+		}
+		if err := s.completeCoinRequestPayment(ctx, p.ID, transactionID); err != nil {
+			return err
+		}
+		if err := s.audit(
+			ctx,
+			userID,
+			AuditTransferAccept,
+			pendingTransferTarget(p.ID),
+			map[string]string{"status": models.PendingStatusPending},
+			map[string]interface{}{"status": p.Status, "transactionId": transactionID},
+		); err != nil {
+			return err
+		}
+		return s.notifyTransferStatus(ctx, p)
+	})
+}
+
+func (s Service) DeclineTransfer(ctx context.Context, userID, id int) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		p, err := s.escrowForRecipient(ctx, userID, id)
+		if err != nil {
+			return err
+		}
+		return s.releaseTransfer(ctx, p, models.PendingStatusDeclined, userID, AuditTransferDecline)
+	})
+}
+
+// escrowForRecipient блокирует перевод и проверяет, что он адресован
+// пользователю. Чужие переводы для него не существуют.
+func (s Service) escrowForRecipient(ctx context.Context, userID, id int) (models.PendingTransfer, error) {
+	p, err := s.repo.GetPendingTransferForUpdate(ctx, id)
+	if err != nil {
+		return models.PendingTransfer{}, err
+	}
+	if p.Kind != models.PendingKindEscrow || p.ToUserID != userID {
+		return models.PendingTransfer{}, sql.ErrNoRows
+	}
+	if p.Status != models.PendingStatusPending {
+		return models.PendingTransfer{}, errors.New("перевод уже обработан")
+	}
+	return p, nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"AVTproject/models"
+)
+
+type CoinExpiration struct {
+	Amount    int       `json:"amount"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// ExpireCoins списывает остатки истёкших партий. Пользователь блокируется до
+// подсчёта остатка, поэтому параллельные экземпляры не спишут его дважды.
+// Списание идёт через UpdateUserCoins: истёкшие партии сгорают раньше
+// остальных и расходуются первыми. Ошибка у одного пользователя не мешает
+// списанию у остальных.
+func (s Service) ExpireCoins(ctx context.Context) error {
+	now := time.Now()
+	userIDs, err := s.repo.ListUsersWithExpiredCoinLots(ctx, now, 100)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, userID := range userIDs {
+		if err := s.expireUserCoins(ctx, userID, now); err != nil {
+			log.Printf("Ошибка списания сгоревших монет пользователя %d: %v", userID, err)
+			errs = append(errs, fmt.Errorf("пользователь %d: %w", userID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s Service) expireUserCoins(ctx context.Context, userID int, now time.Time) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.LockUsers(ctx, userID); err != nil {
+			return err
+		}
+		amount, err := s.repo.SumExpiredCoinLots(ctx, userID, now)
+		if err != nil || amount == 0 {
+			return err
+		}
+		coins, err := s.repo.UpdateUserCoins(ctx, userID, -amount)
+		if err != nil {
+			return err
+		}
+		if _, err := s.repo.AddTransaction(ctx, models.Transaction{
+			FromUserID: userID,
+			Amount:     amount,
+			Type:       models.TransactionTypeExpiry,
+			Reason:     "Истёк срок действия монет",
+		}); err != nil {
+			return err
+		}
+		return s.notifyBalance(ctx, userID, coins, -amount)
+	})
+}
+
+// upcomingExpirations группирует непотраченные партии по дню сгорания.
+func (s Service) upcomingExpirations(ctx context.Context, userID int) ([]CoinExpiration, error) {
+	lots, err := s.repo.ListCoinLots(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	result := []CoinExpiration{}
+	for _, lot := range lots {
+		day := lot.ExpiresAt.Truncate(24 * time.Hour)
+		if n := len(result); n > 0 && result[n-1].ExpiresAt.Equal(day) {
+			result[n-1].Amount += lot.Remaining
+			continue
+		}
+		result = append(result, CoinExpiration{Amount: lot.Remaining, ExpiresAt: day})
+	}
+	return result, nil
+}
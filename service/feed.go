@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"AVTproject/models"
+)
+
+var kudosReactions = map[string]bool{
+	models.ReactionLike:  true,
+	models.ReactionClap:  true,
+	models.ReactionHeart: true,
+	models.ReactionFire:  true,
+	models.ReactionParty: true,
+}
+
+type FeedItem struct {
+	ID          int            `json:"id"`
+	FromUser    string         `json:"fromUser"`
+	ToUser      string         `json:"toUser"`
+	Amount      int            `json:"amount"`
+	Memo        string         `json:"memo,omitempty"`
+	Category    string         `json:"category,omitempty"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	Reactions   map[string]int `json:"reactions"`
+	MyReactions []string       `json:"myReactions"`
+}
+
+func (s Service) GetFeed(ctx context.Context, userID, limit, offset int) ([]FeedItem, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	entries, err := s.repo.ListPublicTransfers(ctx, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]FeedItem, 0, len(entries))
+	byID := make(map[int]int, len(entries))
+	ids := make([]int, 0, len(entries))
+	for _, e := range entries {
+		byID[e.TransactionID] = len(items)
+		ids = append(ids, e.TransactionID)
+		items = append(items, FeedItem{
+			ID:          e.TransactionID,
+			FromUser:    e.FromUsername,
+			ToUser:      e.ToUsername,
+			Amount:      e.Amount,
+			Memo:        e.Memo,
+			Category:    e.Category,
+			CreatedAt:   e.CreatedAt,
+			Reactions:   map[string]int{},
+			MyReactions: []string{},
+		})
+	}
+	if len(ids) == 0 {
+		return items, nil
+	}
+
+	reactions, err := s.repo.ListKudosReactions(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range reactions {
+		i, ok := byID[r.TransactionID]
+		if !ok {
+			continue
+		}
+		items[i].Reactions[r.Reaction]++
+		if r.UserID == userID {
+			items[i].MyReactions = append(items[i].MyReactions, r.Reaction)
+		}
+	}
+	return items, nil
+}
+
+func (s Service) AddReaction(ctx context.Context, userID, transactionID int, reaction string) error {
+	if !kudosReactions[reaction] {
+		return errors.New("неизвестная реакция")
+	}
+	t, err := s.repo.GetTransactionByID(ctx, transactionID)
+	if err != nil {
+		return err
+	}
+	// Непубличные переводы в ленте не видны, поэтому для реакций их не существует.
+	if !t.Public || t.Type != models.TransactionTypeTransfer {
+		return sql.ErrNoRows
+	}
+	return s.repo.AddKudosReaction(ctx, models.KudosReaction{
+		TransactionID: transactionID,
+		UserID:        userID,
+		Reaction:      reaction,
+	})
+}
+
+func (s Service) RemoveReaction(ctx context.Context, userID, transactionID int, reaction string) error {
+	if !kudosReactions[reaction] {
+		return errors.New("неизвестная реакция")
+	}
+	return s.repo.RemoveKudosReaction(ctx, models.KudosReaction{
+		TransactionID: transactionID,
+		UserID:        userID,
+		Reaction:      reaction,
+	})
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"AVTproject/models"
+)
+
+const maxGiftMessageLength = 200
+
+type GiftReceivedNotification struct {
+	FromUser string `json:"fromUser"`
+	Item     string `json:"item"`
+	Size     string `json:"size,omitempty"`
+	Color    string `json:"color,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// giftRecipient проверяет параметры подарка и возвращает получателя. Для
+// обычной покупки возвращает нулевого пользователя.
+func (s Service) giftRecipient(ctx context.Context, buyerID int, opts *PurchaseOptions) (models.User, error) {
+	opts.GiftTo = strings.TrimSpace(opts.GiftTo)
+	// Сообщение показывается получателю, поэтому чистится так же, как
+	// комментарий к переводу.
+	opts.GiftMessage = sanitizeMemo(opts.GiftMessage)
+	if opts.GiftTo == "" {
+		if opts.GiftMessage != "" {
+			return models.User{}, errors.New("сообщение можно добавить только к подарку")
+		}
+		return models.User{}, nil
+	}
+	if utf8.RuneCountInString(opts.GiftMessage) > maxGiftMessageLength {
+		return models.User{}, errors.New("слишком длинное сообщение к подарку")
+	}
+	recipient, err := s.repo.GetUserByUsername(ctx, opts.GiftTo)
+	if err != nil {
+		return models.User{}, err
+	}
+	if recipient.ID == buyerID {
+		return models.User{}, errors.New("нельзя подарить мерч самому себе")
+	}
+	return recipient, nil
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"AVTproject/models"
+)
+
+const maxGrantNameLength = 100
+
+type GrantPolicyRequest struct {
+	Name       string
+	Amount     int
+	Interval   string
+	Role       string
+	Department string
+}
+
+type GrantPolicyInfo struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	Amount     int       `json:"amount"`
+	Interval   string    `json:"interval"`
+	Role       string    `json:"role,omitempty"`
+	Department string    `json:"department,omitempty"`
+	Active     bool      `json:"active"`
+	LastPeriod string    `json:"lastPeriod,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// GrantPreview показывает, кому и сколько будет начислено за текущий период.
+type GrantPreview struct {
+	PolicyID       int      `json:"policyId"`
+	Period         string   `json:"period"`
+	AlreadyGranted bool     `json:"alreadyGranted"`
+	Amount         int      `json:"amount"`
+	Recipients     []string `json:"recipients"`
+	Total          int      `json:"total"`
+}
+
+type GrantRunInfo struct {
+	PolicyID   int    `json:"policyId"`
+	Period     string `json:"period"`
+	Granted    bool   `json:"granted"`
+	Recipients int    `json:"recipients"`
+	Total      int    `json:"total"`
+}
+
+func (s Service) CreateGrantPolicy(ctx context.Context, actorID int, req GrantPolicyRequest) (GrantPolicyInfo, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Department = strings.TrimSpace(req.Department)
+	if req.Name == "" || utf8.RuneCountInString(req.Name) > maxGrantNameLength {
+		return GrantPolicyInfo{}, errors.New("необходимо указать название начисления")
+	}
+	if req.Amount <= 0 {
+		return GrantPolicyInfo{}, errors.New("сумма начисления должна быть положительной")
+	}
+	if req.Interval != models.IntervalMonthly && req.Interval != models.IntervalWeekly {
+		return GrantPolicyInfo{}, errors.New("неизвестная периодичность начисления")
+	}
+	switch req.Role {
+	case "", models.RoleUser, models.RoleManager, models.RoleAdmin:
+	default:
+		return GrantPolicyInfo{}, errors.New("неизвестная роль")
+	}
+
+	p := models.GrantPolicy{
+		Name:       req.Name,
+		Amount:     req.Amount,
+		Interval:   req.Interval,
+		Role:       req.Role,
+		Department: req.Department,
+		Active:     true,
+		CreatedAt:  time.Now(),
+	}
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if p.ID, err = s.repo.CreateGrantPolicy(ctx, p); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, AuditGrantPolicy, grantPolicyTarget(p.ID), nil, toGrantPolicyInfo(p))
+	})
+	if err != nil {
+		return GrantPolicyInfo{}, err
+	}
+	return toGrantPolicyInfo(p), nil
+}
+
+func (s Service) ListGrantPolicies(ctx context.Context) ([]GrantPolicyInfo, error) {
+	policies, err := s.repo.ListGrantPolicies(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]GrantPolicyInfo, 0, len(policies))
+	for _, p := range policies {
+		result = append(result, toGrantPolicyInfo(p))
+	}
+	return result, nil
+}
+
+func (s Service) DeactivateGrantPolicy(ctx context.Context, actorID, id int) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeactivateGrantPolicy(ctx, id); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, AuditGrantPolicy, grantPolicyTarget(id), nil, map[string]bool{"active": false})
+	})
+}
+
+// PreviewGrant выполняет начисление «вхолостую»: ничего не записывает и
+// показывает получателей и сумму за текущий период.
+func (s Service) PreviewGrant(ctx context.Context, id int) (GrantPreview, error) {
+	p, err := s.repo.GetGrantPolicy(ctx, id)
+	if err != nil {
+		return GrantPreview{}, err
+	}
+	recipients, err := s.grantRecipients(ctx, p)
+	if err != nil {
+		return GrantPreview{}, err
+	}
+	period := grantPeriod(p.Interval, time.Now())
+	preview := GrantPreview{
+		PolicyID:       p.ID,
+		Period:         period,
+		AlreadyGranted: p.LastPeriod == period,
+		Amount:         p.Amount,
+		Recipients:     make([]string, 0, len(recipients)),
+		Total:          p.Amount * len(recipients),
+	}
+	for _, u := range recipients {
+		preview.Recipients = append(preview.Recipients, u.Username)
+	}
+	return preview, nil
+}
+
+// RunGrant начисляет монеты по политике за текущий период, не дожидаясь
+// планировщика. Повторный запуск в том же периоде ничего не начисляет.
+func (s Service) RunGrant(ctx context.Context, actorID, id int) (GrantRunInfo, error) {
+	p, err := s.repo.GetGrantPolicy(ctx, id)
+	if err != nil {
+		return GrantRunInfo{}, err
+	}
+	if !p.Active {
+		return GrantRunInfo{}, errors.New("начисление отключено")
+	}
+	return s.runGrant(ctx, actorID, p, time.Now())
+}
+
+// RunDueGrants начисляет монеты по всем активным политикам, для которых
+// ещё не было начисления в текущем периоде.
+func (s Service) RunDueGrants(ctx context.Context) error {
+	policies, err := s.repo.ListGrantPolicies(ctx, true)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	var errs []error
+	for _, p := range policies {
+		if p.LastPeriod == grantPeriod(p.Interval, now) {
+			continue
+		}
+		if _, err := s.runGrant(ctx, 0, p, now); err != nil {
+			errs = append(errs, fmt.Errorf("начисление %d: %w", p.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s Service) runGrant(ctx context.Context, actorID int, p models.GrantPolicy, now time.Time) (GrantRunInfo, error) {
+	info := GrantRunInfo{PolicyID: p.ID, Period: grantPeriod(p.Interval, now)}
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		recipients, err := s.grantRecipients(ctx, p)
+		if err != nil {
+			return err
+		}
+		run := models.GrantRun{
+			PolicyID:   p.ID,
+			Period:     info.Period,
+			Recipients: len(recipients),
+			Total:      p.Amount * len(recipients),
+			ActorID:    actorID,
+		}
+		_, created, err := s.repo.CreateGrantRun(ctx, run)
+		if err != nil || !created {
+			return err
+		}
+
+		reference := "grant:" + strconv.Itoa(p.ID) + ":" + info.Period
+		for _, u := range recipients {
+			coins, err := s.repo.UpdateUserCoins(ctx, u.ID, p.Amount)
+			if err != nil {
+				return err
+			}
+			if _, err := s.repo.AddTransaction(ctx, models.Transaction{
+				ToUserID:  u.ID,
+				Amount:    p.Amount,
+				Type:      models.TransactionTypeGrant,
+				Reason:    p.Name,
+				Reference: reference,
+				ActorID:   actorID,
+			}); err != nil {
+				return err
+			}
+			if err := s.notifyBalance(ctx, u.ID, coins, p.Amount); err != nil {
+				return err
+			}
+		}
+		info.Granted = true
+		info.Recipients = run.Recipients
+		info.Total = run.Total
+		return s.audit(ctx, actorID, AuditGrantRun, grantPolicyTarget(p.ID), nil, info)
+	})
+	if err != nil {
+		return GrantRunInfo{}, err
+	}
+	return info, nil
+}
+
+func (s Service) grantRecipients(ctx context.Context, p models.GrantPolicy) ([]models.User, error) {
+	users, err := s.repo.ListGrantRecipients(ctx, p.Department)
+	if err != nil {
+		return nil, err
+	}
+	if p.Role == "" {
+		return users, nil
+	}
+	var recipients []models.User
+	for _, u := range users {
+		if s.roleOf(u) == p.Role {
+			recipients = append(recipients, u)
+		}
+	}
+	return recipients, nil
+}
+
+// grantPeriod возвращает ключ периода: месяц «2006-01» или ISO-неделю «2006-W01».
+func grantPeriod(interval string, t time.Time) string {
+	t = t.UTC()
+	if interval == models.IntervalWeekly {
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return t.Format("2006-01")
+}
+
+func grantPolicyTarget(id int) string {
+	return "grant_policy:" + strconv.Itoa(id)
+}
+
+func toGrantPolicyInfo(p models.GrantPolicy) GrantPolicyInfo {
+	return GrantPolicyInfo{
+		ID:         p.ID,
+		Name:       p.Name,
+		Amount:     p.Amount,
+		Interval:   p.Interval,
+		Role:       p.Role,
+		Department: p.Department,
+		Active:     p.Active,
+		LastPeriod: p.LastPeriod,
+		CreatedAt:  p.CreatedAt,
+	}
+}
@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"AVTproject/models"
+)
+
+// TransferLimits — действующие лимиты переводов. Нулевое значение означает
+// отсутствие ограничения.
+type TransferLimits struct {
+	PerTransfer      int `json:"perTransfer,omitempty"`
+	PerDay           int `json:"perDay,omitempty"`
+	PerMonth         int `json:"perMonth,omitempty"`
+	RecipientsPerDay int `json:"recipientsPerDay,omitempty"`
+}
+
+// TransferAllowance — остаток лимитов пользователя. Отсутствующее поле
+// означает, что соответствующего ограничения нет.
+type TransferAllowance struct {
+	PerTransfer         *int `json:"perTransfer,omitempty"`
+	RemainingToday      *int `json:"remainingToday,omitempty"`
+	RemainingThisMonth  *int `json:"remainingThisMonth,omitempty"`
+	RecipientsLeftToday *int `json:"recipientsLeftToday,omitempty"`
+}
+
+func WithTransferLimits(limits TransferLimits) Option {
+	return func(s *Service) {
+		s.transferLimits = limits
+	}
+}
+
+func (s Service) effectiveTransferLimits(ctx context.Context, user models.User) (TransferLimits, error) {
+	override, err := s.repo.GetTransferLimitOverride(ctx, user.ID, s.roleOf(user))
+	if err != nil {
+		return TransferLimits{}, err
+	}
+	limits := s.transferLimits
+	overrideLimit(&limits.PerTransfer, override.PerTransfer)
+	overrideLimit(&limits.PerDay, override.PerDay)
+	overrideLimit(&limits.PerMonth, override.PerMonth)
+	overrideLimit(&limits.RecipientsPerDay, override.RecipientsPerDay)
+	return limits, nil
+}
+
+func overrideLimit(limit *int, override *int) {
+	if override != nil {
+		*limit = *override
+	}
+}
+
+func (s Service) transferUsage(ctx context.Context, userID int) (models.TransferUsage, error) {
+	now := time.Now()
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	return s.repo.GetTransferUsage(ctx, userID, dayStart, monthStart)
+}
+
+// checkTransferLimits вызывается внутри транзакции перевода после блокировки
+// отправителя, поэтому параллельные переводы не могут обойти лимиты.
+func (s Service) checkTransferLimits(ctx context.Context, sender models.User, receiverID, amount int) error {
+	limits, err := s.effectiveTransferLimits(ctx, sender)
+	if err != nil {
+		return err
+	}
+	if limits.PerTransfer > 0 && amount > limits.PerTransfer {
+		return errors.New("превышен лимит на один перевод")
+	}
+	if limits.PerDay == 0 && limits.PerMonth == 0 && limits.RecipientsPerDay == 0 {
+		return nil
+	}
+
+	usage, err := s.transferUsage(ctx, sender.ID)
+	if err != nil {
+		return err
+	}
+	if limits.PerDay > 0 && usage.SentToday+amount > limits.PerDay {
+		return errors.New("превышен дневной лимит переводов")
+	}
+	if limits.PerMonth > 0 && usage.SentThisMonth+amount > limits.PerMonth {
+		return errors.New("превышен месячный лимит переводов")
+	}
+	if limits.RecipientsPerDay > 0 && !containsID(usage.RecipientsToday, receiverID) &&
+		len(usage.RecipientsToday) >= limits.RecipientsPerDay {
+		return errors.New("превышено количество получателей за день")
+	}
+	return nil
+}
+
+func (s Service) transferAllowance(ctx context.Context, user models.User) (TransferAllowance, error) {
+	limits, err := s.effectiveTransferLimits(ctx, user)
+	if err != nil {
+		return TransferAllowance{}, err
+	}
+	var allowance TransferAllowance
+	if limits.PerTransfer > 0 {
+		allowance.PerTransfer = intPtr(limits.PerTransfer)
+	}
+	if limits.PerDay == 0 && limits.PerMonth == 0 && limits.RecipientsPerDay == 0 {
+		return allowance, nil
+	}
+
+	usage, err := s.transferUsage(ctx, user.ID)
+	if err != nil {
+		return TransferAllowance{}, err
+	}
+	if limits.PerDay > 0 {
+		allowance.RemainingToday = intPtr(max(limits.PerDay-usage.SentToday, 0))
+	}
+	if limits.PerMonth > 0 {
+		allowance.RemainingThisMonth = intPtr(max(limits.PerMonth-usage.SentThisMonth, 0))
+	}
+	if limits.RecipientsPerDay > 0 {
+		allowance.RecipientsLeftToday = intPtr(max(limits.RecipientsPerDay-len(usage.RecipientsToday), 0))
+	}
+	return allowance, nil
+}
+
+func (s Service) GetUserTransferLimits(ctx context.Context, username string) (TransferLimits, error) {
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return TransferLimits{}, err
+	}
+	return s.effectiveTransferLimits(ctx, user)
+}
+
+func (s Service) SetRoleTransferLimits(
+	ctx context.Context,
+	actorID int,
+	role string,
+	override models.TransferLimitOverride,
+) error {
+	switch role {
+	case models.RoleUser, models.RoleManager, models.RoleAdmin:
+	default:
+		return errors.New("неизвестная роль")
+	}
+	if err := validateLimitOverride(override); err != nil {
+		return err
+	}
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.SetRoleTransferLimits(ctx, role, override); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, AuditTransferLimits, "role:"+role, nil, limitOverrideView(override))
+	})
+}
+
+func (s Service) SetUserTransferLimits(
+	ctx context.Context,
+	actorID int,
+	username string,
+	override models.TransferLimitOverride,
+) error {
+	if err := validateLimitOverride(override); err != nil {
+		return err
+	}
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		user, err := s.repo.GetUserByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.SetUserTransferLimits(ctx, user.ID, override); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, AuditTransferLimits, userTarget(username), nil, limitOverrideView(override))
+	})
+}
+
+func validateLimitOverride(o models.TransferLimitOverride) error {
+	for _, v := range []*int{o.PerTransfer, o.PerDay, o.PerMonth, o.RecipientsPerDay} {
+		if v != nil && *v < 0 {
+			return errors.New("лимит не может быть отрицательным")
+		}
+	}
+	return nil
+}
+
+func limitOverrideView(o models.TransferLimitOverride) map[string]*int {
+	return map[string]*int{
+		"perTransfer":      o.PerTransfer,
+		"perDay":           o.PerDay,
+		"perMonth":         o.PerMonth,
+		"recipientsPerDay": o.RecipientsPerDay,
+	}
+}
+
+func containsID(ids []int, id int) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -8,6 +8,7 @@ import (
 	models "AVTproject/models"
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 )
@@ -35,32 +36,316 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
-// AddPurchase mocks base method.
-func (m *MockRepository) AddPurchase(arg0 context.Context, arg1 int, arg2 string) error {
+// AddAuditEntry mocks base method.
+func (m *MockRepository) AddAuditEntry(arg0 context.Context, arg1 models.AuditEntry) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAuditEntry", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAuditEntry indicates an expected call of AddAuditEntry.
+func (mr *MockRepositoryMockRecorder) AddAuditEntry(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAuditEntry", reflect.TypeOf((*MockRepository)(nil).AddAuditEntry), arg0, arg1)
+}
+
+// AddKudosReaction mocks base method.
+func (m *MockRepository) AddKudosReaction(arg0 context.Context, arg1 models.KudosReaction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddKudosReaction", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddKudosReaction indicates an expected call of AddKudosReaction.
+func (mr *MockRepositoryMockRecorder) AddKudosReaction(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddKudosReaction", reflect.TypeOf((*MockRepository)(nil).AddKudosReaction), arg0, arg1)
+}
+
+// AddOutboxEvent mocks base method.
+func (m *MockRepository) AddOutboxEvent(arg0 context.Context, arg1 models.OutboxEvent) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddOutboxEvent", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddOutboxEvent indicates an expected call of AddOutboxEvent.
+func (mr *MockRepositoryMockRecorder) AddOutboxEvent(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddOutboxEvent", reflect.TypeOf((*MockRepository)(nil).AddOutboxEvent), arg0, arg1)
+}
+
+// AddPromoRedemption mocks base method.
+func (m *MockRepository) AddPromoRedemption(arg0 context.Context, arg1 models.PromoRedemption) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddPurchase", arg0, arg1, arg2)
+	ret := m.ctrl.Call(m, "AddPromoRedemption", arg0, arg1)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// AddPromoRedemption indicates an expected call of AddPromoRedemption.
+func (mr *MockRepositoryMockRecorder) AddPromoRedemption(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPromoRedemption", reflect.TypeOf((*MockRepository)(nil).AddPromoRedemption), arg0, arg1)
+}
+
+// AddPurchase mocks base method.
+func (m *MockRepository) AddPurchase(arg0 context.Context, arg1 models.Purchase) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddPurchase", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // AddPurchase indicates an expected call of AddPurchase.
-func (mr *MockRepositoryMockRecorder) AddPurchase(arg0, arg1, arg2 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) AddPurchase(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPurchase", reflect.TypeOf((*MockRepository)(nil).AddPurchase), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPurchase", reflect.TypeOf((*MockRepository)(nil).AddPurchase), arg0, arg1)
 }
 
-// AddTransaction mocks base method.
-func (m *MockRepository) AddTransaction(arg0 context.Context, arg1, arg2, arg3 int) error {
+// AddPurchaseCoinLots mocks base method.
+func (m *MockRepository) AddPurchaseCoinLots(arg0 context.Context, arg1 int, arg2 []models.CoinLot) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddTransaction", arg0, arg1, arg2, arg3)
+	ret := m.ctrl.Call(m, "AddPurchaseCoinLots", arg0, arg1, arg2)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// AddPurchaseCoinLots indicates an expected call of AddPurchaseCoinLots.
+func (mr *MockRepositoryMockRecorder) AddPurchaseCoinLots(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddPurchaseCoinLots", reflect.TypeOf((*MockRepository)(nil).AddPurchaseCoinLots), arg0, arg1, arg2)
+}
+
+// AddTransaction mocks base method.
+func (m *MockRepository) AddTransaction(arg0 context.Context, arg1 models.Transaction) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddTransaction", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // AddTransaction indicates an expected call of AddTransaction.
-func (mr *MockRepositoryMockRecorder) AddTransaction(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) AddTransaction(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTransaction", reflect.TypeOf((*MockRepository)(nil).AddTransaction), arg0, arg1)
+}
+
+// AdjustUserCoins mocks base method.
+func (m *MockRepository) AdjustUserCoins(arg0 context.Context, arg1, arg2 int, arg3 bool) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AdjustUserCoins", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AdjustUserCoins indicates an expected call of AdjustUserCoins.
+func (mr *MockRepositoryMockRecorder) AdjustUserCoins(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AdjustUserCoins", reflect.TypeOf((*MockRepository)(nil).AdjustUserCoins), arg0, arg1, arg2, arg3)
+}
+
+// ChargeUserCoins mocks base method.
+func (m *MockRepository) ChargeUserCoins(arg0 context.Context, arg1, arg2, arg3 int) (int, []models.CoinLot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ChargeUserCoins", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].([]models.CoinLot)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// ChargeUserCoins indicates an expected call of ChargeUserCoins.
+func (mr *MockRepositoryMockRecorder) ChargeUserCoins(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ChargeUserCoins", reflect.TypeOf((*MockRepository)(nil).ChargeUserCoins), arg0, arg1, arg2, arg3)
+}
+
+// ClaimDueScheduledTransfers mocks base method.
+func (m *MockRepository) ClaimDueScheduledTransfers(arg0 context.Context, arg1 time.Time, arg2 time.Duration, arg3 int) ([]models.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimDueScheduledTransfers", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].([]models.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimDueScheduledTransfers indicates an expected call of ClaimDueScheduledTransfers.
+func (mr *MockRepositoryMockRecorder) ClaimDueScheduledTransfers(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimDueScheduledTransfers", reflect.TypeOf((*MockRepository)(nil).ClaimDueScheduledTransfers), arg0, arg1, arg2, arg3)
+}
+
+// ClaimExpiredPendingTransfers mocks base method.
+func (m *MockRepository) ClaimExpiredPendingTransfers(arg0 context.Context, arg1 time.Time, arg2 int) ([]models.PendingTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimExpiredPendingTransfers", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.PendingTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimExpiredPendingTransfers indicates an expected call of ClaimExpiredPendingTransfers.
+func (mr *MockRepositoryMockRecorder) ClaimExpiredPendingTransfers(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimExpiredPendingTransfers", reflect.TypeOf((*MockRepository)(nil).ClaimExpiredPendingTransfers), arg0, arg1, arg2)
+}
+
+// CountPromoRedemptions mocks base method.
+func (m *MockRepository) CountPromoRedemptions(arg0 context.Context, arg1, arg2 int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountPromoRedemptions", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountPromoRedemptions indicates an expected call of CountPromoRedemptions.
+func (mr *MockRepositoryMockRecorder) CountPromoRedemptions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountPromoRedemptions", reflect.TypeOf((*MockRepository)(nil).CountPromoRedemptions), arg0, arg1, arg2)
+}
+
+// CreateBillSplit mocks base method.
+func (m *MockRepository) CreateBillSplit(arg0 context.Context, arg1 models.BillSplit) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateBillSplit", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateBillSplit indicates an expected call of CreateBillSplit.
+func (mr *MockRepositoryMockRecorder) CreateBillSplit(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateBillSplit", reflect.TypeOf((*MockRepository)(nil).CreateBillSplit), arg0, arg1)
+}
+
+// CreateCoinRequest mocks base method.
+func (m *MockRepository) CreateCoinRequest(arg0 context.Context, arg1 models.CoinRequest) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateCoinRequest", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateCoinRequest indicates an expected call of CreateCoinRequest.
+func (mr *MockRepositoryMockRecorder) CreateCoinRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateCoinRequest", reflect.TypeOf((*MockRepository)(nil).CreateCoinRequest), arg0, arg1)
+}
+
+// CreateGrantPolicy mocks base method.
+func (m *MockRepository) CreateGrantPolicy(arg0 context.Context, arg1 models.GrantPolicy) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateGrantPolicy", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateGrantPolicy indicates an expected call of CreateGrantPolicy.
+func (mr *MockRepositoryMockRecorder) CreateGrantPolicy(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateGrantPolicy", reflect.TypeOf((*MockRepository)(nil).CreateGrantPolicy), arg0, arg1)
+}
+
+// CreateGrantRun mocks base method.
+func (m *MockRepository) CreateGrantRun(arg0 context.Context, arg1 models.GrantRun) (int, bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateGrantRun", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(bool)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// CreateGrantRun indicates an expected call of CreateGrantRun.
+func (mr *MockRepositoryMockRecorder) CreateGrantRun(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateGrantRun", reflect.TypeOf((*MockRepository)(nil).CreateGrantRun), arg0, arg1)
+}
+
+// CreatePendingTransfer mocks base method.
+func (m *MockRepository) CreatePendingTransfer(arg0 context.Context, arg1 models.PendingTransfer) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePendingTransfer", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePendingTransfer indicates an expected call of CreatePendingTransfer.
+func (mr *MockRepositoryMockRecorder) CreatePendingTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePendingTransfer", reflect.TypeOf((*MockRepository)(nil).CreatePendingTransfer), arg0, arg1)
+}
+
+// CreatePriceSchedule mocks base method.
+func (m *MockRepository) CreatePriceSchedule(arg0 context.Context, arg1 models.PriceSchedule) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePriceSchedule", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePriceSchedule indicates an expected call of CreatePriceSchedule.
+func (mr *MockRepositoryMockRecorder) CreatePriceSchedule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePriceSchedule", reflect.TypeOf((*MockRepository)(nil).CreatePriceSchedule), arg0, arg1)
+}
+
+// CreatePromoCode mocks base method.
+func (m *MockRepository) CreatePromoCode(arg0 context.Context, arg1 models.PromoCode) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePromoCode", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePromoCode indicates an expected call of CreatePromoCode.
+func (mr *MockRepositoryMockRecorder) CreatePromoCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePromoCode", reflect.TypeOf((*MockRepository)(nil).CreatePromoCode), arg0, arg1)
+}
+
+// CreatePurchaseReturn mocks base method.
+func (m *MockRepository) CreatePurchaseReturn(arg0 context.Context, arg1 models.PurchaseReturn) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreatePurchaseReturn", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreatePurchaseReturn indicates an expected call of CreatePurchaseReturn.
+func (mr *MockRepositoryMockRecorder) CreatePurchaseReturn(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreatePurchaseReturn", reflect.TypeOf((*MockRepository)(nil).CreatePurchaseReturn), arg0, arg1)
+}
+
+// CreateScheduledTransfer mocks base method.
+func (m *MockRepository) CreateScheduledTransfer(arg0 context.Context, arg1 models.ScheduledTransfer) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateScheduledTransfer", arg0, arg1)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateScheduledTransfer indicates an expected call of CreateScheduledTransfer.
+func (mr *MockRepositoryMockRecorder) CreateScheduledTransfer(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddTransaction", reflect.TypeOf((*MockRepository)(nil).AddTransaction), arg0, arg1, arg2, arg3)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateScheduledTransfer", reflect.TypeOf((*MockRepository)(nil).CreateScheduledTransfer), arg0, arg1)
 }
 
 // CreateUser mocks base method.
@@ -78,77 +363,979 @@ func (mr *MockRepositoryMockRecorder) CreateUser(arg0, arg1, arg2 interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateUser", reflect.TypeOf((*MockRepository)(nil).CreateUser), arg0, arg1, arg2)
 }
 
-// GetUserByID mocks base method.
-func (m *MockRepository) GetUserByID(arg0 context.Context, arg1 int) (models.User, error) {
+// CreateWebhookSubscription mocks base method.
+func (m *MockRepository) CreateWebhookSubscription(arg0 context.Context, arg1 models.WebhookSubscription) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByID", arg0, arg1)
-	ret0, _ := ret[0].(models.User)
+	ret := m.ctrl.Call(m, "CreateWebhookSubscription", arg0, arg1)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserByID indicates an expected call of GetUserByID.
-func (mr *MockRepositoryMockRecorder) GetUserByID(arg0, arg1 interface{}) *gomock.Call {
+// CreateWebhookSubscription indicates an expected call of CreateWebhookSubscription.
+func (mr *MockRepositoryMockRecorder) CreateWebhookSubscription(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockRepository)(nil).GetUserByID), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateWebhookSubscription", reflect.TypeOf((*MockRepository)(nil).CreateWebhookSubscription), arg0, arg1)
 }
 
-// GetUserByUsername mocks base method.
-func (m *MockRepository) GetUserByUsername(arg0 context.Context, arg1 string) (models.User, error) {
+// DeactivateGrantPolicy mocks base method.
+func (m *MockRepository) DeactivateGrantPolicy(arg0 context.Context, arg1 int) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserByUsername", arg0, arg1)
-	ret0, _ := ret[0].(models.User)
+	ret := m.ctrl.Call(m, "DeactivateGrantPolicy", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeactivateGrantPolicy indicates an expected call of DeactivateGrantPolicy.
+func (mr *MockRepositoryMockRecorder) DeactivateGrantPolicy(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeactivateGrantPolicy", reflect.TypeOf((*MockRepository)(nil).DeactivateGrantPolicy), arg0, arg1)
+}
+
+// DeactivatePromoCode mocks base method.
+func (m *MockRepository) DeactivatePromoCode(arg0 context.Context, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeactivatePromoCode", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeactivatePromoCode indicates an expected call of DeactivatePromoCode.
+func (mr *MockRepositoryMockRecorder) DeactivatePromoCode(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeactivatePromoCode", reflect.TypeOf((*MockRepository)(nil).DeactivatePromoCode), arg0, arg1)
+}
+
+// DeactivateWebhookSubscription mocks base method.
+func (m *MockRepository) DeactivateWebhookSubscription(arg0 context.Context, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeactivateWebhookSubscription", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeactivateWebhookSubscription indicates an expected call of DeactivateWebhookSubscription.
+func (mr *MockRepositoryMockRecorder) DeactivateWebhookSubscription(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeactivateWebhookSubscription", reflect.TypeOf((*MockRepository)(nil).DeactivateWebhookSubscription), arg0, arg1)
+}
+
+// DecrementPurchase mocks base method.
+func (m *MockRepository) DecrementPurchase(arg0 context.Context, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecrementPurchase", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DecrementPurchase indicates an expected call of DecrementPurchase.
+func (mr *MockRepositoryMockRecorder) DecrementPurchase(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecrementPurchase", reflect.TypeOf((*MockRepository)(nil).DecrementPurchase), arg0, arg1)
+}
+
+// DeletePriceSchedule mocks base method.
+func (m *MockRepository) DeletePriceSchedule(arg0 context.Context, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePriceSchedule", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeletePriceSchedule indicates an expected call of DeletePriceSchedule.
+func (mr *MockRepositoryMockRecorder) DeletePriceSchedule(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePriceSchedule", reflect.TypeOf((*MockRepository)(nil).DeletePriceSchedule), arg0, arg1)
+}
+
+// DepositUserCoins mocks base method.
+func (m *MockRepository) DepositUserCoins(arg0 context.Context, arg1, arg2 int, arg3 []models.CoinLot) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DepositUserCoins", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserByUsername indicates an expected call of GetUserByUsername.
-func (mr *MockRepositoryMockRecorder) GetUserByUsername(arg0, arg1 interface{}) *gomock.Call {
+// DepositUserCoins indicates an expected call of DepositUserCoins.
+func (mr *MockRepositoryMockRecorder) DepositUserCoins(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByUsername", reflect.TypeOf((*MockRepository)(nil).GetUserByUsername), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DepositUserCoins", reflect.TypeOf((*MockRepository)(nil).DepositUserCoins), arg0, arg1, arg2, arg3)
 }
 
-// GetUserPurchases mocks base method.
-func (m *MockRepository) GetUserPurchases(arg0 context.Context, arg1 int) ([]models.Purchase, error) {
+// ExpireCoinRequests mocks base method.
+func (m *MockRepository) ExpireCoinRequests(arg0 context.Context, arg1 time.Time) ([]models.CoinRequest, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserPurchases", arg0, arg1)
-	ret0, _ := ret[0].([]models.Purchase)
+	ret := m.ctrl.Call(m, "ExpireCoinRequests", arg0, arg1)
+	ret0, _ := ret[0].([]models.CoinRequest)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// GetUserPurchases indicates an expected call of GetUserPurchases.
-func (mr *MockRepositoryMockRecorder) GetUserPurchases(arg0, arg1 interface{}) *gomock.Call {
+// ExpireCoinRequests indicates an expected call of ExpireCoinRequests.
+func (mr *MockRepositoryMockRecorder) ExpireCoinRequests(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserPurchases", reflect.TypeOf((*MockRepository)(nil).GetUserPurchases), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ExpireCoinRequests", reflect.TypeOf((*MockRepository)(nil).ExpireCoinRequests), arg0, arg1)
 }
 
-// GetUserTransactions mocks base method.
-func (m *MockRepository) GetUserTransactions(arg0 context.Context, arg1 int) ([]models.Transaction, []models.Transaction, error) {
+// GetBillSplit mocks base method.
+func (m *MockRepository) GetBillSplit(arg0 context.Context, arg1 int) (models.BillSplit, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserTransactions", arg0, arg1)
-	ret0, _ := ret[0].([]models.Transaction)
-	ret1, _ := ret[1].([]models.Transaction)
-	ret2, _ := ret[2].(error)
-	return ret0, ret1, ret2
+	ret := m.ctrl.Call(m, "GetBillSplit", arg0, arg1)
+	ret0, _ := ret[0].(models.BillSplit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// GetUserTransactions indicates an expected call of GetUserTransactions.
-func (mr *MockRepositoryMockRecorder) GetUserTransactions(arg0, arg1 interface{}) *gomock.Call {
+// GetBillSplit indicates an expected call of GetBillSplit.
+func (mr *MockRepositoryMockRecorder) GetBillSplit(arg0, arg1 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTransactions", reflect.TypeOf((*MockRepository)(nil).GetUserTransactions), arg0, arg1)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetBillSplit", reflect.TypeOf((*MockRepository)(nil).GetBillSplit), arg0, arg1)
 }
 
-// UpdateUserCoins mocks base method.
-func (m *MockRepository) UpdateUserCoins(arg0 context.Context, arg1, arg2 int) error {
+// GetCoinRequestByPaymentForUpdate mocks base method.
+func (m *MockRepository) GetCoinRequestByPaymentForUpdate(arg0 context.Context, arg1, arg2 int) (models.CoinRequest, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "UpdateUserCoins", arg0, arg1, arg2)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "GetCoinRequestByPaymentForUpdate", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.CoinRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// UpdateUserCoins indicates an expected call of UpdateUserCoins.
-func (mr *MockRepositoryMockRecorder) UpdateUserCoins(arg0, arg1, arg2 interface{}) *gomock.Call {
+// GetCoinRequestByPaymentForUpdate indicates an expected call of GetCoinRequestByPaymentForUpdate.
+func (mr *MockRepositoryMockRecorder) GetCoinRequestByPaymentForUpdate(arg0, arg1, arg2 interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserCoins", reflect.TypeOf((*MockRepository)(nil).UpdateUserCoins), arg0, arg1, arg2)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCoinRequestByPaymentForUpdate", reflect.TypeOf((*MockRepository)(nil).GetCoinRequestByPaymentForUpdate), arg0, arg1, arg2)
+}
+
+// GetCoinRequestForUpdate mocks base method.
+func (m *MockRepository) GetCoinRequestForUpdate(arg0 context.Context, arg1 int) (models.CoinRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetCoinRequestForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(models.CoinRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetCoinRequestForUpdate indicates an expected call of GetCoinRequestForUpdate.
+func (mr *MockRepositoryMockRecorder) GetCoinRequestForUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetCoinRequestForUpdate", reflect.TypeOf((*MockRepository)(nil).GetCoinRequestForUpdate), arg0, arg1)
+}
+
+// GetGrantPolicy mocks base method.
+func (m *MockRepository) GetGrantPolicy(arg0 context.Context, arg1 int) (models.GrantPolicy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetGrantPolicy", arg0, arg1)
+	ret0, _ := ret[0].(models.GrantPolicy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetGrantPolicy indicates an expected call of GetGrantPolicy.
+func (mr *MockRepositoryMockRecorder) GetGrantPolicy(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetGrantPolicy", reflect.TypeOf((*MockRepository)(nil).GetGrantPolicy), arg0, arg1)
+}
+
+// GetPendingTransferForUpdate mocks base method.
+func (m *MockRepository) GetPendingTransferForUpdate(arg0 context.Context, arg1 int) (models.PendingTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPendingTransferForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(models.PendingTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPendingTransferForUpdate indicates an expected call of GetPendingTransferForUpdate.
+func (mr *MockRepositoryMockRecorder) GetPendingTransferForUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPendingTransferForUpdate", reflect.TypeOf((*MockRepository)(nil).GetPendingTransferForUpdate), arg0, arg1)
+}
+
+// GetPromoCodeForUpdate mocks base method.
+func (m *MockRepository) GetPromoCodeForUpdate(arg0 context.Context, arg1 string) (models.PromoCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPromoCodeForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(models.PromoCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPromoCodeForUpdate indicates an expected call of GetPromoCodeForUpdate.
+func (mr *MockRepositoryMockRecorder) GetPromoCodeForUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPromoCodeForUpdate", reflect.TypeOf((*MockRepository)(nil).GetPromoCodeForUpdate), arg0, arg1)
+}
+
+// GetPurchaseForUpdate mocks base method.
+func (m *MockRepository) GetPurchaseForUpdate(arg0 context.Context, arg1 int) (models.Purchase, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPurchaseForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(models.Purchase)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPurchaseForUpdate indicates an expected call of GetPurchaseForUpdate.
+func (mr *MockRepositoryMockRecorder) GetPurchaseForUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPurchaseForUpdate", reflect.TypeOf((*MockRepository)(nil).GetPurchaseForUpdate), arg0, arg1)
+}
+
+// GetPurchaseReturnForUpdate mocks base method.
+func (m *MockRepository) GetPurchaseReturnForUpdate(arg0 context.Context, arg1 int) (models.PurchaseReturn, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPurchaseReturnForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(models.PurchaseReturn)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPurchaseReturnForUpdate indicates an expected call of GetPurchaseReturnForUpdate.
+func (mr *MockRepositoryMockRecorder) GetPurchaseReturnForUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPurchaseReturnForUpdate", reflect.TypeOf((*MockRepository)(nil).GetPurchaseReturnForUpdate), arg0, arg1)
+}
+
+// GetScheduledTransferForUpdate mocks base method.
+func (m *MockRepository) GetScheduledTransferForUpdate(arg0 context.Context, arg1 int) (models.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetScheduledTransferForUpdate", arg0, arg1)
+	ret0, _ := ret[0].(models.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetScheduledTransferForUpdate indicates an expected call of GetScheduledTransferForUpdate.
+func (mr *MockRepositoryMockRecorder) GetScheduledTransferForUpdate(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetScheduledTransferForUpdate", reflect.TypeOf((*MockRepository)(nil).GetScheduledTransferForUpdate), arg0, arg1)
+}
+
+// GetTransactionByID mocks base method.
+func (m *MockRepository) GetTransactionByID(arg0 context.Context, arg1 int) (models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransactionByID", arg0, arg1)
+	ret0, _ := ret[0].(models.Transaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransactionByID indicates an expected call of GetTransactionByID.
+func (mr *MockRepositoryMockRecorder) GetTransactionByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransactionByID", reflect.TypeOf((*MockRepository)(nil).GetTransactionByID), arg0, arg1)
+}
+
+// GetTransferLimitOverride mocks base method.
+func (m *MockRepository) GetTransferLimitOverride(arg0 context.Context, arg1 int, arg2 string) (models.TransferLimitOverride, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferLimitOverride", arg0, arg1, arg2)
+	ret0, _ := ret[0].(models.TransferLimitOverride)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferLimitOverride indicates an expected call of GetTransferLimitOverride.
+func (mr *MockRepositoryMockRecorder) GetTransferLimitOverride(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferLimitOverride", reflect.TypeOf((*MockRepository)(nil).GetTransferLimitOverride), arg0, arg1, arg2)
+}
+
+// GetTransferUsage mocks base method.
+func (m *MockRepository) GetTransferUsage(arg0 context.Context, arg1 int, arg2, arg3 time.Time) (models.TransferUsage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTransferUsage", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(models.TransferUsage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTransferUsage indicates an expected call of GetTransferUsage.
+func (mr *MockRepositoryMockRecorder) GetTransferUsage(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTransferUsage", reflect.TypeOf((*MockRepository)(nil).GetTransferUsage), arg0, arg1, arg2, arg3)
+}
+
+// GetUserByID mocks base method.
+func (m *MockRepository) GetUserByID(arg0 context.Context, arg1 int) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByID", arg0, arg1)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByID indicates an expected call of GetUserByID.
+func (mr *MockRepositoryMockRecorder) GetUserByID(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByID", reflect.TypeOf((*MockRepository)(nil).GetUserByID), arg0, arg1)
+}
+
+// GetUserByUsername mocks base method.
+func (m *MockRepository) GetUserByUsername(arg0 context.Context, arg1 string) (models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserByUsername", arg0, arg1)
+	ret0, _ := ret[0].(models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserByUsername indicates an expected call of GetUserByUsername.
+func (mr *MockRepositoryMockRecorder) GetUserByUsername(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserByUsername", reflect.TypeOf((*MockRepository)(nil).GetUserByUsername), arg0, arg1)
+}
+
+// GetUserPurchases mocks base method.
+func (m *MockRepository) GetUserPurchases(arg0 context.Context, arg1 int) ([]models.Purchase, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserPurchases", arg0, arg1)
+	ret0, _ := ret[0].([]models.Purchase)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetUserPurchases indicates an expected call of GetUserPurchases.
+func (mr *MockRepositoryMockRecorder) GetUserPurchases(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserPurchases", reflect.TypeOf((*MockRepository)(nil).GetUserPurchases), arg0, arg1)
+}
+
+// GetUserTransactions mocks base method.
+func (m *MockRepository) GetUserTransactions(arg0 context.Context, arg1 int, arg2 string) ([]models.Transaction, []models.Transaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetUserTransactions", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.Transaction)
+	ret1, _ := ret[1].([]models.Transaction)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// GetUserTransactions indicates an expected call of GetUserTransactions.
+func (mr *MockRepositoryMockRecorder) GetUserTransactions(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserTransactions", reflect.TypeOf((*MockRepository)(nil).GetUserTransactions), arg0, arg1, arg2)
+}
+
+// HasReversal mocks base method.
+func (m *MockRepository) HasReversal(arg0 context.Context, arg1 int) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasReversal", arg0, arg1)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasReversal indicates an expected call of HasReversal.
+func (mr *MockRepositoryMockRecorder) HasReversal(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasReversal", reflect.TypeOf((*MockRepository)(nil).HasReversal), arg0, arg1)
+}
+
+// ListAuditEntries mocks base method.
+func (m *MockRepository) ListAuditEntries(arg0 context.Context, arg1 models.AuditFilter) ([]models.AuditEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListAuditEntries", arg0, arg1)
+	ret0, _ := ret[0].([]models.AuditEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListAuditEntries indicates an expected call of ListAuditEntries.
+func (mr *MockRepositoryMockRecorder) ListAuditEntries(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListAuditEntries", reflect.TypeOf((*MockRepository)(nil).ListAuditEntries), arg0, arg1)
+}
+
+// ListBillSplits mocks base method.
+func (m *MockRepository) ListBillSplits(arg0 context.Context, arg1 int) ([]models.BillSplit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListBillSplits", arg0, arg1)
+	ret0, _ := ret[0].([]models.BillSplit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListBillSplits indicates an expected call of ListBillSplits.
+func (mr *MockRepositoryMockRecorder) ListBillSplits(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListBillSplits", reflect.TypeOf((*MockRepository)(nil).ListBillSplits), arg0, arg1)
+}
+
+// ListCoinLots mocks base method.
+func (m *MockRepository) ListCoinLots(arg0 context.Context, arg1 int) ([]models.CoinLot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCoinLots", arg0, arg1)
+	ret0, _ := ret[0].([]models.CoinLot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCoinLots indicates an expected call of ListCoinLots.
+func (mr *MockRepositoryMockRecorder) ListCoinLots(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCoinLots", reflect.TypeOf((*MockRepository)(nil).ListCoinLots), arg0, arg1)
+}
+
+// ListCoinRequests mocks base method.
+func (m *MockRepository) ListCoinRequests(arg0 context.Context, arg1 models.CoinRequestFilter) ([]models.CoinRequest, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListCoinRequests", arg0, arg1)
+	ret0, _ := ret[0].([]models.CoinRequest)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListCoinRequests indicates an expected call of ListCoinRequests.
+func (mr *MockRepositoryMockRecorder) ListCoinRequests(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListCoinRequests", reflect.TypeOf((*MockRepository)(nil).ListCoinRequests), arg0, arg1)
+}
+
+// ListGrantPolicies mocks base method.
+func (m *MockRepository) ListGrantPolicies(arg0 context.Context, arg1 bool) ([]models.GrantPolicy, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListGrantPolicies", arg0, arg1)
+	ret0, _ := ret[0].([]models.GrantPolicy)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListGrantPolicies indicates an expected call of ListGrantPolicies.
+func (mr *MockRepositoryMockRecorder) ListGrantPolicies(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListGrantPolicies", reflect.TypeOf((*MockRepository)(nil).ListGrantPolicies), arg0, arg1)
+}
+
+// ListGrantRecipients mocks base method.
+func (m *MockRepository) ListGrantRecipients(arg0 context.Context, arg1 string) ([]models.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListGrantRecipients", arg0, arg1)
+	ret0, _ := ret[0].([]models.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListGrantRecipients indicates an expected call of ListGrantRecipients.
+func (mr *MockRepositoryMockRecorder) ListGrantRecipients(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListGrantRecipients", reflect.TypeOf((*MockRepository)(nil).ListGrantRecipients), arg0, arg1)
+}
+
+// ListItemVariants mocks base method.
+func (m *MockRepository) ListItemVariants(arg0 context.Context, arg1 string) ([]models.ItemVariant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListItemVariants", arg0, arg1)
+	ret0, _ := ret[0].([]models.ItemVariant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListItemVariants indicates an expected call of ListItemVariants.
+func (mr *MockRepositoryMockRecorder) ListItemVariants(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListItemVariants", reflect.TypeOf((*MockRepository)(nil).ListItemVariants), arg0, arg1)
+}
+
+// ListKudosReactions mocks base method.
+func (m *MockRepository) ListKudosReactions(arg0 context.Context, arg1 []int) ([]models.KudosReaction, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListKudosReactions", arg0, arg1)
+	ret0, _ := ret[0].([]models.KudosReaction)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListKudosReactions indicates an expected call of ListKudosReactions.
+func (mr *MockRepositoryMockRecorder) ListKudosReactions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListKudosReactions", reflect.TypeOf((*MockRepository)(nil).ListKudosReactions), arg0, arg1)
+}
+
+// ListPendingTransfers mocks base method.
+func (m *MockRepository) ListPendingTransfers(arg0 context.Context, arg1 models.PendingTransferFilter) ([]models.PendingTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPendingTransfers", arg0, arg1)
+	ret0, _ := ret[0].([]models.PendingTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPendingTransfers indicates an expected call of ListPendingTransfers.
+func (mr *MockRepositoryMockRecorder) ListPendingTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPendingTransfers", reflect.TypeOf((*MockRepository)(nil).ListPendingTransfers), arg0, arg1)
+}
+
+// ListPriceSchedules mocks base method.
+func (m *MockRepository) ListPriceSchedules(arg0 context.Context, arg1 string, arg2 time.Time) ([]models.PriceSchedule, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPriceSchedules", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.PriceSchedule)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPriceSchedules indicates an expected call of ListPriceSchedules.
+func (mr *MockRepositoryMockRecorder) ListPriceSchedules(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPriceSchedules", reflect.TypeOf((*MockRepository)(nil).ListPriceSchedules), arg0, arg1, arg2)
+}
+
+// ListPromoCodes mocks base method.
+func (m *MockRepository) ListPromoCodes(arg0 context.Context) ([]models.PromoCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPromoCodes", arg0)
+	ret0, _ := ret[0].([]models.PromoCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPromoCodes indicates an expected call of ListPromoCodes.
+func (mr *MockRepositoryMockRecorder) ListPromoCodes(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPromoCodes", reflect.TypeOf((*MockRepository)(nil).ListPromoCodes), arg0)
+}
+
+// ListPublicTransfers mocks base method.
+func (m *MockRepository) ListPublicTransfers(arg0 context.Context, arg1, arg2 int) ([]models.FeedEntry, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPublicTransfers", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.FeedEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPublicTransfers indicates an expected call of ListPublicTransfers.
+func (mr *MockRepositoryMockRecorder) ListPublicTransfers(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPublicTransfers", reflect.TypeOf((*MockRepository)(nil).ListPublicTransfers), arg0, arg1, arg2)
+}
+
+// ListPurchaseReturns mocks base method.
+func (m *MockRepository) ListPurchaseReturns(arg0 context.Context, arg1 models.PurchaseReturnFilter) ([]models.PurchaseReturn, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPurchaseReturns", arg0, arg1)
+	ret0, _ := ret[0].([]models.PurchaseReturn)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPurchaseReturns indicates an expected call of ListPurchaseReturns.
+func (mr *MockRepositoryMockRecorder) ListPurchaseReturns(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPurchaseReturns", reflect.TypeOf((*MockRepository)(nil).ListPurchaseReturns), arg0, arg1)
+}
+
+// ListPurchases mocks base method.
+func (m *MockRepository) ListPurchases(arg0 context.Context, arg1 int) ([]models.Purchase, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListPurchases", arg0, arg1)
+	ret0, _ := ret[0].([]models.Purchase)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListPurchases indicates an expected call of ListPurchases.
+func (mr *MockRepositoryMockRecorder) ListPurchases(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListPurchases", reflect.TypeOf((*MockRepository)(nil).ListPurchases), arg0, arg1)
+}
+
+// ListScheduledTransfers mocks base method.
+func (m *MockRepository) ListScheduledTransfers(arg0 context.Context, arg1 int) ([]models.ScheduledTransfer, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListScheduledTransfers", arg0, arg1)
+	ret0, _ := ret[0].([]models.ScheduledTransfer)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListScheduledTransfers indicates an expected call of ListScheduledTransfers.
+func (mr *MockRepositoryMockRecorder) ListScheduledTransfers(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListScheduledTransfers", reflect.TypeOf((*MockRepository)(nil).ListScheduledTransfers), arg0, arg1)
+}
+
+// ListUsersWithExpiredCoinLots mocks base method.
+func (m *MockRepository) ListUsersWithExpiredCoinLots(arg0 context.Context, arg1 time.Time, arg2 int) ([]int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListUsersWithExpiredCoinLots", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListUsersWithExpiredCoinLots indicates an expected call of ListUsersWithExpiredCoinLots.
+func (mr *MockRepositoryMockRecorder) ListUsersWithExpiredCoinLots(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListUsersWithExpiredCoinLots", reflect.TypeOf((*MockRepository)(nil).ListUsersWithExpiredCoinLots), arg0, arg1, arg2)
+}
+
+// ListWebhookDeliveries mocks base method.
+func (m *MockRepository) ListWebhookDeliveries(arg0 context.Context, arg1, arg2 int) ([]models.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhookDeliveries", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]models.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhookDeliveries indicates an expected call of ListWebhookDeliveries.
+func (mr *MockRepositoryMockRecorder) ListWebhookDeliveries(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookDeliveries", reflect.TypeOf((*MockRepository)(nil).ListWebhookDeliveries), arg0, arg1, arg2)
+}
+
+// ListWebhookSubscriptions mocks base method.
+func (m *MockRepository) ListWebhookSubscriptions(arg0 context.Context) ([]models.WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListWebhookSubscriptions", arg0)
+	ret0, _ := ret[0].([]models.WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListWebhookSubscriptions indicates an expected call of ListWebhookSubscriptions.
+func (mr *MockRepositoryMockRecorder) ListWebhookSubscriptions(arg0 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListWebhookSubscriptions", reflect.TypeOf((*MockRepository)(nil).ListWebhookSubscriptions), arg0)
+}
+
+// LockUsers mocks base method.
+func (m *MockRepository) LockUsers(arg0 context.Context, arg1 ...int) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{arg0}
+	for _, a := range arg1 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "LockUsers", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockUsers indicates an expected call of LockUsers.
+func (mr *MockRepositoryMockRecorder) LockUsers(arg0 interface{}, arg1 ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{arg0}, arg1...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockUsers", reflect.TypeOf((*MockRepository)(nil).LockUsers), varargs...)
+}
+
+// NotifyUser mocks base method.
+func (m *MockRepository) NotifyUser(arg0 context.Context, arg1 []byte) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifyUser", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifyUser indicates an expected call of NotifyUser.
+func (mr *MockRepositoryMockRecorder) NotifyUser(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifyUser", reflect.TypeOf((*MockRepository)(nil).NotifyUser), arg0, arg1)
+}
+
+// ParkCoinLots mocks base method.
+func (m *MockRepository) ParkCoinLots(arg0 context.Context, arg1 int, arg2 []models.CoinLot) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ParkCoinLots", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ParkCoinLots indicates an expected call of ParkCoinLots.
+func (mr *MockRepositoryMockRecorder) ParkCoinLots(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ParkCoinLots", reflect.TypeOf((*MockRepository)(nil).ParkCoinLots), arg0, arg1, arg2)
+}
+
+// ReleasePromoRedemption mocks base method.
+func (m *MockRepository) ReleasePromoRedemption(arg0 context.Context, arg1 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleasePromoRedemption", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleasePromoRedemption indicates an expected call of ReleasePromoRedemption.
+func (mr *MockRepositoryMockRecorder) ReleasePromoRedemption(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleasePromoRedemption", reflect.TypeOf((*MockRepository)(nil).ReleasePromoRedemption), arg0, arg1)
+}
+
+// RemoveKudosReaction mocks base method.
+func (m *MockRepository) RemoveKudosReaction(arg0 context.Context, arg1 models.KudosReaction) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveKudosReaction", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveKudosReaction indicates an expected call of RemoveKudosReaction.
+func (mr *MockRepositoryMockRecorder) RemoveKudosReaction(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveKudosReaction", reflect.TypeOf((*MockRepository)(nil).RemoveKudosReaction), arg0, arg1)
+}
+
+// RestockItemVariant mocks base method.
+func (m *MockRepository) RestockItemVariant(arg0 context.Context, arg1, arg2 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestockItemVariant", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RestockItemVariant indicates an expected call of RestockItemVariant.
+func (mr *MockRepositoryMockRecorder) RestockItemVariant(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestockItemVariant", reflect.TypeOf((*MockRepository)(nil).RestockItemVariant), arg0, arg1, arg2)
+}
+
+// SetRoleTransferLimits mocks base method.
+func (m *MockRepository) SetRoleTransferLimits(arg0 context.Context, arg1 string, arg2 models.TransferLimitOverride) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetRoleTransferLimits", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetRoleTransferLimits indicates an expected call of SetRoleTransferLimits.
+func (mr *MockRepositoryMockRecorder) SetRoleTransferLimits(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetRoleTransferLimits", reflect.TypeOf((*MockRepository)(nil).SetRoleTransferLimits), arg0, arg1, arg2)
+}
+
+// SetUserCreditLimit mocks base method.
+func (m *MockRepository) SetUserCreditLimit(arg0 context.Context, arg1, arg2 int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserCreditLimit", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserCreditLimit indicates an expected call of SetUserCreditLimit.
+func (mr *MockRepositoryMockRecorder) SetUserCreditLimit(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserCreditLimit", reflect.TypeOf((*MockRepository)(nil).SetUserCreditLimit), arg0, arg1, arg2)
+}
+
+// SetUserProfile mocks base method.
+func (m *MockRepository) SetUserProfile(arg0 context.Context, arg1 int, arg2 string, arg3 bool) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserProfile", arg0, arg1, arg2, arg3)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserProfile indicates an expected call of SetUserProfile.
+func (mr *MockRepositoryMockRecorder) SetUserProfile(arg0, arg1, arg2, arg3 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserProfile", reflect.TypeOf((*MockRepository)(nil).SetUserProfile), arg0, arg1, arg2, arg3)
+}
+
+// SetUserTransferLimits mocks base method.
+func (m *MockRepository) SetUserTransferLimits(arg0 context.Context, arg1 int, arg2 models.TransferLimitOverride) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetUserTransferLimits", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetUserTransferLimits indicates an expected call of SetUserTransferLimits.
+func (mr *MockRepositoryMockRecorder) SetUserTransferLimits(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetUserTransferLimits", reflect.TypeOf((*MockRepository)(nil).SetUserTransferLimits), arg0, arg1, arg2)
+}
+
+// SumExpiredCoinLots mocks base method.
+func (m *MockRepository) SumExpiredCoinLots(arg0 context.Context, arg1 int, arg2 time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SumExpiredCoinLots", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SumExpiredCoinLots indicates an expected call of SumExpiredCoinLots.
+func (mr *MockRepositoryMockRecorder) SumExpiredCoinLots(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SumExpiredCoinLots", reflect.TypeOf((*MockRepository)(nil).SumExpiredCoinLots), arg0, arg1, arg2)
+}
+
+// TakeItemVariantStock mocks base method.
+func (m *MockRepository) TakeItemVariantStock(arg0 context.Context, arg1 int) (models.ItemVariant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeItemVariantStock", arg0, arg1)
+	ret0, _ := ret[0].(models.ItemVariant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeItemVariantStock indicates an expected call of TakeItemVariantStock.
+func (mr *MockRepositoryMockRecorder) TakeItemVariantStock(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeItemVariantStock", reflect.TypeOf((*MockRepository)(nil).TakeItemVariantStock), arg0, arg1)
+}
+
+// TakeParkedCoinLots mocks base method.
+func (m *MockRepository) TakeParkedCoinLots(arg0 context.Context, arg1 int) ([]models.CoinLot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakeParkedCoinLots", arg0, arg1)
+	ret0, _ := ret[0].([]models.CoinLot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakeParkedCoinLots indicates an expected call of TakeParkedCoinLots.
+func (mr *MockRepositoryMockRecorder) TakeParkedCoinLots(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakeParkedCoinLots", reflect.TypeOf((*MockRepository)(nil).TakeParkedCoinLots), arg0, arg1)
+}
+
+// TakePurchaseCoinLots mocks base method.
+func (m *MockRepository) TakePurchaseCoinLots(arg0 context.Context, arg1 int) ([]models.CoinLot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TakePurchaseCoinLots", arg0, arg1)
+	ret0, _ := ret[0].([]models.CoinLot)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// TakePurchaseCoinLots indicates an expected call of TakePurchaseCoinLots.
+func (mr *MockRepositoryMockRecorder) TakePurchaseCoinLots(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TakePurchaseCoinLots", reflect.TypeOf((*MockRepository)(nil).TakePurchaseCoinLots), arg0, arg1)
+}
+
+// UpdateCoinRequest mocks base method.
+func (m *MockRepository) UpdateCoinRequest(arg0 context.Context, arg1 models.CoinRequest) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateCoinRequest", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateCoinRequest indicates an expected call of UpdateCoinRequest.
+func (mr *MockRepositoryMockRecorder) UpdateCoinRequest(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateCoinRequest", reflect.TypeOf((*MockRepository)(nil).UpdateCoinRequest), arg0, arg1)
+}
+
+// UpdatePendingTransfer mocks base method.
+func (m *MockRepository) UpdatePendingTransfer(arg0 context.Context, arg1 models.PendingTransfer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePendingTransfer", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePendingTransfer indicates an expected call of UpdatePendingTransfer.
+func (mr *MockRepositoryMockRecorder) UpdatePendingTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePendingTransfer", reflect.TypeOf((*MockRepository)(nil).UpdatePendingTransfer), arg0, arg1)
+}
+
+// UpdatePurchaseReturn mocks base method.
+func (m *MockRepository) UpdatePurchaseReturn(arg0 context.Context, arg1 models.PurchaseReturn) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePurchaseReturn", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePurchaseReturn indicates an expected call of UpdatePurchaseReturn.
+func (mr *MockRepositoryMockRecorder) UpdatePurchaseReturn(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePurchaseReturn", reflect.TypeOf((*MockRepository)(nil).UpdatePurchaseReturn), arg0, arg1)
+}
+
+// UpdateScheduledTransfer mocks base method.
+func (m *MockRepository) UpdateScheduledTransfer(arg0 context.Context, arg1 models.ScheduledTransfer) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateScheduledTransfer", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateScheduledTransfer indicates an expected call of UpdateScheduledTransfer.
+func (mr *MockRepositoryMockRecorder) UpdateScheduledTransfer(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateScheduledTransfer", reflect.TypeOf((*MockRepository)(nil).UpdateScheduledTransfer), arg0, arg1)
+}
+
+// UpdateUserBonusCoins mocks base method.
+func (m *MockRepository) UpdateUserBonusCoins(arg0 context.Context, arg1, arg2 int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserBonusCoins", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserBonusCoins indicates an expected call of UpdateUserBonusCoins.
+func (mr *MockRepositoryMockRecorder) UpdateUserBonusCoins(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserBonusCoins", reflect.TypeOf((*MockRepository)(nil).UpdateUserBonusCoins), arg0, arg1, arg2)
+}
+
+// UpdateUserCoins mocks base method.
+func (m *MockRepository) UpdateUserCoins(arg0 context.Context, arg1, arg2 int) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateUserCoins", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpdateUserCoins indicates an expected call of UpdateUserCoins.
+func (mr *MockRepositoryMockRecorder) UpdateUserCoins(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserCoins", reflect.TypeOf((*MockRepository)(nil).UpdateUserCoins), arg0, arg1, arg2)
+}
+
+// UpsertItemVariant mocks base method.
+func (m *MockRepository) UpsertItemVariant(arg0 context.Context, arg1 models.ItemVariant) (models.ItemVariant, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertItemVariant", arg0, arg1)
+	ret0, _ := ret[0].(models.ItemVariant)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// UpsertItemVariant indicates an expected call of UpsertItemVariant.
+func (mr *MockRepositoryMockRecorder) UpsertItemVariant(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertItemVariant", reflect.TypeOf((*MockRepository)(nil).UpsertItemVariant), arg0, arg1)
+}
+
+// WithTx mocks base method.
+func (m *MockRepository) WithTx(arg0 context.Context, arg1 func(context.Context) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx.
+func (mr *MockRepositoryMockRecorder) WithTx(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockRepository)(nil).WithTx), arg0, arg1)
+}
+
+// WithdrawUserCoins mocks base method.
+func (m *MockRepository) WithdrawUserCoins(arg0 context.Context, arg1, arg2 int) (int, []models.CoinLot, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithdrawUserCoins", arg0, arg1, arg2)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].([]models.CoinLot)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// WithdrawUserCoins indicates an expected call of WithdrawUserCoins.
+func (mr *MockRepositoryMockRecorder) WithdrawUserCoins(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithdrawUserCoins", reflect.TypeOf((*MockRepository)(nil).WithdrawUserCoins), arg0, arg1, arg2)
 }
@@ -0,0 +1,204 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"AVTproject/models"
+)
+
+const maxPromoCodeLength = 32
+
+type PromoCodeRequest struct {
+	Code           string
+	Kind           string
+	Value          int
+	Item           string
+	StartsAt       time.Time
+	EndsAt         *time.Time
+	MaxRedemptions int
+	PerUserLimit   int
+}
+
+type PromoCodeInfo struct {
+	ID             int        `json:"id"`
+	Code           string     `json:"code"`
+	Kind           string     `json:"kind"`
+	Value          int        `json:"value"`
+	Item           string     `json:"item,omitempty"`
+	StartsAt       time.Time  `json:"startsAt"`
+	EndsAt         *time.Time `json:"endsAt,omitempty"`
+	MaxRedemptions int        `json:"maxRedemptions,omitempty"`
+	PerUserLimit   int        `json:"perUserLimit,omitempty"`
+	Redemptions    int        `json:"redemptions"`
+	Active         bool       `json:"active"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func (s Service) CreatePromoCode(ctx context.Context, actorID int, req PromoCodeRequest) (PromoCodeInfo, error) {
+	code := normalizePromoCode(req.Code)
+	if code == "" || len(code) > maxPromoCodeLength || strings.IndexFunc(code, invalidPromoRune) >= 0 {
+		return PromoCodeInfo{}, errors.New("промокод может содержать только латинские буквы, цифры, «-» и «_»")
+	}
+	switch req.Kind {
+	case models.DiscountPercent:
+		if req.Value <= 0 || req.Value > 100 {
+			return PromoCodeInfo{}, errors.New("процент скидки должен быть от 1 до 100")
+		}
+	case models.DiscountFixed:
+		if req.Value <= 0 {
+			return PromoCodeInfo{}, errors.New("скидка должна быть положительной")
+		}
+	default:
+		return PromoCodeInfo{}, errors.New("неизвестный тип скидки")
+	}
+	if _, ok := merchPrices[req.Item]; req.Item != "" && !ok {
+		return PromoCodeInfo{}, errors.New("неверное название мерча")
+	}
+	if req.StartsAt.IsZero() {
+		req.StartsAt = time.Now()
+	}
+	if req.EndsAt != nil && !req.EndsAt.After(req.StartsAt) {
+		return PromoCodeInfo{}, errors.New("срок действия промокода должен заканчиваться после начала")
+	}
+	if req.MaxRedemptions < 0 || req.PerUserLimit < 0 {
+		return PromoCodeInfo{}, errors.New("лимиты погашений не могут быть отрицательными")
+	}
+
+	p := models.PromoCode{
+		Code:           code,
+		Kind:           req.Kind,
+		Value:          req.Value,
+		Item:           req.Item,
+		StartsAt:       req.StartsAt,
+		EndsAt:         req.EndsAt,
+		MaxRedemptions: req.MaxRedemptions,
+		PerUserLimit:   req.PerUserLimit,
+		Active:         true,
+		CreatedAt:      time.Now(),
+	}
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		_, err := s.repo.GetPromoCodeForUpdate(ctx, code)
+		if err == nil {
+			return errors.New("такой промокод уже существует")
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if p.ID, err = s.repo.CreatePromoCode(ctx, p); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, AuditPromoCode, promoCodeTarget(p.ID), nil, toPromoCodeInfo(p))
+	})
+	if err != nil {
+		return PromoCodeInfo{}, err
+	}
+	return toPromoCodeInfo(p), nil
+}
+
+func (s Service) ListPromoCodes(ctx context.Context) ([]PromoCodeInfo, error) {
+	codes, err := s.repo.ListPromoCodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]PromoCodeInfo, 0, len(codes))
+	for _, p := range codes {
+		result = append(result, toPromoCodeInfo(p))
+	}
+	return result, nil
+}
+
+func (s Service) DeactivatePromoCode(ctx context.Context, actorID, id int) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeactivatePromoCode(ctx, id); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, AuditPromoCode, promoCodeTarget(id), nil, map[string]bool{"active": false})
+	})
+}
+
+// checkPromoCode проверяет промокод для покупки item по цене price и
+// возвращает погашение, которое покупка запишет вместе со своим
+// идентификатором. Промокод заблокирован до конца транзакции покупки,
+// поэтому лимиты не превышаются при параллельных покупках.
+func (s Service) checkPromoCode(
+	ctx context.Context,
+	userID int,
+	code, item string,
+	price int,
+) (models.PromoRedemption, error) {
+	invalid := errors.New("промокод недействителен")
+	p, err := s.repo.GetPromoCodeForUpdate(ctx, normalizePromoCode(code))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.PromoRedemption{}, invalid
+	}
+	if err != nil {
+		return models.PromoRedemption{}, err
+	}
+	now := time.Now()
+	if !p.Active || now.Before(p.StartsAt) || (p.EndsAt != nil && !now.Before(*p.EndsAt)) {
+		return models.PromoRedemption{}, invalid
+	}
+	if p.Item != "" && p.Item != item {
+		return models.PromoRedemption{}, errors.New("промокод не действует на этот товар")
+	}
+	if p.MaxRedemptions > 0 && p.Redemptions >= p.MaxRedemptions {
+		return models.PromoRedemption{}, errors.New("промокод больше недоступен")
+	}
+	if p.PerUserLimit > 0 {
+		used, err := s.repo.CountPromoRedemptions(ctx, p.ID, userID)
+		if err != nil {
+			return models.PromoRedemption{}, err
+		}
+		if used >= p.PerUserLimit {
+			return models.PromoRedemption{}, errors.New("вы уже использовали этот промокод")
+		}
+	}
+
+	return models.PromoRedemption{
+		PromoID:  p.ID,
+		UserID:   userID,
+		Item:     item,
+		Discount: promoDiscount(p, price),
+	}, nil
+}
+
+func promoDiscount(p models.PromoCode, price int) int {
+	if p.Kind == models.DiscountPercent {
+		return price * p.Value / 100
+	}
+	return min(p.Value, price)
+}
+
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func invalidPromoRune(r rune) bool {
+	return !(r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_')
+}
+
+func promoCodeTarget(id int) string {
+	return "promo_code:" + strconv.Itoa(id)
+}
+
+func toPromoCodeInfo(p models.PromoCode) PromoCodeInfo {
+	return PromoCodeInfo{
+		ID:             p.ID,
+		Code:           p.Code,
+		Kind:           p.Kind,
+		Value:          p.Value,
+		Item:           p.Item,
+		StartsAt:       p.StartsAt,
+		EndsAt:         p.EndsAt,
+		MaxRedemptions: p.MaxRedemptions,
+		PerUserLimit:   p.PerUserLimit,
+		Redemptions:    p.Redemptions,
+		Active:         p.Active,
+		CreatedAt:      p.CreatedAt,
+	}
+}
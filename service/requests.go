@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"AVTproject/models"
+)
+
+type CoinRequestInfo struct {
+	ID                int        `json:"id"`
+	SplitID           int        `json:"splitId,omitempty"`
+	Requester         string     `json:"requester"`
+	Payer             string     `json:"payer"`
+	Amount            int        `json:"amount"`
+	Memo              string     `json:"memo,omitempty"`
+	Status            string     `json:"status"`
+	TransactionID     int        `json:"transactionId,omitempty"`
+	PendingTransferID int        `json:"pendingTransferId,omitempty"`
+	ExpiresAt         time.Time  `json:"expiresAt"`
+	CreatedAt         time.Time  `json:"createdAt"`
+	DecidedAt         *time.Time `json:"decidedAt,omitempty"`
+}
+
+type CoinRequestNotification struct {
+	ID     int    `json:"id"`
+	User   string `json:"user,omitempty"`
+	Amount int    `json:"amount"`
+	Memo   string `json:"memo,omitempty"`
+	Status string `json:"status"`
+}
+
+// WithCoinRequestTTL задаёт срок, после которого неоплаченный запрос монет истекает.
+func WithCoinRequestTTL(ttl time.Duration) Option {
+	return func(s *Service) {
+		s.coinRequestTTL = ttl
+	}
+}
+
+func (s Service) CreateCoinRequest(
+	ctx context.Context,
+	requesterID int,
+	payerUsername string,
+	amount int,
+	memo string,
+) (CoinRequestInfo, error) {
+	if amount <= 0 {
+		return CoinRequestInfo{}, errors.New("сумма запроса должна быть положительной")
+	}
+	opts, err := TransferOptions{Memo: memo}.normalize()
+	if err != nil {
+		return CoinRequestInfo{}, err
+	}
+
+	var info CoinRequestInfo
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		requester, err := s.repo.GetUserByID(ctx, requesterID)
+		if err != nil {
+			return err
+		}
+		payer, err := s.repo.GetUserByUsername(ctx, payerUsername)
+		if err != nil {
+			return err
+		}
+		if payer.ID == requester.ID {
+			return errors.New("нельзя запросить монеты у самого себя")
+		}
+
+		cr, err := s.createCoinRequest(ctx, requester, payer, amount, opts.Memo, 0)
+		if err != nil {
+			return err
+		}
+		info = toCoinRequestInfo(cr)
+		return nil
+	})
+	if err != nil {
+		return CoinRequestInfo{}, err
+	}
+	return info, nil
+}
+
+func (s Service) createCoinRequest(
+	ctx context.Context,
+	requester, payer models.User,
+	amount int,
+	memo string,
+	splitID int,
+) (models.CoinRequest, error) {
+	cr := models.CoinRequest{
+		SplitID:           splitID,
+		RequesterID:       requester.ID,
+		PayerID:           payer.ID,
+		RequesterUsername: requester.Username,
+		PayerUsername:     payer.Username,
+		Amount:            amount,
+		Memo:              memo,
+		Status:            models.CoinRequestPending,
+		ExpiresAt:         time.Now().Add(s.coinRequestTTL),
+	}
+	var err error
+	if cr.ID, err = s.repo.CreateCoinRequest(ctx, cr); err != nil {
+		return models.CoinRequest{}, err
+	}
+	err = s.notifyUser(ctx, payer.ID, models.NotificationCoinRequested, CoinRequestNotification{
+		ID:     cr.ID,
+		User:   requester.Username,
+		Amount: amount,
+		Memo:   memo,
+		Status: cr.Status,
+	})
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+	return cr, nil
+}
+
+func (s Service) ListIncomingCoinRequests(ctx context.Context, userID int) ([]CoinRequestInfo, error) {
+	requests, err := s.repo.ListCoinRequests(ctx, models.CoinRequestFilter{PayerID: userID})
+	if err != nil {
+		return nil, err
+	}
+	return toCoinRequestInfos(requests), nil
+}
+
+func (s Service) ListOutgoingCoinRequests(ctx context.Context, userID int) ([]CoinRequestInfo, error) {
+	requests, err := s.repo.ListCoinRequests(ctx, models.CoinRequestFilter{RequesterID: userID})
+	if err != nil {
+		return nil, err
+	}
+	return toCoinRequestInfos(requests), nil
+}
+
+// PayCoinRequest оплачивает запрос обычным переводом через SendCoin, поэтому
+// на оплату распространяются лимиты, согласование и все события перевода.
+// Если перевод удержан до согласования или подтверждения получателем, запрос
+// ждёт его исхода в статусе payment_pending.
+func (s Service) PayCoinRequest(ctx context.Context, payerID, id int) (TransferResult, error) {
+	var result TransferResult
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		cr, err := s.coinRequestForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if cr.PayerID != payerID {
+			return sql.ErrNoRows
+		}
+		if time.Now().After(cr.ExpiresAt) {
+			return errors.New("срок запроса истёк")
+		}
+		result, err = s.SendCoin(ctx, payerID, cr.RequesterUsername, cr.Amount, TransferOptions{Memo: cr.Memo})
+		if err != nil {
+			return err
+		}
+		cr.TransactionID = result.TransactionID
+		cr.PendingTransferID = result.PendingTransferID
+		if cr.TransactionID == 0 {
+			cr.Status = models.CoinRequestPaymentPending
+			if err := s.repo.UpdateCoinRequest(ctx, cr); err != nil {
+				return err
+			}
+			return s.notifyCoinRequestStatus(ctx, cr, cr.RequesterID)
+		}
+		return s.closeCoinRequest(ctx, cr, models.CoinRequestPaid, cr.RequesterID)
+	})
+	if err != nil {
+		return TransferResult{}, err
+	}
+	return result, nil
+}
+
+func (s Service) DeclineCoinRequest(ctx context.Context, payerID, id int) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		cr, err := s.coinRequestForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if cr.PayerID != payerID {
+			return sql.ErrNoRows
+		}
+		return s.closeCoinRequest(ctx, cr, models.CoinRequestDeclined, cr.RequesterID)
+	})
+}
+
+func (s Service) CancelCoinRequest(ctx context.Context, requesterID, id int) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		cr, err := s.coinRequestForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if cr.RequesterID != requesterID {
+			return sql.ErrNoRows
+		}
+		return s.closeCoinRequest(ctx, cr, models.CoinRequestCancelled, cr.PayerID)
+	})
+}
+
+func (s Service) ExpireCoinRequests(ctx context.Context) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		expired, err := s.repo.ExpireCoinRequests(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		for _, cr := range expired {
+			if err := s.notifyCoinRequestStatus(ctx, cr, cr.RequesterID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s Service) coinRequestForUpdate(ctx context.Context, id int) (models.CoinRequest, error) {
+	cr, err := s.repo.GetCoinRequestForUpdate(ctx, id)
+	if err != nil {
+		return models.CoinRequest{}, err
+	}
+	if cr.Status != models.CoinRequestPending {
+		return models.CoinRequest{}, errors.New("запрос уже обработан")
+	}
+	return cr, nil
+}
+
+// completeCoinRequestPayment отмечает оплаченным запрос, ожидавший
+// удержанный перевод, после того как перевод записан транзакцией.
+func (s Service) completeCoinRequestPayment(ctx context.Context, pendingTransferID, transactionID int) error {
+	cr, ok, err := s.coinRequestByPayment(ctx, 0, pendingTransferID, models.CoinRequestPaymentPending)
+	if err != nil || !ok {
+		return err
+	}
+	cr.TransactionID = transactionID
+	return s.closeCoinRequest(ctx, cr, models.CoinRequestPaid, cr.RequesterID)
+}
+
+// reopenCoinRequest снова открывает запрос, если его оплата не состоялась:
+// удержанный перевод отклонён или истёк, либо оплативший перевод отменён.
+// Запрос, срок которого уже прошёл, сразу истекает.
+func (s Service) reopenCoinRequest(ctx context.Context, transactionID, pendingTransferID int, status string) error {
+	cr, ok, err := s.coinRequestByPayment(ctx, transactionID, pendingTransferID, status)
+	if err != nil || !ok {
+		return err
+	}
+	cr.TransactionID = 0
+	cr.PendingTransferID = 0
+	if time.Now().After(cr.ExpiresAt) {
+		return s.closeCoinRequest(ctx, cr, models.CoinRequestExpired, cr.RequesterID)
+	}
+	cr.Status = models.CoinRequestPending
+	cr.DecidedAt = nil
+	if err := s.repo.UpdateCoinRequest(ctx, cr); err != nil {
+		return err
+	}
+	return s.notifyCoinRequestStatus(ctx, cr, cr.RequesterID)
+}
+
+func (s Service) coinRequestByPayment(
+	ctx context.Context,
+	transactionID, pendingTransferID int,
+	status string,
+) (models.CoinRequest, bool, error) {
+	cr, err := s.repo.GetCoinRequestByPaymentForUpdate(ctx, transactionID, pendingTransferID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.CoinRequest{}, false, nil
+	}
+	if err != nil {
+		return models.CoinRequest{}, false, err
+	}
+	return cr, cr.Status == status, nil
+}
+
+func (s Service) closeCoinRequest(ctx context.Context, cr models.CoinRequest, status string, notifyUserID int) error {
+	now := time.Now()
+	cr.Status = status
+	cr.DecidedAt = &now
+	if err := s.repo.UpdateCoinRequest(ctx, cr); err != nil {
+		return err
+	}
+	return s.notifyCoinRequestStatus(ctx, cr, notifyUserID)
+}
+
+func (s Service) notifyCoinRequestStatus(ctx context.Context, cr models.CoinRequest, userID int) error {
+	return s.notifyUser(ctx, userID, models.NotificationCoinRequestStatus, CoinRequestNotification{
+		ID:     cr.ID,
+		Amount: cr.Amount,
+		Status: cr.Status,
+	})
+}
+
+func toCoinRequestInfo(cr models.CoinRequest) CoinRequestInfo {
+	return CoinRequestInfo{
+		ID:                cr.ID,
+		SplitID:           cr.SplitID,
+		Requester:         cr.RequesterUsername,
+		Payer:             cr.PayerUsername,
+		Amount:            cr.Amount,
+		Memo:              cr.Memo,
+		Status:            cr.Status,
+		TransactionID:     cr.TransactionID,
+		PendingTransferID: cr.PendingTransferID,
+		ExpiresAt:         cr.ExpiresAt,
+		CreatedAt:         cr.CreatedAt,
+		DecidedAt:         cr.DecidedAt,
+	}
+}
+
+func toCoinRequestInfos(requests []models.CoinRequest) []CoinRequestInfo {
+	result := make([]CoinRequestInfo, 0, len(requests))
+	for _, cr := range requests {
+		result = append(result, toCoinRequestInfo(cr))
+	}
+	return result
+}
@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"AVTproject/models"
+)
+
+type PurchaseInfo struct {
+	ID              int        `json:"id"`
+	Item            string     `json:"item"`
+	Size            string     `json:"size,omitempty"`
+	Color           string     `json:"color,omitempty"`
+	Quantity        int        `json:"quantity"`
+	Price           int        `json:"price"`
+	OriginalPrice   int        `json:"originalPrice,omitempty"`
+	PaidBonus       int        `json:"paidBonus,omitempty"`
+	GiftFrom        string     `json:"giftFrom,omitempty"`
+	GiftTo          string     `json:"giftTo,omitempty"`
+	GiftMessage     string     `json:"giftMessage,omitempty"`
+	ReturnableUntil *time.Time `json:"returnableUntil,omitempty"`
+	CreatedAt       time.Time  `json:"createdAt"`
+}
+
+type PurchaseReturnInfo struct {
+	ID                 int        `json:"id"`
+	PurchaseID         int        `json:"purchaseId"`
+	User               string     `json:"user"`
+	Item               string     `json:"item"`
+	Size               string     `json:"size,omitempty"`
+	Color              string     `json:"color,omitempty"`
+	Amount             int        `json:"amount"`
+	Reason             string     `json:"reason,omitempty"`
+	Status             string     `json:"status"`
+	DecisionReason     string     `json:"decisionReason,omitempty"`
+	TransactionID      int        `json:"transactionId,omitempty"`
+	BonusTransactionID int        `json:"bonusTransactionId,omitempty"`
+	CreatedAt          time.Time  `json:"createdAt"`
+	DecidedAt          *time.Time `json:"decidedAt,omitempty"`
+}
+
+type ReturnStatusNotification struct {
+	ID     int    `json:"id"`
+	Item   string `json:"item"`
+	Amount int    `json:"amount"`
+	Status string `json:"status"`
+}
+
+// WithReturnWindow задаёт, сколько времени после покупки можно запросить
+// возврат. Нулевое значение отключает возвраты.
+func WithReturnWindow(window time.Duration) Option {
+	return func(s *Service) {
+		s.returnWindow = window
+	}
+}
+
+func (s Service) ListPurchases(ctx context.Context, userID int) ([]PurchaseInfo, error) {
+	purchases, err := s.repo.ListPurchases(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := make([]PurchaseInfo, 0, len(purchases))
+	for _, p := range purchases {
+		info := PurchaseInfo{
+			ID:            p.ID,
+			Item:          p.Item,
+			Size:          p.Size,
+			Color:         p.Color,
+			Quantity:      p.Quantity,
+			Price:         p.Price,
+			OriginalPrice: p.OriginalPrice,
+			PaidBonus:     p.PaidBonus,
+			GiftMessage:   p.GiftMessage,
+			CreatedAt:     p.CreatedAt,
+		}
+		switch {
+		case p.BuyerID == userID:
+			info.GiftTo = p.Username
+		case p.BuyerID != 0:
+			// Получатель подарка не видит, сколько за него заплатили.
+			info.GiftFrom = p.BuyerUsername
+			info.Price, info.OriginalPrice, info.PaidBonus = 0, 0, 0
+		}
+		if until := p.CreatedAt.Add(s.returnWindow); s.returnable(p) && now.Before(until) {
+			info.ReturnableUntil = &until
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// RequestReturn создаёт заявку на возврат покупки. Монеты возвращаются только
+// после одобрения администратором.
+func (s Service) RequestReturn(ctx context.Context, userID, purchaseID int, reason string) (PurchaseReturnInfo, error) {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > maxReasonLength {
+		return PurchaseReturnInfo{}, errors.New("слишком длинная причина возврата")
+	}
+	var ret models.PurchaseReturn
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		p, err := s.repo.GetPurchaseForUpdate(ctx, purchaseID)
+		if err != nil {
+			return err
+		}
+		if p.UserID != userID {
+			return sql.ErrNoRows
+		}
+		if !s.returnable(p) {
+			return errors.New("покупка не подлежит возврату")
+		}
+		if p.Quantity < 1 {
+			return errors.New("покупка уже возвращена")
+		}
+		if time.Since(p.CreatedAt) > s.returnWindow {
+			return errors.New("срок возврата покупки истёк")
+		}
+		existing, err := s.repo.ListPurchaseReturns(ctx, models.PurchaseReturnFilter{PurchaseID: p.ID})
+		if err != nil {
+			return err
+		}
+		for _, e := range existing {
+			if e.Status == models.ReturnPending || e.Status == models.ReturnApproved {
+				return errors.New("возврат этой покупки уже запрошен")
+			}
+		}
+
+		ret = models.PurchaseReturn{
+			PurchaseID: p.ID,
+			UserID:     userID,
+			Item:       p.Item,
+			Size:       p.Size,
+			Color:      p.Color,
+			Amount:     p.Price,
+			Reason:     reason,
+			Status:     models.ReturnPending,
+			CreatedAt:  time.Now(),
+		}
+		if ret.ID, err = s.repo.CreatePurchaseReturn(ctx, ret); err != nil {
+			return err
+		}
+		return s.audit(ctx, userID, AuditReturnRequest, purchaseReturnTarget(ret.ID), nil, map[string]interface{}{
+			"purchaseId": p.ID,
+			"amount":     p.Price,
+		})
+	})
+	if err != nil {
+		return PurchaseReturnInfo{}, err
+	}
+	return toPurchaseReturnInfo(ret), nil
+}
+
+func (s Service) ListOwnReturns(ctx context.Context, userID int) ([]PurchaseReturnInfo, error) {
+	returns, err := s.repo.ListPurchaseReturns(ctx, models.PurchaseReturnFilter{UserID: userID})
+	if err != nil {
+		return nil, err
+	}
+	return toPurchaseReturnInfos(returns), nil
+}
+
+func (s Service) ListReturns(ctx context.Context, status string) ([]PurchaseReturnInfo, error) {
+	switch status {
+	case "", models.ReturnPending, models.ReturnApproved, models.ReturnRejected:
+	default:
+		return nil, errors.New("неизвестный статус возврата")
+	}
+	returns, err := s.repo.ListPurchaseReturns(ctx, models.PurchaseReturnFilter{Status: status})
+	if err != nil {
+		return nil, err
+	}
+	return toPurchaseReturnInfos(returns), nil
+}
+
+// ApproveReturn уменьшает количество купленного товара, возвращает его на
+// склад, снимает погашение промокода и зачисляет покупателю списанные монеты
+// в те же кошельки, из которых они были оплачены. Возврат оформляется
+// транзакциями типа refund. Вариант, промокод и пользователь блокируются в
+// том же порядке, что и в BuyItem.
+func (s Service) ApproveReturn(ctx context.Context, actorID, id int) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		ret, err := s.pendingReturn(ctx, id)
+		if err != nil {
+			return err
+		}
+		p, err := s.repo.GetPurchaseForUpdate(ctx, ret.PurchaseID)
+		if err != nil {
+			return err
+		}
+		if p.VariantID != 0 {
+			if err := s.repo.RestockItemVariant(ctx, p.VariantID, 1); err != nil {
+				return err
+			}
+		}
+		if err := s.repo.ReleasePromoRedemption(ctx, p.ID); err != nil {
+			return err
+		}
+		if err := s.repo.LockUsers(ctx, p.UserID); err != nil {
+			return err
+		}
+		user, err := s.repo.GetUserByID(ctx, p.UserID)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.DecrementPurchase(ctx, p.ID); err != nil {
+			return err
+		}
+
+		coins, bonusCoins := user.Coins, user.BonusCoins
+		regular, bonus := p.Price-p.PaidBonus, p.PaidBonus
+		refund := models.Transaction{
+			ToUserID:  p.UserID,
+			Type:      models.TransactionTypeRefund,
+			Reference: purchaseTarget(p.ID),
+			ActorID:   actorID,
+		}
+		if bonus > 0 {
+			if bonusCoins, err = s.repo.UpdateUserBonusCoins(ctx, p.UserID, bonus); err != nil {
+				return err
+			}
+			t := refund
+			t.Amount = bonus
+			t.Wallet = models.WalletBonus
+			if ret.BonusTransactionID, err = s.repo.AddTransaction(ctx, t); err != nil {
+				return err
+			}
+		}
+		if regular > 0 {
+			lots, err := s.repo.TakePurchaseCoinLots(ctx, p.ID)
+			if err != nil {
+				return err
+			}
+			if coins, err = s.repo.DepositUserCoins(ctx, p.UserID, regular, lots); err != nil {
+				return err
+			}
+			t := refund
+			t.Amount = regular
+			if ret.TransactionID, err = s.repo.AddTransaction(ctx, t); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		ret.Status = models.ReturnApproved
+		ret.ApproverID = actorID
+		ret.DecidedAt = &now
+		if err := s.repo.UpdatePurchaseReturn(ctx, ret); err != nil {
+			return err
+		}
+		if err := s.audit(
+			ctx,
+			actorID,
+			AuditReturnApprove,
+			purchaseReturnTarget(ret.ID),
+			map[string]int{"coins": user.Coins, "bonusCoins": user.BonusCoins},
+			map[string]int{"coins": coins, "bonusCoins": bonusCoins, "refunded": p.Price},
+		); err != nil {
+			return err
+		}
+		if regular > 0 {
+			if err := s.notifyBalance(ctx, p.UserID, coins, regular); err != nil {
+				return err
+			}
+		}
+		return s.notifyReturnStatus(ctx, ret)
+	})
+}
+
+func (s Service) RejectReturn(ctx context.Context, actorID, id int, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > maxReasonLength {
+		return errors.New("слишком длинная причина отказа")
+	}
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		ret, err := s.pendingReturn(ctx, id)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		ret.Status = models.ReturnRejected
+		ret.ApproverID = actorID
+		ret.DecisionReason = reason
+		ret.DecidedAt = &now
+		if err := s.repo.UpdatePurchaseReturn(ctx, ret); err != nil {
+			return err
+		}
+		if err := s.audit(
+			ctx,
+			actorID,
+			AuditReturnReject,
+			purchaseReturnTarget(ret.ID),
+			map[string]string{"status": models.ReturnPending},
+			map[string]string{"status": ret.Status, "reason": reason},
+		); err != nil {
+			return err
+		}
+		return s.notifyReturnStatus(ctx, ret)
+	})
+}
+
+func (s Service) pendingReturn(ctx context.Context, id int) (models.PurchaseReturn, error) {
+	ret, err := s.repo.GetPurchaseReturnForUpdate(ctx, id)
+	if err != nil {
+		return models.PurchaseReturn{}, err
+	}
+	if ret.Status != models.ReturnPending {
+		return models.PurchaseReturn{}, errors.New("возврат уже обработан")
+	}
+	return ret, nil
+}
+
+// returnable сообщает, можно ли вернуть покупку: у покупок, сделанных до
+// появления возвратов, не сохранена цена, и вернуть за них монеты нельзя.
+// Подарки не возвращаются: монеты платил один пользователь, а товар у другого.
+func (s Service) returnable(p models.Purchase) bool {
+	return s.returnWindow > 0 && p.BuyerID == 0
+}
+
+func (s Service) notifyReturnStatus(ctx context.Context, ret models.PurchaseReturn) error {
+	return s.notifyUser(ctx, ret.UserID, models.NotificationReturnStatus, ReturnStatusNotification{
+		ID:     ret.ID,
+		Item:   ret.Item,
+		Amount: ret.Amount,
+		Status: ret.Status,
+	})
+}
+
+func purchaseTarget(id int) string {
+	return "purchase:" + strconv.Itoa(id)
+}
+
+func purchaseReturnTarget(id int) string {
+	return "purchase_return:" + strconv.Itoa(id)
+}
+
+func toPurchaseReturnInfo(ret models.PurchaseReturn) PurchaseReturnInfo {
+	return PurchaseReturnInfo{
+		ID:                 ret.ID,
+		PurchaseID:         ret.PurchaseID,
+		User:               ret.Username,
+		Item:               ret.Item,
+		Size:               ret.Size,
+		Color:              ret.Color,
+		Amount:             ret.Amount,
+		Reason:             ret.Reason,
+		Status:             ret.Status,
+		DecisionReason:     ret.DecisionReason,
+		TransactionID:      ret.TransactionID,
+		BonusTransactionID: ret.BonusTransactionID,
+		CreatedAt:          ret.CreatedAt,
+		DecidedAt:          ret.DecidedAt,
+	}
+}
+
+func toPurchaseReturnInfos(returns []models.PurchaseReturn) []PurchaseReturnInfo {
+	result := make([]PurchaseReturnInfo, 0, len(returns))
+	for _, ret := range returns {
+		result = append(result, toPurchaseReturnInfo(ret))
+	}
+	return result
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"AVTproject/models"
+)
+
+type PriceScheduleRequest struct {
+	Item      string
+	SalePrice int
+	StartsAt  time.Time
+	EndsAt    time.Time
+}
+
+type PriceScheduleInfo struct {
+	ID            int       `json:"id"`
+	Item          string    `json:"item"`
+	SalePrice     int       `json:"salePrice"`
+	OriginalPrice int       `json:"originalPrice"`
+	StartsAt      time.Time `json:"startsAt"`
+	EndsAt        time.Time `json:"endsAt"`
+	Active        bool      `json:"active"`
+}
+
+// CreatePriceSchedule планирует распродажу товара. Расписания могут
+// пересекаться: в каждый момент действует наименьшая цена.
+func (s Service) CreatePriceSchedule(ctx context.Context, actorID int, req PriceScheduleRequest) (PriceScheduleInfo, error) {
+	base, ok := merchPrices[req.Item]
+	if !ok {
+		return PriceScheduleInfo{}, errors.New("неверное название мерча")
+	}
+	if req.SalePrice <= 0 || req.SalePrice >= base {
+		return PriceScheduleInfo{}, errors.New("цена распродажи должна быть положительной и ниже цены каталога")
+	}
+	now := time.Now()
+	if req.StartsAt.IsZero() {
+		req.StartsAt = now
+	}
+	if !req.EndsAt.After(req.StartsAt) || !req.EndsAt.After(now) {
+		return PriceScheduleInfo{}, errors.New("распродажа должна заканчиваться в будущем и после начала")
+	}
+
+	p := models.PriceSchedule{
+		Item:      req.Item,
+		SalePrice: req.SalePrice,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+		ActorID:   actorID,
+	}
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		var err error
+		if p.ID, err = s.repo.CreatePriceSchedule(ctx, p); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, AuditPriceSchedule, priceScheduleTarget(p.ID), nil, toPriceScheduleInfo(p, now))
+	})
+	if err != nil {
+		return PriceScheduleInfo{}, err
+	}
+	return toPriceScheduleInfo(p, now), nil
+}
+
+// ListPriceSchedules возвращает текущие и будущие распродажи.
+func (s Service) ListPriceSchedules(ctx context.Context) ([]PriceScheduleInfo, error) {
+	now := time.Now()
+	schedules, err := s.repo.ListPriceSchedules(ctx, "", now)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]PriceScheduleInfo, 0, len(schedules))
+	for _, p := range schedules {
+		result = append(result, toPriceScheduleInfo(p, now))
+	}
+	return result, nil
+}
+
+func (s Service) DeletePriceSchedule(ctx context.Context, actorID, id int) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeletePriceSchedule(ctx, id); err != nil {
+			return err
+		}
+		return s.audit(ctx, actorID, AuditPriceSchedule, priceScheduleTarget(id), nil, map[string]bool{"deleted": true})
+	})
+}
+
+// salePrices возвращает действующие в момент now цены распродаж по товарам.
+func (s Service) salePrices(ctx context.Context, item string, now time.Time) (map[string]int, error) {
+	schedules, err := s.repo.ListPriceSchedules(ctx, item, now)
+	if err != nil {
+		return nil, err
+	}
+	prices := make(map[string]int)
+	for _, p := range schedules {
+		if !saleActive(p, now) {
+			continue
+		}
+		if cur, ok := prices[p.Item]; !ok || p.SalePrice < cur {
+			prices[p.Item] = p.SalePrice
+		}
+	}
+	return prices, nil
+}
+
+// applySale возвращает цену с учётом распродажи: распродажа не повышает
+// цену варианта, если она и так ниже.
+func applySale(price int, sale int, onSale bool) int {
+	if onSale && sale < price {
+		return sale
+	}
+	return price
+}
+
+func saleActive(p models.PriceSchedule, now time.Time) bool {
+	return !now.Before(p.StartsAt) && now.Before(p.EndsAt)
+}
+
+func priceScheduleTarget(id int) string {
+	return "price_schedule:" + strconv.Itoa(id)
+}
+
+func toPriceScheduleInfo(p models.PriceSchedule, now time.Time) PriceScheduleInfo {
+	return PriceScheduleInfo{
+		ID:            p.ID,
+		Item:          p.Item,
+		SalePrice:     p.SalePrice,
+		OriginalPrice: merchPrices[p.Item],
+		StartsAt:      p.StartsAt,
+		EndsAt:        p.EndsAt,
+		Active:        saleActive(p, now),
+	}
+}
@@ -0,0 +1,347 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"AVTproject/models"
+)
+
+// scheduleLease — сколько захваченный перевод недоступен другим экземплярам
+// планировщика. Должно с запасом покрывать выполнение одного перевода.
+const scheduleLease = 5 * time.Minute
+
+type ScheduleRetry struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+type ScheduleRequest struct {
+	ToUser   string
+	Amount   int
+	Memo     string
+	Category string
+	RunAt    time.Time
+	Interval string
+}
+
+type ScheduledTransferInfo struct {
+	ID                int        `json:"id"`
+	ToUser            string     `json:"toUser"`
+	Amount            int        `json:"amount"`
+	Memo              string     `json:"memo,omitempty"`
+	Category          string     `json:"category,omitempty"`
+	Interval          string     `json:"interval,omitempty"`
+	Status            string     `json:"status"`
+	NextRunAt         time.Time  `json:"nextRunAt"`
+	Attempts          int        `json:"attempts,omitempty"`
+	LastError         string     `json:"lastError,omitempty"`
+	LastTransactionID int        `json:"lastTransactionId,omitempty"`
+	LastRunAt         *time.Time `json:"lastRunAt,omitempty"`
+	CreatedAt         time.Time  `json:"createdAt"`
+}
+
+type ScheduledFailedNotification struct {
+	ID        int        `json:"id"`
+	ToUser    string     `json:"toUser"`
+	Amount    int        `json:"amount"`
+	Attempts  int        `json:"attempts"`
+	Error     string     `json:"error"`
+	NextRunAt *time.Time `json:"nextRunAt,omitempty"`
+}
+
+// WithScheduleRetry задаёт число попыток выполнить запланированный перевод и
+// базовую задержку между ними (растёт линейно с номером попытки).
+func WithScheduleRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(s *Service) {
+		if maxAttempts > 0 {
+			s.scheduleRetry.MaxAttempts = maxAttempts
+		}
+		if backoff > 0 {
+			s.scheduleRetry.Backoff = backoff
+		}
+	}
+}
+
+func (s Service) CreateScheduledTransfer(ctx context.Context, userID int, req ScheduleRequest) (ScheduledTransferInfo, error) {
+	req, err := req.normalize()
+	if err != nil {
+		return ScheduledTransferInfo{}, err
+	}
+
+	var st models.ScheduledTransfer
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		receiver, err := s.repo.GetUserByUsername(ctx, req.ToUser)
+		if err != nil {
+			return err
+		}
+		if receiver.ID == userID {
+			return errors.New("нельзя запланировать перевод самому себе")
+		}
+		st = models.ScheduledTransfer{
+			FromUserID: userID,
+			ToUserID:   receiver.ID,
+			ToUsername: receiver.Username,
+			Amount:     req.Amount,
+			Memo:       req.Memo,
+			Category:   req.Category,
+			Interval:   req.Interval,
+			Status:     models.ScheduleActive,
+			NextRunAt:  req.RunAt,
+			RetryAt:    req.RunAt,
+			CreatedAt:  time.Now(),
+		}
+		st.ID, err = s.repo.CreateScheduledTransfer(ctx, st)
+		return err
+	})
+	if err != nil {
+		return ScheduledTransferInfo{}, err
+	}
+	return toScheduledTransferInfo(st), nil
+}
+
+func (s Service) ListScheduledTransfers(ctx context.Context, userID int) ([]ScheduledTransferInfo, error) {
+	transfers, err := s.repo.ListScheduledTransfers(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ScheduledTransferInfo, 0, len(transfers))
+	for _, st := range transfers {
+		result = append(result, toScheduledTransferInfo(st))
+	}
+	return result, nil
+}
+
+// UpdateScheduledTransfer меняет сумму, комментарий, время и периодичность
+// активного перевода. Получателя изменить нельзя — для этого перевод
+// отменяют и создают заново.
+func (s Service) UpdateScheduledTransfer(
+	ctx context.Context,
+	userID, id int,
+	req ScheduleRequest,
+) (ScheduledTransferInfo, error) {
+	req, err := req.normalize()
+	if err != nil {
+		return ScheduledTransferInfo{}, err
+	}
+
+	var st models.ScheduledTransfer
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		st, err = s.ownScheduledTransfer(ctx, userID, id)
+		if err != nil {
+			return err
+		}
+		if req.ToUser != st.ToUsername {
+			return errors.New("получателя запланированного перевода изменить нельзя")
+		}
+		st.Amount = req.Amount
+		st.Memo = req.Memo
+		st.Category = req.Category
+		st.Interval = req.Interval
+		st.NextRunAt = req.RunAt
+		st.RetryAt = req.RunAt
+		st.Attempts = 0
+		st.LastError = ""
+		return s.repo.UpdateScheduledTransfer(ctx, st)
+	})
+	if err != nil {
+		return ScheduledTransferInfo{}, err
+	}
+	return toScheduledTransferInfo(st), nil
+}
+
+func (s Service) CancelScheduledTransfer(ctx context.Context, userID, id int) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		st, err := s.ownScheduledTransfer(ctx, userID, id)
+		if err != nil {
+			return err
+		}
+		st.Status = models.ScheduleCancelled
+		return s.repo.UpdateScheduledTransfer(ctx, st)
+	})
+}
+
+// RunScheduledTransfers выполняет наступившие переводы. Каждый перевод
+// захватывается арендой и выполняется в своей транзакции вместе с переносом
+// на следующий период, поэтому несколько экземпляров приложения не выполнят
+// один и тот же перевод дважды.
+func (s Service) RunScheduledTransfers(ctx context.Context) error {
+	claimed, err := s.repo.ClaimDueScheduledTransfers(ctx, time.Now(), scheduleLease, 50)
+	if err != nil {
+		return err
+	}
+	for _, claim := range claimed {
+		if err := s.runScheduledTransfer(ctx, claim); err != nil {
+			log.Printf("Ошибка запланированного перевода %d: %v", claim.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s Service) runScheduledTransfer(ctx context.Context, claim models.ScheduledTransfer) error {
+	var sendErr error
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		st, err := s.repo.GetScheduledTransferForUpdate(ctx, claim.ID)
+		if err != nil {
+			return err
+		}
+		if !holdsLease(st, claim) {
+			return nil
+		}
+		result, err := s.SendCoin(ctx, st.FromUserID, st.ToUsername, st.Amount, TransferOptions{
+			Memo:     st.Memo,
+			Category: st.Category,
+		})
+		if err != nil {
+			sendErr = err
+			return err
+		}
+
+		now := time.Now()
+		st.LastRunAt = &now
+		st.LastTransactionID = result.TransactionID
+		st.LastError = ""
+		st.Attempts = 0
+		s.advanceSchedule(&st, now)
+		return s.repo.UpdateScheduledTransfer(ctx, st)
+	})
+	if sendErr == nil {
+		return err
+	}
+	return s.failScheduledTransfer(ctx, claim, sendErr)
+}
+
+// failScheduledTransfer записывает неудачную попытку. Пока попытки не
+// исчерпаны, перевод откладывается; после последней разовый перевод
+// помечается failed, а повторяющийся переносится на следующий период.
+// В обоих случаях владелец получает уведомление.
+func (s Service) failScheduledTransfer(ctx context.Context, claim models.ScheduledTransfer, sendErr error) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		st, err := s.repo.GetScheduledTransferForUpdate(ctx, claim.ID)
+		if err != nil {
+			return err
+		}
+		if !holdsLease(st, claim) {
+			return nil
+		}
+
+		now := time.Now()
+		st.Attempts++
+		st.LastError = sendErr.Error()
+		st.LastRunAt = &now
+		if st.Attempts < s.scheduleRetry.MaxAttempts {
+			st.RetryAt = now.Add(time.Duration(st.Attempts) * s.scheduleRetry.Backoff)
+			return s.repo.UpdateScheduledTransfer(ctx, st)
+		}
+
+		attempts := st.Attempts
+		st.Attempts = 0
+		s.advanceSchedule(&st, now)
+		if err := s.repo.UpdateScheduledTransfer(ctx, st); err != nil {
+			return err
+		}
+		notification := ScheduledFailedNotification{
+			ID:       st.ID,
+			ToUser:   st.ToUsername,
+			Amount:   st.Amount,
+			Attempts: attempts,
+			Error:    st.LastError,
+		}
+		if st.Status == models.ScheduleActive {
+			notification.NextRunAt = &st.NextRunAt
+		}
+		return s.notifyUser(ctx, st.FromUserID, models.NotificationScheduledFailed, notification)
+	})
+}
+
+// advanceSchedule переносит перевод на следующий период после now. Пропущенные
+// периоды (например, если приложение было остановлено) не догоняются.
+// Разовый перевод завершается: успешно или с ошибкой, если попытки исчерпаны.
+func (s Service) advanceSchedule(st *models.ScheduledTransfer, now time.Time) {
+	if st.Interval == models.IntervalOnce {
+		st.Status = models.ScheduleCompleted
+		if st.LastError != "" {
+			st.Status = models.ScheduleFailed
+		}
+		return
+	}
+	next := st.NextRunAt
+	for !next.After(now) {
+		next = nextRun(next, st.Interval)
+	}
+	st.NextRunAt = next
+	st.RetryAt = next
+}
+
+func nextRun(t time.Time, interval string) time.Time {
+	switch interval {
+	case models.IntervalDaily:
+		return t.AddDate(0, 0, 1)
+	case models.IntervalWeekly:
+		return t.AddDate(0, 0, 7)
+	default:
+		return t.AddDate(0, 1, 0)
+	}
+}
+
+// holdsLease проверяет, что перевод всё ещё активен и захвачен именно этим
+// исполнителем: если аренда истекла и перевод взял другой экземпляр,
+// retry_at уже будет другим.
+func holdsLease(st, claim models.ScheduledTransfer) bool {
+	return st.Status == models.ScheduleActive && st.RetryAt.Equal(claim.RetryAt)
+}
+
+func (s Service) ownScheduledTransfer(ctx context.Context, userID, id int) (models.ScheduledTransfer, error) {
+	st, err := s.repo.GetScheduledTransferForUpdate(ctx, id)
+	if err != nil {
+		return models.ScheduledTransfer{}, err
+	}
+	if st.FromUserID != userID {
+		return models.ScheduledTransfer{}, sql.ErrNoRows
+	}
+	if st.Status != models.ScheduleActive {
+		return models.ScheduledTransfer{}, errors.New("запланированный перевод уже не активен")
+	}
+	return st, nil
+}
+
+func (r ScheduleRequest) normalize() (ScheduleRequest, error) {
+	if r.ToUser == "" || r.Amount <= 0 {
+		return ScheduleRequest{}, errors.New("неверные параметры перевода")
+	}
+	if r.RunAt.IsZero() || !r.RunAt.After(time.Now()) {
+		return ScheduleRequest{}, errors.New("время перевода должно быть в будущем")
+	}
+	switch r.Interval {
+	case models.IntervalOnce, models.IntervalDaily, models.IntervalWeekly, models.IntervalMonthly:
+	default:
+		return ScheduleRequest{}, errors.New("неизвестная периодичность перевода")
+	}
+	opts, err := TransferOptions{Memo: r.Memo, Category: r.Category}.normalize()
+	if err != nil {
+		return ScheduleRequest{}, err
+	}
+	r.Memo, r.Category = opts.Memo, opts.Category
+	return r, nil
+}
+
+func toScheduledTransferInfo(st models.ScheduledTransfer) ScheduledTransferInfo {
+	return ScheduledTransferInfo{
+		ID:                st.ID,
+		ToUser:            st.ToUsername,
+		Amount:            st.Amount,
+		Memo:              st.Memo,
+		Category:          st.Category,
+		Interval:          st.Interval,
+		Status:            st.Status,
+		NextRunAt:         st.NextRunAt,
+		Attempts:          st.Attempts,
+		LastError:         st.LastError,
+		LastTransactionID: st.LastTransactionID,
+		LastRunAt:         st.LastRunAt,
+		CreatedAt:         st.CreatedAt,
+	}
+}
@@ -2,8 +2,13 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"sort"
 	"strconv"
 	"time"
 
@@ -16,36 +21,178 @@ import (
 //go:generate mockgen -destination=./mocks/mock_repository.go -package=mocks AVTproject/service Repository
 
 type Repository interface {
+	WithTx(ctx context.Context, fn func(ctx context.Context) error) error
+	LockUsers(ctx context.Context, ids ...int) error
 	GetUserByUsername(ctx context.Context, username string) (models.User, error)
 	GetUserByID(ctx context.Context, id int) (models.User, error)
 	CreateUser(ctx context.Context, username, password string) (int, error)
-	UpdateUserCoins(ctx context.Context, id, delta int) error
-	AddTransaction(ctx context.Context, fromUserID, toUserID, amount int) error
-	GetUserTransactions(ctx context.Context, userID int) ([]models.Transaction, []models.Transaction, error)
+	UpdateUserCoins(ctx context.Context, id, delta int) (int, error)
+	AdjustUserCoins(ctx context.Context, id, delta int, allowNegative bool) (int, error)
+	UpdateUserBonusCoins(ctx context.Context, id, delta int) (int, error)
+	ChargeUserCoins(ctx context.Context, id, amount, creditLimit int) (int, []models.CoinLot, error)
+	SetUserCreditLimit(ctx context.Context, userID, limit int) error
+	AddTransaction(ctx context.Context, t models.Transaction) (int, error)
+	GetUserTransactions(ctx context.Context, userID int, category string) ([]models.Transaction, []models.Transaction, error)
+	GetTransactionByID(ctx context.Context, id int) (models.Transaction, error)
+	HasReversal(ctx context.Context, transactionID int) (bool, error)
+	ListPublicTransfers(ctx context.Context, limit, offset int) ([]models.FeedEntry, error)
+	ListKudosReactions(ctx context.Context, transactionIDs []int) ([]models.KudosReaction, error)
+	AddKudosReaction(ctx context.Context, r models.KudosReaction) error
+	RemoveKudosReaction(ctx context.Context, r models.KudosReaction) error
+	GetTransferLimitOverride(ctx context.Context, userID int, role string) (models.TransferLimitOverride, error)
+	SetRoleTransferLimits(ctx context.Context, role string, o models.TransferLimitOverride) error
+	SetUserTransferLimits(ctx context.Context, userID int, o models.TransferLimitOverride) error
+	GetTransferUsage(ctx context.Context, userID int, dayStart, monthStart time.Time) (models.TransferUsage, error)
+	CreatePendingTransfer(ctx context.Context, p models.PendingTransfer) (int, error)
+	GetPendingTransferForUpdate(ctx context.Context, id int) (models.PendingTransfer, error)
+	ListPendingTransfers(ctx context.Context, f models.PendingTransferFilter) ([]models.PendingTransfer, error)
+	ClaimExpiredPendingTransfers(ctx context.Context, now time.Time, limit int) ([]models.PendingTransfer, error)
+	UpdatePendingTransfer(ctx context.Context, p models.PendingTransfer) error
+	CreateScheduledTransfer(ctx context.Context, st models.ScheduledTransfer) (int, error)
+	GetScheduledTransferForUpdate(ctx context.Context, id int) (models.ScheduledTransfer, error)
+	ListScheduledTransfers(ctx context.Context, fromUserID int) ([]models.ScheduledTransfer, error)
+	UpdateScheduledTransfer(ctx context.Context, st models.ScheduledTransfer) error
+	ClaimDueScheduledTransfers(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]models.ScheduledTransfer, error)
+	SetUserProfile(ctx context.Context, userID int, department string, active bool) error
+	ListGrantRecipients(ctx context.Context, department string) ([]models.User, error)
+	CreateGrantPolicy(ctx context.Context, p models.GrantPolicy) (int, error)
+	GetGrantPolicy(ctx context.Context, id int) (models.GrantPolicy, error)
+	ListGrantPolicies(ctx context.Context, activeOnly bool) ([]models.GrantPolicy, error)
+	DeactivateGrantPolicy(ctx context.Context, id int) error
+	CreateGrantRun(ctx context.Context, run models.GrantRun) (int, bool, error)
+	ListUsersWithExpiredCoinLots(ctx context.Context, now time.Time, limit int) ([]int, error)
+	SumExpiredCoinLots(ctx context.Context, userID int, now time.Time) (int, error)
+	ListCoinLots(ctx context.Context, userID int) ([]models.CoinLot, error)
+	WithdrawUserCoins(ctx context.Context, id, amount int) (int, []models.CoinLot, error)
+	DepositUserCoins(ctx context.Context, id, amount int, lots []models.CoinLot) (int, error)
+	ParkCoinLots(ctx context.Context, pendingTransferID int, lots []models.CoinLot) error
+	AddPurchaseCoinLots(ctx context.Context, purchaseID int, lots []models.CoinLot) error
+	TakePurchaseCoinLots(ctx context.Context, purchaseID int) ([]models.CoinLot, error)
+	TakeParkedCoinLots(ctx context.Context, pendingTransferID int) ([]models.CoinLot, error)
+	CreateBillSplit(ctx context.Context, b models.BillSplit) (int, error)
+	GetBillSplit(ctx context.Context, id int) (models.BillSplit, error)
+	ListBillSplits(ctx context.Context, creatorID int) ([]models.BillSplit, error)
+	CreateCoinRequest(ctx context.Context, r models.CoinRequest) (int, error)
+	GetCoinRequestForUpdate(ctx context.Context, id int) (models.CoinRequest, error)
+	GetCoinRequestByPaymentForUpdate(ctx context.Context, transactionID, pendingTransferID int) (models.CoinRequest, error)
+	ListCoinRequests(ctx context.Context, f models.CoinRequestFilter) ([]models.CoinRequest, error)
+	UpdateCoinRequest(ctx context.Context, r models.CoinRequest) error
+	ExpireCoinRequests(ctx context.Context, now time.Time) ([]models.CoinRequest, error)
 	GetUserPurchases(ctx context.Context, userID int) ([]models.Purchase, error)
-	AddPurchase(ctx context.Context, userID int, item string) error
+	AddPurchase(ctx context.Context, p models.Purchase) (int, error)
+	ListPurchases(ctx context.Context, userID int) ([]models.Purchase, error)
+	GetPurchaseForUpdate(ctx context.Context, id int) (models.Purchase, error)
+	DecrementPurchase(ctx context.Context, id int) error
+	RestockItemVariant(ctx context.Context, id, quantity int) error
+	CreatePurchaseReturn(ctx context.Context, r models.PurchaseReturn) (int, error)
+	GetPurchaseReturnForUpdate(ctx context.Context, id int) (models.PurchaseReturn, error)
+	ListPurchaseReturns(ctx context.Context, f models.PurchaseReturnFilter) ([]models.PurchaseReturn, error)
+	UpdatePurchaseReturn(ctx context.Context, r models.PurchaseReturn) error
+	CreatePriceSchedule(ctx context.Context, p models.PriceSchedule) (int, error)
+	ListPriceSchedules(ctx context.Context, item string, from time.Time) ([]models.PriceSchedule, error)
+	DeletePriceSchedule(ctx context.Context, id int) error
+	ListItemVariants(ctx context.Context, item string) ([]models.ItemVariant, error)
+	UpsertItemVariant(ctx context.Context, v models.ItemVariant) (models.ItemVariant, error)
+	TakeItemVariantStock(ctx context.Context, id int) (models.ItemVariant, error)
+	CreatePromoCode(ctx context.Context, p models.PromoCode) (int, error)
+	GetPromoCodeForUpdate(ctx context.Context, code string) (models.PromoCode, error)
+	ListPromoCodes(ctx context.Context) ([]models.PromoCode, error)
+	DeactivatePromoCode(ctx context.Context, id int) error
+	CountPromoRedemptions(ctx context.Context, promoID, userID int) (int, error)
+	AddPromoRedemption(ctx context.Context, r models.PromoRedemption) error
+	ReleasePromoRedemption(ctx context.Context, purchaseID int) error
+	CreateWebhookSubscription(ctx context.Context, sub models.WebhookSubscription) (int, error)
+	ListWebhookSubscriptions(ctx context.Context) ([]models.WebhookSubscription, error)
+	DeactivateWebhookSubscription(ctx context.Context, id int) error
+	ListWebhookDeliveries(ctx context.Context, subscriptionID, limit int) ([]models.WebhookDelivery, error)
+	AddOutboxEvent(ctx context.Context, e models.OutboxEvent) error
+	NotifyUser(ctx context.Context, payload []byte) error
+	AddAuditEntry(ctx context.Context, e models.AuditEntry) error
+	ListAuditEntries(ctx context.Context, f models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+type Subscriber interface {
+	Subscribe(userID int) (<-chan models.UserNotification, func())
 }
 
 type Service struct {
-	repo      Repository
-	jwtSecret string
+	repo              Repository
+	jwtSecret         string
+	admins            map[string]bool
+	managers          map[string]bool
+	subscriber        Subscriber
+	transferLimits    TransferLimits
+	approvalThreshold int
+	approvalTTL       time.Duration
+	acceptanceTTL     time.Duration
+	undoWindow        time.Duration
+	coinRequestTTL    time.Duration
+	returnWindow      time.Duration
+	scheduleRetry     ScheduleRetry
+}
+
+type Option func(*Service)
+
+func WithAdmins(usernames ...string) Option {
+	return func(s *Service) {
+		for _, username := range usernames {
+			if username != "" {
+				s.admins[username] = true
+			}
+		}
+	}
+}
+
+func WithManagers(usernames ...string) Option {
+	return func(s *Service) {
+		for _, username := range usernames {
+			if username != "" {
+				s.managers[username] = true
+			}
+		}
+	}
 }
 
-func NewService(repo Repository, jwtSecret string) Service {
-	return Service{
+func WithSubscriber(subscriber Subscriber) Option {
+	return func(s *Service) {
+		s.subscriber = subscriber
+	}
+}
+
+func NewService(repo Repository, jwtSecret string, opts ...Option) Service {
+	s := Service{
 		repo:      repo,
 		jwtSecret: jwtSecret,
+		admins:    make(map[string]bool),
+		managers:  make(map[string]bool),
+
+		approvalTTL:    72 * time.Hour,
+		acceptanceTTL:  72 * time.Hour,
+		coinRequestTTL: 7 * 24 * time.Hour,
+		returnWindow:   14 * 24 * time.Hour,
+		scheduleRetry:  ScheduleRetry{MaxAttempts: 3, Backoff: 5 * time.Minute},
 	}
+	for _, opt := range opts {
+		opt(&s)
+	}
+	return s
 }
 
 type InfoResponse struct {
-	Coins       int                 `json:"coins"`
-	Inventory   []InventoryItem     `json:"inventory"`
-	CoinHistory CoinHistoryResponse `json:"coinHistory"`
+	Coins             int                 `json:"coins"`
+	BonusCoins        int                 `json:"bonusCoins"`
+	CreditLimit       int                 `json:"creditLimit,omitempty"`
+	Debt              int                 `json:"debt,omitempty"`
+	Inventory         []InventoryItem     `json:"inventory"`
+	CoinHistory       CoinHistoryResponse `json:"coinHistory"`
+	TransferAllowance TransferAllowance   `json:"transferAllowance"`
+	ExpiringCoins     []CoinExpiration    `json:"expiringCoins"`
 }
 
 type InventoryItem struct {
 	Type     string `json:"type"`
+	Size     string `json:"size,omitempty"`
+	Color    string `json:"color,omitempty"`
 	Quantity int    `json:"quantity"`
 }
 
@@ -55,8 +202,87 @@ type CoinHistoryResponse struct {
 }
 
 type TransactionInfo struct {
-	OtherUser string `json:"otherUser"`
-	Amount    int    `json:"amount"`
+	ID         int    `json:"id"`
+	OtherUser  string `json:"otherUser"`
+	Amount     int    `json:"amount"`
+	Type       string `json:"type"`
+	Wallet     string `json:"wallet,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	Reference  string `json:"reference,omitempty"`
+	Memo       string `json:"memo,omitempty"`
+	Category   string `json:"category,omitempty"`
+	Public     bool   `json:"public,omitempty"`
+	ReversalOf int    `json:"reversalOf,omitempty"`
+}
+
+type CoinTransferEvent struct {
+	FromUser string `json:"fromUser"`
+	ToUser   string `json:"toUser"`
+	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+type ItemPurchasedEvent struct {
+	User      string `json:"user"`
+	Item      string `json:"item"`
+	Size      string `json:"size,omitempty"`
+	Color     string `json:"color,omitempty"`
+	Price     int    `json:"price"`
+	Discount  int    `json:"discount,omitempty"`
+	Recipient string `json:"recipient,omitempty"`
+}
+
+type CoinReceivedNotification struct {
+	FromUser string `json:"fromUser,omitempty"`
+	Amount   int    `json:"amount"`
+	Memo     string `json:"memo,omitempty"`
+	Category string `json:"category,omitempty"`
+}
+
+type BalanceChangedNotification struct {
+	Coins int `json:"coins"`
+	Delta int `json:"delta"`
+}
+
+type PurchaseCompletedNotification struct {
+	Item      string `json:"item"`
+	Size      string `json:"size,omitempty"`
+	Color     string `json:"color,omitempty"`
+	Price     int    `json:"price"`
+	Discount  int    `json:"discount,omitempty"`
+	PaidBonus int    `json:"paidBonus,omitempty"`
+	GiftTo    string `json:"giftTo,omitempty"`
+}
+
+// CatalogItem описывает товар каталога. Price — текущая цена с учётом
+// распродажи, OriginalPrice заполняется, только если она отличается.
+type CatalogItem struct {
+	Name          string           `json:"name"`
+	Price         int              `json:"price"`
+	OriginalPrice int              `json:"originalPrice,omitempty"`
+	Variants      []CatalogVariant `json:"variants,omitempty"`
+}
+
+type CatalogVariant struct {
+	Size          string `json:"size,omitempty"`
+	Color         string `json:"color,omitempty"`
+	Price         int    `json:"price"`
+	OriginalPrice int    `json:"originalPrice,omitempty"`
+	Stock         int    `json:"stock"`
+}
+
+var merchPrices = map[string]int{
+	"t-shirt":    80,
+	"cup":        20,
+	"book":       50,
+	"pen":        10,
+	"powerbank":  200,
+	"hoody":      300,
+	"umbrella":   200,
+	"socks":      10,
+	"wallet":     50,
+	"pink-hoody": 500,
 }
 
 func (s Service) Authenticate(
@@ -66,29 +292,49 @@ func (s Service) Authenticate(
 	user, err := s.repo.GetUserByUsername(ctx, username)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
+			// Администраторы и руководители из конфигурации должны уже иметь
+			// учётную запись: иначе их роль получил бы первый, кто войдёт под этим именем.
+			if s.admins[username] || s.managers[username] {
+				return "", errors.New("учетная запись не найдена")
+			}
 			hashed, err := bcryptHash(password)
 			if err != nil {
 				return "", err
 			}
-			userID, err := s.repo.CreateUser(ctx, username, hashed)
+			err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+				userID, err := s.repo.CreateUser(ctx, username, hashed)
+				if err != nil {
+					return err
+				}
+				user = models.User{
+					ID:       userID,
+					Username: username,
+					Password: hashed,
+					Coins:    1000,
+					Role:     models.RoleUser,
+				}
+				return s.audit(ctx, userID, AuditAuthRegister, userTarget(username), nil, map[string]int{"coins": user.Coins})
+			})
 			if err != nil {
 				return "", err
 			}
-			user = models.User{
-				ID:       userID,
-				Username: username,
-				Password: hashed,
-				Coins:    1000,
-			}
 		} else {
 			return "", err
 		}
 	} else {
 		if !bcryptCompare(user.Password, password) {
+			if err := s.audit(ctx, user.ID, AuditAuthLoginFailed, userTarget(username), nil, nil); err != nil {
+				log.Printf("Ошибка записи в журнал аудита: %v", err)
+			}
 			return "", errors.New("неверные учетные данные")
 		}
+		// Как и для неудачных попыток, сбой журнала не должен блокировать вход.
+		if err := s.audit(ctx, user.ID, AuditAuthLogin, userTarget(username), nil, nil); err != nil {
+			log.Printf("Ошибка записи в журнал аудита: %v", err)
+		}
 	}
 
+	user.Role = s.roleOf(user)
 	token, err := generateJWT(user, s.jwtSecret)
 	if err != nil {
 		return "", err
@@ -108,7 +354,15 @@ func (s Service) GetInfo(
 	if err != nil {
 		return InfoResponse{}, err
 	}
-	rec, sent, err := s.repo.GetUserTransactions(ctx, userID)
+	history, err := s.GetHistory(ctx, userID, "")
+	if err != nil {
+		return InfoResponse{}, err
+	}
+	allowance, err := s.transferAllowance(ctx, user)
+	if err != nil {
+		return InfoResponse{}, err
+	}
+	expiring, err := s.upcomingExpirations(ctx, userID)
 	if err != nil {
 		return InfoResponse{}, err
 	}
@@ -119,38 +373,48 @@ func (s Service) GetInfo(
 			inventory,
 			InventoryItem{
 				Type:     p.Item,
+				Size:     p.Size,
+				Color:    p.Color,
 				Quantity: p.Quantity,
 			},
 		)
 	}
 
+	return InfoResponse{
+		Coins:             user.Coins,
+		BonusCoins:        user.BonusCoins,
+		CreditLimit:       user.CreditLimit,
+		Debt:              max(-user.Coins, 0),
+		Inventory:         inventory,
+		CoinHistory:       history,
+		TransferAllowance: allowance,
+		ExpiringCoins:     expiring,
+	}, nil
+}
+
+func (s Service) GetHistory(
+	ctx context.Context,
+	userID int,
+	category string,
+) (CoinHistoryResponse, error) {
+	if category != "" && !transferCategories[category] {
+		return CoinHistoryResponse{}, errors.New("неизвестная категория перевода")
+	}
+	rec, sent, err := s.repo.GetUserTransactions(ctx, userID, category)
+	if err != nil {
+		return CoinHistoryResponse{}, err
+	}
+
 	var received []TransactionInfo
 	for _, t := range rec {
-		received = append(
-			received,
-			TransactionInfo{
-				OtherUser: itoa(t.FromUserID),
-				Amount:    t.Amount,
-			},
-		)
+		received = append(received, toTransactionInfo(t, t.FromUserID))
 	}
 
 	var sentInfo []TransactionInfo
 	for _, t := range sent {
-		sentInfo = append(
-			sentInfo,
-			TransactionInfo{
-				OtherUser: itoa(t.ToUserID),
-				Amount:    t.Amount,
-			},
-		)
+		sentInfo = append(sentInfo, toTransactionInfo(t, t.ToUserID))
 	}
-
-	return InfoResponse{
-		Coins:       user.Coins,
-		Inventory:   inventory,
-		CoinHistory: CoinHistoryResponse{Received: received, Sent: sentInfo},
-	}, nil
+	return CoinHistoryResponse{Received: received, Sent: sentInfo}, nil
 }
 
 func (s Service) SendCoin(
@@ -158,45 +422,508 @@ func (s Service) SendCoin(
 	fromUserID int,
 	toUsername string,
 	amount int,
-) error {
-	receiver, err := s.repo.GetUserByUsername(ctx, toUsername)
+	opts TransferOptions,
+) (TransferResult, error) {
+	opts, err := opts.normalize()
 	if err != nil {
-		return err
+		return TransferResult{}, err
 	}
-	if err := s.repo.UpdateUserCoins(ctx, fromUserID, -amount); err != nil {
-		return err
+	var result TransferResult
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		receiver, err := s.repo.GetUserByUsername(ctx, toUsername)
+		if err != nil {
+			return err
+		}
+		if err := s.repo.LockUsers(ctx, fromUserID, receiver.ID); err != nil {
+			return err
+		}
+		sender, err := s.repo.GetUserByID(ctx, fromUserID)
+		if err != nil {
+			return err
+		}
+		if err := s.checkTransferLimits(ctx, sender, receiver.ID, amount); err != nil {
+			return err
+		}
+		if s.approvalThreshold > 0 && amount > s.approvalThreshold {
+			if opts.RequiresAcceptance {
+				return errors.New("перевод, требующий согласования, нельзя отправить с подтверждением получателем")
+			}
+			result, err = s.holdTransfer(ctx, models.PendingKindApproval, sender, receiver, amount, opts)
+			return err
+		}
+		if opts.RequiresAcceptance {
+			result, err = s.holdTransfer(ctx, models.PendingKindEscrow, sender, receiver, amount, opts)
+			return err
+		}
+
+		spent, err := s.spend(ctx, sender, operationTransfer, amount)
+		if err != nil {
+			return err
+		}
+		senderCoins := spent.Coins
+		receiverCoins, transactionID, err := s.settleTransfer(ctx, sender, receiver, amount, spent.Lots, opts)
+		if err != nil {
+			return err
+		}
+		if err := s.audit(
+			ctx,
+			sender.ID,
+			AuditTransfer,
+			userTarget(receiver.Username),
+			map[string]int{"senderCoins": senderCoins + amount, "receiverCoins": receiverCoins - amount},
+			map[string]int{"senderCoins": senderCoins, "receiverCoins": receiverCoins, "amount": amount},
+		); err != nil {
+			return err
+		}
+		result = TransferResult{Status: TransferStatusCompleted, TransactionID: transactionID}
+		return s.notifyBalance(ctx, sender.ID, senderCoins, -amount)
+	})
+	if err != nil {
+		return TransferResult{}, err
 	}
-	if err := s.repo.UpdateUserCoins(ctx, receiver.ID, amount); err != nil {
-		return err
+	return result, nil
+}
+
+// settleTransfer зачисляет монеты получателю, уже списанные у отправителя,
+// и записывает транзакцию, события и уведомления получателю. lots — партии,
+// израсходованные у отправителя: их сроки действия переходят получателю.
+func (s Service) settleTransfer(
+	ctx context.Context,
+	sender, receiver models.User,
+	amount int,
+	lots []models.CoinLot,
+	opts TransferOptions,
+) (int, int, error) {
+	receiverCoins, err := s.repo.DepositUserCoins(ctx, receiver.ID, amount, lots)
+	if err != nil {
+		return 0, 0, err
+	}
+	transactionID, err := s.repo.AddTransaction(ctx, models.Transaction{
+		FromUserID: sender.ID,
+		ToUserID:   receiver.ID,
+		Amount:     amount,
+		Type:       models.TransactionTypeTransfer,
+		Memo:       opts.Memo,
+		Category:   opts.Category,
+		Public:     opts.Public,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	data := CoinTransferEvent{
+		FromUser: sender.Username,
+		ToUser:   receiver.Username,
+		Amount:   amount,
+		Memo:     opts.Memo,
+		Category: opts.Category,
+	}
+	if err := s.recordEvent(ctx, models.EventCoinSent, sender.ID, data); err != nil {
+		return 0, 0, err
+	}
+	if err := s.recordEvent(ctx, models.EventCoinReceived, receiver.ID, data); err != nil {
+		return 0, 0, err
+	}
+
+	if err := s.notifyBalance(ctx, receiver.ID, receiverCoins, amount); err != nil {
+		return 0, 0, err
+	}
+	err = s.notifyUser(ctx, receiver.ID, models.NotificationCoinReceived, CoinReceivedNotification{
+		FromUser: sender.Username,
+		Amount:   amount,
+		Memo:     opts.Memo,
+		Category: opts.Category,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return receiverCoins, transactionID, nil
+}
+
+func (s Service) GetBalance(
+	ctx context.Context,
+	username string,
+) (int, error) {
+	user, err := s.repo.GetUserByUsername(ctx, username)
+	if err != nil {
+		return 0, err
 	}
-	return s.repo.AddTransaction(ctx, fromUserID, receiver.ID, amount)
+	return user.Coins, nil
+}
+
+func (s Service) CreditCoins(
+	ctx context.Context,
+	username string,
+	amount int,
+) (int, error) {
+	if amount <= 0 {
+		return 0, errors.New("сумма начисления должна быть положительной")
+	}
+	var coins int
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		user, err := s.repo.GetUserByUsername(ctx, username)
+		if err != nil {
+			return err
+		}
+		coins, err = s.repo.UpdateUserCoins(ctx, user.ID, amount)
+		if err != nil {
+			return err
+		}
+		if _, err := s.repo.AddTransaction(ctx, models.Transaction{
+			ToUserID: user.ID,
+			Amount:   amount,
+			Type:     models.TransactionTypeCredit,
+		}); err != nil {
+			return err
+		}
+		if err := s.audit(
+			ctx,
+			0,
+			AuditCredit,
+			userTarget(user.Username),
+			map[string]int{"coins": coins - amount},
+			map[string]int{"coins": coins, "amount": amount},
+		); err != nil {
+			return err
+		}
+		if err := s.recordEvent(ctx, models.EventCoinReceived, user.ID, CoinTransferEvent{
+			ToUser: user.Username,
+			Amount: amount,
+		}); err != nil {
+			return err
+		}
+
+		if err := s.notifyBalance(ctx, user.ID, coins, amount); err != nil {
+			return err
+		}
+		return s.notifyUser(ctx, user.ID, models.NotificationCoinReceived, CoinReceivedNotification{
+			Amount: amount,
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+	return coins, nil
+}
+
+func (s Service) ListItems(ctx context.Context) ([]CatalogItem, error) {
+	variants, err := s.repo.ListItemVariants(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	sales, err := s.salePrices(ctx, "", time.Now())
+	if err != nil {
+		return nil, err
+	}
+	byItem := make(map[string][]CatalogVariant)
+	for _, v := range variants {
+		sale, onSale := sales[v.Item]
+		cv := CatalogVariant{
+			Size:  v.Size,
+			Color: v.Color,
+			Price: applySale(variantPrice(v), sale, onSale),
+			Stock: v.Stock,
+		}
+		if cv.Price != variantPrice(v) {
+			cv.OriginalPrice = variantPrice(v)
+		}
+		byItem[v.Item] = append(byItem[v.Item], cv)
+	}
+
+	items := make([]CatalogItem, 0, len(merchPrices))
+	for name, price := range merchPrices {
+		sale, onSale := sales[name]
+		item := CatalogItem{Name: name, Price: applySale(price, sale, onSale), Variants: byItem[name]}
+		if item.Price != price {
+			item.OriginalPrice = price
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].Name < items[j].Name
+	})
+	return items, nil
+}
+
+// PurchaseOptions уточняет покупку: размер и цвет обязательны для товаров,
+// у которых в каталоге есть варианты, PromoCode необязателен. Если указан
+// GiftTo, товар попадает в инвентарь получателя, а платит покупатель.
+type PurchaseOptions struct {
+	Size        string
+	Color       string
+	PromoCode   string
+	GiftTo      string
+	GiftMessage string
 }
 
 func (s Service) BuyItem(
 	ctx context.Context,
 	userID int,
 	item string,
+	opts PurchaseOptions,
 ) error {
-	merchPrices := map[string]int{
-		"t-shirt":    80,
-		"cup":        20,
-		"book":       50,
-		"pen":        10,
-		"powerbank":  200,
-		"hoody":      300,
-		"umbrella":   200,
-		"socks":      10,
-		"wallet":     50,
-		"pink-hoody": 500,
-	}
 	price, ok := merchPrices[item]
 	if !ok {
 		return errors.New("неверное название мерча")
 	}
-	if err := s.repo.UpdateUserCoins(ctx, userID, -price); err != nil {
+	variants, err := s.repo.ListItemVariants(ctx, item)
+	if err != nil {
+		return err
+	}
+	variant, err := selectVariant(variants, opts)
+	if err != nil {
+		return err
+	}
+	sales, err := s.salePrices(ctx, item, time.Now())
+	if err != nil {
+		return err
+	}
+	sale, onSale := sales[item]
+	recipient, err := s.giftRecipient(ctx, userID, &opts)
+	if err != nil {
 		return err
 	}
-	return s.repo.AddPurchase(ctx, userID, item)
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		var variantID int
+		if variant != nil {
+			v, err := s.repo.TakeItemVariantStock(ctx, variant.ID)
+			if errors.Is(err, sql.ErrNoRows) {
+				return errors.New("выбранный вариант мерча закончился")
+			}
+			if err != nil {
+				return err
+			}
+			variantID = v.ID
+			price = variantPrice(v)
+		}
+		originalPrice := price
+		price = applySale(price, sale, onSale)
+		var promo models.PromoRedemption
+		if opts.PromoCode != "" {
+			if promo, err = s.checkPromoCode(ctx, userID, opts.PromoCode, item, price); err != nil {
+				return err
+			}
+			price -= promo.Discount
+		}
+		discount := promo.Discount
+		if err := s.repo.LockUsers(ctx, userID); err != nil {
+			return err
+		}
+		user, err := s.repo.GetUserByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		spent, err := s.spend(ctx, user, operationPurchase, price)
+		if err != nil {
+			return err
+		}
+		purchase := models.Purchase{
+			UserID:        userID,
+			Item:          item,
+			VariantID:     variantID,
+			Price:         price,
+			PaidBonus:     spent.Bonus,
+			OriginalPrice: originalPrice,
+		}
+		if recipient.ID != 0 {
+			purchase.UserID = recipient.ID
+			purchase.BuyerID = userID
+			purchase.GiftMessage = opts.GiftMessage
+		}
+		purchaseID, err := s.repo.AddPurchase(ctx, purchase)
+		if err != nil {
+			return err
+		}
+		// Подарки не возвращаются, поэтому партии запоминаются только для своих покупок.
+		if recipient.ID == 0 && len(spent.Lots) > 0 {
+			if err := s.repo.AddPurchaseCoinLots(ctx, purchaseID, spent.Lots); err != nil {
+				return err
+			}
+		}
+		if promo.PromoID != 0 {
+			promo.PurchaseID = purchaseID
+			if err := s.repo.AddPromoRedemption(ctx, promo); err != nil {
+				return err
+			}
+		}
+		target := "item:" + item
+		var size, color string
+		if variant != nil {
+			target += ":" + variantLabel(*variant)
+			size, color = variant.Size, variant.Color
+		}
+		if err := s.audit(
+			ctx,
+			userID,
+			AuditPurchase,
+			target,
+			map[string]int{"coins": user.Coins, "bonusCoins": user.BonusCoins},
+			map[string]interface{}{
+				"coins":         spent.Coins,
+				"bonusCoins":    spent.BonusCoins,
+				"price":         price,
+				"originalPrice": originalPrice,
+				"discount":      discount,
+				"giftTo":        recipient.Username,
+			},
+		); err != nil {
+			return err
+		}
+		if err := s.recordEvent(ctx, models.EventItemPurchased, userID, ItemPurchasedEvent{
+			User:      user.Username,
+			Item:      item,
+			Size:      size,
+			Color:     color,
+			Price:     price,
+			Discount:  discount,
+			Recipient: recipient.Username,
+		}); err != nil {
+			return err
+		}
+
+		if spent.Regular > 0 {
+			if err := s.notifyBalance(ctx, userID, spent.Coins, -spent.Regular); err != nil {
+				return err
+			}
+		}
+		if err := s.notifyUser(ctx, userID, models.NotificationPurchaseCompleted, PurchaseCompletedNotification{
+			Item:      item,
+			Size:      size,
+			Color:     color,
+			Price:     price,
+			Discount:  discount,
+			PaidBonus: spent.Bonus,
+			GiftTo:    recipient.Username,
+		}); err != nil {
+			return err
+		}
+		if recipient.ID == 0 {
+			return nil
+		}
+		return s.notifyUser(ctx, recipient.ID, models.NotificationGiftReceived, GiftReceivedNotification{
+			FromUser: user.Username,
+			Item:     item,
+			Size:     size,
+			Color:    color,
+			Message:  opts.GiftMessage,
+		})
+	})
+}
+
+func (s Service) SubscribeEvents(userID int) (<-chan models.UserNotification, func(), error) {
+	if s.subscriber == nil {
+		return nil, nil, errors.New("уведомления недоступны")
+	}
+	events, unsubscribe := s.subscriber.Subscribe(userID)
+	return events, unsubscribe, nil
+}
+
+// UserRole возвращает действующую роль пользователя. Роль вычисляется при каждом
+// запросе, а не берётся из токена, чтобы изменение ADMIN_USERS, MANAGER_USERS
+// или профиля вступало в силу сразу.
+func (s Service) UserRole(ctx context.Context, userID int) (string, error) {
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return s.roleOf(user), nil
+}
+
+func (s Service) roleOf(user models.User) string {
+	switch {
+	case s.admins[user.Username]:
+		return models.RoleAdmin
+	case s.managers[user.Username]:
+		return models.RoleManager
+	}
+	return user.Role
+}
+
+func (s Service) notifyBalance(ctx context.Context, userID, coins, delta int) error {
+	return s.notifyUser(ctx, userID, models.NotificationBalanceChanged, BalanceChangedNotification{
+		Coins: coins,
+		Delta: delta,
+	})
+}
+
+// notifyUser отправляет уведомление через NOTIFY: Postgres доставит его
+// слушателям всех экземпляров сервиса только после фиксации транзакции.
+func (s Service) notifyUser(
+	ctx context.Context,
+	userID int,
+	notificationType string,
+	data interface{},
+) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(models.UserNotification{
+		UserID: userID,
+		Type:   notificationType,
+		Data:   raw,
+	})
+	if err != nil {
+		return err
+	}
+	return s.repo.NotifyUser(ctx, payload)
+}
+
+func (s Service) recordEvent(
+	ctx context.Context,
+	eventType string,
+	userID int,
+	data interface{},
+) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	eventID, err := newEventID()
+	if err != nil {
+		return err
+	}
+	return s.repo.AddOutboxEvent(ctx, models.OutboxEvent{
+		EventID:   eventID,
+		Type:      eventType,
+		UserID:    userID,
+		Payload:   payload,
+		CreatedAt: time.Now().UTC(),
+	})
+}
+
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func toTransactionInfo(t models.Transaction, otherUserID int) TransactionInfo {
+	var otherUser string
+	if otherUserID != 0 {
+		otherUser = itoa(otherUserID)
+	}
+	var wallet string
+	if t.Wallet == models.WalletBonus {
+		wallet = t.Wallet
+	}
+	return TransactionInfo{
+		ID:         t.ID,
+		OtherUser:  otherUser,
+		Amount:     t.Amount,
+		Type:       t.Type,
+		Wallet:     wallet,
+		Reason:     t.Reason,
+		Reference:  t.Reference,
+		Memo:       t.Memo,
+		Category:   t.Category,
+		Public:     t.Public,
+		ReversalOf: t.ReversalOf,
+	}
 }
 
 func itoa(n int) string {
@@ -231,6 +958,7 @@ func generateJWT(
 		jwt.MapClaims{
 			"user_id":  user.ID,
 			"username": user.Username,
+			"role":     user.Role,
 			"exp":      time.Now().Add(24 * time.Hour).Unix(),
 		},
 	)
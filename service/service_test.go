@@ -3,8 +3,10 @@ package service_test
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"testing"
+	"time"
 
 	"AVTproject/models"
 	"AVTproject/service"
@@ -17,6 +19,50 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+func expectTx(mr *mocks.MockRepository) {
+	mr.EXPECT().
+		WithTx(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, fn func(ctx context.Context) error) error {
+			return fn(ctx)
+		})
+}
+
+type outboxEventMatcher struct {
+	eventType string
+	userID    int
+}
+
+func outboxEvent(eventType string, userID int) gomock.Matcher {
+	return outboxEventMatcher{eventType: eventType, userID: userID}
+}
+
+func (m outboxEventMatcher) Matches(x interface{}) bool {
+	e, ok := x.(models.OutboxEvent)
+	return ok && e.Type == m.eventType && e.UserID == m.userID && e.EventID != ""
+}
+
+func (m outboxEventMatcher) String() string {
+	return "outbox event " + m.eventType
+}
+
+type auditEntryMatcher struct {
+	action  string
+	actorID int
+}
+
+func auditEntry(action string, actorID int) gomock.Matcher {
+	return auditEntryMatcher{action: action, actorID: actorID}
+}
+
+func (m auditEntryMatcher) Matches(x interface{}) bool {
+	e, ok := x.(models.AuditEntry)
+	return ok && e.Action == m.action && e.ActorID == m.actorID
+}
+
+func (m auditEntryMatcher) String() string {
+	return "audit entry " + m.action
+}
+
 func TestService_Authenticate(t *testing.T) {
 	type fields struct {
 		prepareRepository func(*mocks.MockRepository)
@@ -39,9 +85,13 @@ func TestService_Authenticate(t *testing.T) {
 					mr.EXPECT().
 						GetUserByUsername(gomock.Any(), "newuser").
 						Return(models.User{}, sql.ErrNoRows)
+					expectTx(mr)
 					mr.EXPECT().
 						CreateUser(gomock.Any(), "newuser", gomock.Any()).
 						Return(1, nil)
+					mr.EXPECT().
+						AddAuditEntry(gomock.Any(), auditEntry(service.AuditAuthRegister, 1)).
+						Return(nil)
 				},
 			},
 			args: args{
@@ -66,6 +116,29 @@ func TestService_Authenticate(t *testing.T) {
 					mr.EXPECT().
 						GetUserByUsername(gomock.Any(), "existing").
 						Return(user, nil)
+					mr.EXPECT().
+						AddAuditEntry(gomock.Any(), auditEntry(service.AuditAuthLogin, 2)).
+						Return(nil)
+				},
+			},
+			args: args{
+				username: "existing",
+				password: "pass",
+			},
+			wantErr:    false,
+			wantUserID: 2,
+		},
+		{
+			name: "Audit failure does not block login",
+			fields: fields{
+				prepareRepository: func(mr *mocks.MockRepository) {
+					hashed, _ := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.DefaultCost)
+					mr.EXPECT().
+						GetUserByUsername(gomock.Any(), "existing").
+						Return(models.User{ID: 2, Username: "existing", Password: string(hashed), Coins: 1000}, nil)
+					mr.EXPECT().
+						AddAuditEntry(gomock.Any(), auditEntry(service.AuditAuthLogin, 2)).
+						Return(errors.New("журнал недоступен"))
 				},
 			},
 			args: args{
@@ -90,6 +163,9 @@ func TestService_Authenticate(t *testing.T) {
 					mr.EXPECT().
 						GetUserByUsername(gomock.Any(), "existing").
 						Return(user, nil)
+					mr.EXPECT().
+						AddAuditEntry(gomock.Any(), auditEntry(service.AuditAuthLoginFailed, 3)).
+						Return(nil)
 				},
 			},
 			args: args{
@@ -149,9 +225,16 @@ func TestService_BuyItem_InsufficientCoins(t *testing.T) {
 			name: "Insufficient coins for t-shirt",
 			fields: fields{
 				prepareRepository: func(mr *mocks.MockRepository) {
+					mr.EXPECT().ListItemVariants(gomock.Any(), "t-shirt").Return(nil, nil)
+					mr.EXPECT().ListPriceSchedules(gomock.Any(), "t-shirt", gomock.Any()).Return(nil, nil)
+					expectTx(mr)
+					mr.EXPECT().LockUsers(gomock.Any(), 3).Return(nil)
 					mr.EXPECT().
-						UpdateUserCoins(gomock.Any(), 3, -80).
-						Return(errors.New("недостаточно монет"))
+						GetUserByID(gomock.Any(), 3).
+						Return(models.User{ID: 3, Username: "testuser3", Coins: 50}, nil)
+					mr.EXPECT().
+						WithdrawUserCoins(gomock.Any(), 3, 80).
+						Return(0, nil, errors.New("недостаточно монет"))
 				},
 			},
 			args: args{
@@ -172,7 +255,383 @@ func TestService_BuyItem_InsufficientCoins(t *testing.T) {
 			tt.fields.prepareRepository(mockRepo)
 
 			svc := service.NewService(mockRepo, "secret")
-			err := svc.BuyItem(ctx, tt.args.userID, tt.args.item)
+			err := svc.BuyItem(ctx, tt.args.userID, tt.args.item, service.PurchaseOptions{})
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestService_BuyItem_Wallets(t *testing.T) {
+	tests := []struct {
+		name              string
+		prepareRepository func(*mocks.MockRepository)
+	}{
+		{
+			name: "Bonus covers part of the price",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().ListItemVariants(gomock.Any(), "t-shirt").Return(nil, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "t-shirt", gomock.Any()).Return(nil, nil)
+				expectTx(mr)
+				mr.EXPECT().LockUsers(gomock.Any(), 3).Return(nil)
+				mr.EXPECT().
+					GetUserByID(gomock.Any(), 3).
+					Return(models.User{ID: 3, Username: "testuser3", Coins: 1000, BonusCoins: 50}, nil)
+				mr.EXPECT().UpdateUserBonusCoins(gomock.Any(), 3, -50).Return(0, nil)
+				mr.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 30).Return(970, nil, nil)
+				mr.EXPECT().AddPurchase(gomock.Any(), models.Purchase{
+					UserID: 3, Item: "t-shirt", Price: 80, PaidBonus: 50, OriginalPrice: 80,
+				}).Return(1, nil)
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditPurchase, 3)).Return(nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), outboxEvent(models.EventItemPurchased, 3)).Return(nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+			},
+		},
+		{
+			name: "Bonus covers the whole price",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().ListItemVariants(gomock.Any(), "t-shirt").Return(nil, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "t-shirt", gomock.Any()).Return(nil, nil)
+				expectTx(mr)
+				mr.EXPECT().LockUsers(gomock.Any(), 3).Return(nil)
+				mr.EXPECT().
+					GetUserByID(gomock.Any(), 3).
+					Return(models.User{ID: 3, Username: "testuser3", Coins: 1000, BonusCoins: 200}, nil)
+				mr.EXPECT().UpdateUserBonusCoins(gomock.Any(), 3, -80).Return(120, nil)
+				mr.EXPECT().AddPurchase(gomock.Any(), models.Purchase{
+					UserID: 3, Item: "t-shirt", Price: 80, PaidBonus: 80, OriginalPrice: 80,
+				}).Return(1, nil)
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditPurchase, 3)).Return(nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), outboxEvent(models.EventItemPurchased, 3)).Return(nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "Purchase on credit",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().ListItemVariants(gomock.Any(), "t-shirt").Return(nil, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "t-shirt", gomock.Any()).Return(nil, nil)
+				expectTx(mr)
+				mr.EXPECT().LockUsers(gomock.Any(), 3).Return(nil)
+				mr.EXPECT().
+					GetUserByID(gomock.Any(), 3).
+					Return(models.User{ID: 3, Username: "testuser3", Coins: 30, CreditLimit: 100}, nil)
+				mr.EXPECT().ChargeUserCoins(gomock.Any(), 3, 80, 100).Return(-50, nil, nil)
+				mr.EXPECT().AddPurchase(gomock.Any(), models.Purchase{
+					UserID: 3, Item: "t-shirt", Price: 80, OriginalPrice: 80,
+				}).Return(1, nil)
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditPurchase, 3)).Return(nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), outboxEvent(models.EventItemPurchased, 3)).Return(nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			require.NoError(t, svc.BuyItem(context.Background(), 3, "t-shirt", service.PurchaseOptions{}))
+		})
+	}
+}
+
+func TestService_BuyItem_PromoCode(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	promo := models.PromoCode{
+		ID: 5, Code: "SUMMER", Kind: models.DiscountPercent, Value: 25,
+		StartsAt: past.Add(-time.Hour), PerUserLimit: 1, Active: true,
+	}
+	tests := []struct {
+		name              string
+		prepareRepository func(*mocks.MockRepository)
+		wantErr           bool
+	}{
+		{
+			name: "Percentage discount",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().ListItemVariants(gomock.Any(), "t-shirt").Return(nil, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "t-shirt", gomock.Any()).Return(nil, nil)
+				expectTx(mr)
+				mr.EXPECT().GetPromoCodeForUpdate(gomock.Any(), "SUMMER").Return(promo, nil)
+				mr.EXPECT().CountPromoRedemptions(gomock.Any(), 5, 3).Return(0, nil)
+				mr.EXPECT().AddPromoRedemption(gomock.Any(), models.PromoRedemption{
+					PromoID: 5, UserID: 3, Item: "t-shirt", Discount: 20, PurchaseID: 1,
+				}).Return(nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3).Return(nil)
+				mr.EXPECT().
+					GetUserByID(gomock.Any(), 3).
+					Return(models.User{ID: 3, Username: "testuser3", Coins: 1000}, nil)
+				mr.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 60).Return(940, nil, nil)
+				mr.EXPECT().AddPurchase(gomock.Any(), models.Purchase{
+					UserID: 3, Item: "t-shirt", Price: 60, OriginalPrice: 80,
+				}).Return(1, nil)
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditPurchase, 3)).Return(nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), outboxEvent(models.EventItemPurchased, 3)).Return(nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+			},
+		},
+		{
+			name: "Per-user limit reached",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().ListItemVariants(gomock.Any(), "t-shirt").Return(nil, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "t-shirt", gomock.Any()).Return(nil, nil)
+				expectTx(mr)
+				mr.EXPECT().GetPromoCodeForUpdate(gomock.Any(), "SUMMER").Return(promo, nil)
+				mr.EXPECT().CountPromoRedemptions(gomock.Any(), 5, 3).Return(1, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Global limit reached",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				exhausted := promo
+				exhausted.MaxRedemptions, exhausted.Redemptions = 10, 10
+				mr.EXPECT().ListItemVariants(gomock.Any(), "t-shirt").Return(nil, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "t-shirt", gomock.Any()).Return(nil, nil)
+				expectTx(mr)
+				mr.EXPECT().GetPromoCodeForUpdate(gomock.Any(), "SUMMER").Return(exhausted, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Expired code",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expired := promo
+				expired.EndsAt = &past
+				mr.EXPECT().ListItemVariants(gomock.Any(), "t-shirt").Return(nil, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "t-shirt", gomock.Any()).Return(nil, nil)
+				expectTx(mr)
+				mr.EXPECT().GetPromoCodeForUpdate(gomock.Any(), "SUMMER").Return(expired, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Code for another item",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				other := promo
+				other.Item = "hoody"
+				mr.EXPECT().ListItemVariants(gomock.Any(), "t-shirt").Return(nil, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "t-shirt", gomock.Any()).Return(nil, nil)
+				expectTx(mr)
+				mr.EXPECT().GetPromoCodeForUpdate(gomock.Any(), "SUMMER").Return(other, nil)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			err := svc.BuyItem(context.Background(), 3, "t-shirt", service.PurchaseOptions{PromoCode: " summer "})
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestService_BuyItem_FlashSale(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	now := time.Now()
+	mr := mocks.NewMockRepository(ctrl)
+	mr.EXPECT().ListItemVariants(gomock.Any(), "t-shirt").Return(nil, nil)
+	mr.EXPECT().ListPriceSchedules(gomock.Any(), "t-shirt", gomock.Any()).Return([]models.PriceSchedule{
+		{ID: 1, Item: "t-shirt", SalePrice: 40, StartsAt: now.Add(-time.Hour), EndsAt: now.Add(time.Hour)},
+		{ID: 2, Item: "t-shirt", SalePrice: 10, StartsAt: now.Add(time.Hour), EndsAt: now.Add(2 * time.Hour)},
+	}, nil)
+	expectTx(mr)
+	mr.EXPECT().LockUsers(gomock.Any(), 3).Return(nil)
+	mr.EXPECT().
+		GetUserByID(gomock.Any(), 3).
+		Return(models.User{ID: 3, Username: "testuser3", Coins: 1000}, nil)
+	mr.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 40).Return(960, nil, nil)
+	mr.EXPECT().AddPurchase(gomock.Any(), models.Purchase{
+		UserID: 3, Item: "t-shirt", Price: 40, OriginalPrice: 80,
+	}).Return(1, nil)
+	mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditPurchase, 3)).Return(nil)
+	mr.EXPECT().AddOutboxEvent(gomock.Any(), outboxEvent(models.EventItemPurchased, 3)).Return(nil)
+	mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+
+	svc := service.NewService(mr, "secret")
+	require.NoError(t, svc.BuyItem(context.Background(), 3, "t-shirt", service.PurchaseOptions{}))
+}
+
+func TestService_BuyItem_Gift(t *testing.T) {
+	tests := []struct {
+		name              string
+		opts              service.PurchaseOptions
+		prepareRepository func(*mocks.MockRepository)
+		wantErr           bool
+	}{
+		{
+			name: "Gift lands in recipient's inventory",
+			opts: service.PurchaseOptions{GiftTo: "testuser4", GiftMessage: " С днём\u200b\n рождения!\x07 "},
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().ListItemVariants(gomock.Any(), "cup").Return(nil, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "cup", gomock.Any()).Return(nil, nil)
+				mr.EXPECT().
+					GetUserByUsername(gomock.Any(), "testuser4").
+					Return(models.User{ID: 4, Username: "testuser4"}, nil)
+				expectTx(mr)
+				mr.EXPECT().LockUsers(gomock.Any(), 3).Return(nil)
+				mr.EXPECT().
+					GetUserByID(gomock.Any(), 3).
+					Return(models.User{ID: 3, Username: "testuser3", Coins: 1000}, nil)
+				mr.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 20).Return(980, nil, nil)
+				mr.EXPECT().AddPurchase(gomock.Any(), models.Purchase{
+					UserID: 4, BuyerID: 3, GiftMessage: "С днём рождения!",
+					Item: "cup", Price: 20, OriginalPrice: 20,
+				}).Return(1, nil)
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditPurchase, 3)).Return(nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), outboxEvent(models.EventItemPurchased, 3)).Return(nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ context.Context, payload []byte) error {
+						var n models.UserNotification
+						require.NoError(t, json.Unmarshal(payload, &n))
+						require.Equal(t, 4, n.UserID)
+						require.Equal(t, models.NotificationGiftReceived, n.Type)
+						return nil
+					},
+				)
+			},
+		},
+		{
+			name: "Gift to self",
+			opts: service.PurchaseOptions{GiftTo: "testuser3"},
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().ListItemVariants(gomock.Any(), "cup").Return(nil, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "cup", gomock.Any()).Return(nil, nil)
+				mr.EXPECT().
+					GetUserByUsername(gomock.Any(), "testuser3").
+					Return(models.User{ID: 3, Username: "testuser3"}, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Message without recipient",
+			opts: service.PurchaseOptions{GiftMessage: "Привет"},
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().ListItemVariants(gomock.Any(), "cup").Return(nil, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "cup", gomock.Any()).Return(nil, nil)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			err := svc.BuyItem(context.Background(), 3, "cup", tt.opts)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestService_BuyItem_Variants(t *testing.T) {
+	price := 120
+	variants := []models.ItemVariant{
+		{ID: 7, Item: "hoody", Size: "M", Color: "black", Stock: 3},
+		{ID: 8, Item: "hoody", Size: "XL", Color: "black", Stock: 0, Price: &price},
+	}
+	tests := []struct {
+		name              string
+		opts              service.PurchaseOptions
+		prepareRepository func(*mocks.MockRepository)
+		wantErr           bool
+	}{
+		{
+			name: "Variant with price override",
+			opts: service.PurchaseOptions{Size: "xl", Color: " Black"},
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().ListItemVariants(gomock.Any(), "hoody").Return(variants, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "hoody", gomock.Any()).Return(nil, nil)
+				expectTx(mr)
+				mr.EXPECT().TakeItemVariantStock(gomock.Any(), 8).Return(models.ItemVariant{
+					ID: 8, Item: "hoody", Size: "XL", Color: "black", Stock: 4, Price: &price,
+				}, nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3).Return(nil)
+				mr.EXPECT().
+					GetUserByID(gomock.Any(), 3).
+					Return(models.User{ID: 3, Username: "testuser3", Coins: 1000}, nil)
+				lots := []models.CoinLot{{Amount: 120, Remaining: 120}}
+				mr.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 120).Return(880, lots, nil)
+				mr.EXPECT().AddPurchase(gomock.Any(), models.Purchase{
+					UserID: 3, Item: "hoody", VariantID: 8, Price: 120, OriginalPrice: 120,
+				}).Return(1, nil)
+				mr.EXPECT().AddPurchaseCoinLots(gomock.Any(), 1, lots).Return(nil)
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditPurchase, 3)).Return(nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), outboxEvent(models.EventItemPurchased, 3)).Return(nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+			},
+		},
+		{
+			name: "Variant is required",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().ListItemVariants(gomock.Any(), "hoody").Return(variants, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unknown variant",
+			opts: service.PurchaseOptions{Size: "S", Color: "black"},
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().ListItemVariants(gomock.Any(), "hoody").Return(variants, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Variant out of stock",
+			opts: service.PurchaseOptions{Size: "M", Color: "black"},
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().ListItemVariants(gomock.Any(), "hoody").Return(variants, nil)
+				mr.EXPECT().ListPriceSchedules(gomock.Any(), "hoody", gomock.Any()).Return(nil, nil)
+				expectTx(mr)
+				mr.EXPECT().TakeItemVariantStock(gomock.Any(), 7).Return(models.ItemVariant{}, sql.ErrNoRows)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			err := svc.BuyItem(context.Background(), 3, "hoody", tt.opts)
 			if tt.wantErr {
 				require.Error(t, err)
 			} else {
@@ -190,6 +649,7 @@ func TestService_SendCoin_Success(t *testing.T) {
 		senderID   int
 		toUsername string
 		amount     int
+		opts       service.TransferOptions
 	}
 	tests := []struct {
 		name    string
@@ -201,18 +661,50 @@ func TestService_SendCoin_Success(t *testing.T) {
 			name: "Successful coin transfer",
 			fields: fields{
 				prepareRepository: func(mr *mocks.MockRepository) {
+					sender := models.User{ID: 3, Username: "testuser3", Coins: 1000}
 					receiver := models.User{ID: 4, Username: "testuser4", Coins: 1000}
+					expectTx(mr)
 					mr.EXPECT().
 						GetUserByUsername(gomock.Any(), "testuser4").
 						Return(receiver, nil)
 					mr.EXPECT().
-						UpdateUserCoins(gomock.Any(), 3, -50).
+						LockUsers(gomock.Any(), 3, 4).
+						Return(nil)
+					mr.EXPECT().
+						GetUserByID(gomock.Any(), 3).
+						Return(sender, nil)
+					mr.EXPECT().
+						GetTransferLimitOverride(gomock.Any(), 3, "").
+						Return(models.TransferLimitOverride{}, nil)
+					// Получатель получает партии отправителя с их сроком действия.
+					expiresAt := time.Now().Add(24 * time.Hour)
+					lots := []models.CoinLot{{UserID: 3, Amount: 50, Remaining: 50, ExpiresAt: &expiresAt}}
+					mr.EXPECT().
+						WithdrawUserCoins(gomock.Any(), 3, 50).
+						Return(950, lots, nil)
+					mr.EXPECT().
+						DepositUserCoins(gomock.Any(), 4, 50, lots).
+						Return(1050, nil)
+					mr.EXPECT().
+						AddTransaction(gomock.Any(), models.Transaction{
+							FromUserID: 3,
+							ToUserID:   4,
+							Amount:     50,
+							Type:       models.TransactionTypeTransfer,
+						}).
+						Return(15, nil)
+					mr.EXPECT().
+						AddAuditEntry(gomock.Any(), auditEntry(service.AuditTransfer, 3)).
+						Return(nil)
+					mr.EXPECT().
+						AddOutboxEvent(gomock.Any(), outboxEvent(models.EventCoinSent, 3)).
 						Return(nil)
 					mr.EXPECT().
-						UpdateUserCoins(gomock.Any(), 4, 50).
+						AddOutboxEvent(gomock.Any(), outboxEvent(models.EventCoinReceived, 4)).
 						Return(nil)
 					mr.EXPECT().
-						AddTransaction(gomock.Any(), 3, 4, 50).
+						NotifyUser(gomock.Any(), gomock.Any()).
+						Times(3).
 						Return(nil)
 				},
 			},
@@ -223,6 +715,58 @@ func TestService_SendCoin_Success(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "Transfer with sanitized memo and category",
+			fields: fields{
+				prepareRepository: func(mr *mocks.MockRepository) {
+					sender := models.User{ID: 3, Username: "testuser3", Coins: 1000}
+					receiver := models.User{ID: 4, Username: "testuser4", Coins: 1000}
+					expectTx(mr)
+					mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(receiver, nil)
+					mr.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+					mr.EXPECT().GetUserByID(gomock.Any(), 3).Return(sender, nil)
+					mr.EXPECT().GetTransferLimitOverride(gomock.Any(), 3, "").Return(models.TransferLimitOverride{}, nil)
+					mr.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 50).Return(950, nil, nil)
+					mr.EXPECT().DepositUserCoins(gomock.Any(), 4, 50, gomock.Any()).Return(1050, nil)
+					mr.EXPECT().
+						AddTransaction(gomock.Any(), models.Transaction{
+							FromUserID: 3,
+							ToUserID:   4,
+							Amount:     50,
+							Type:       models.TransactionTypeTransfer,
+							Memo:       "за пиццу",
+							Category:   models.CategoryReimbursement,
+						}).
+						Return(16, nil)
+					mr.EXPECT().AddAuditEntry(gomock.Any(), gomock.Any()).Return(nil)
+					mr.EXPECT().AddOutboxEvent(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+					mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(3).Return(nil)
+				},
+			},
+			args: args{
+				senderID:   3,
+				toUsername: "testuser4",
+				amount:     50,
+				opts: service.TransferOptions{
+					Memo:     "  за\u202e\tпиццу\n",
+					Category: "Reimbursement",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "Unknown category",
+			fields: fields{
+				prepareRepository: func(mr *mocks.MockRepository) {},
+			},
+			args: args{
+				senderID:   3,
+				toUsername: "testuser4",
+				amount:     50,
+				opts:       service.TransferOptions{Category: "bribe"},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -235,7 +779,7 @@ func TestService_SendCoin_Success(t *testing.T) {
 			tt.fields.prepareRepository(mockRepo)
 
 			svc := service.NewService(mockRepo, "secret")
-			err := svc.SendCoin(ctx, tt.args.senderID, tt.args.toUsername, tt.args.amount)
+			_, err := svc.SendCoin(ctx, tt.args.senderID, tt.args.toUsername, tt.args.amount, tt.args.opts)
 			if tt.wantErr {
 				require.Error(t, err)
 			} else {
@@ -244,3 +788,1604 @@ func TestService_SendCoin_Success(t *testing.T) {
 		})
 	}
 }
+
+func TestService_CreateWebhook(t *testing.T) {
+	type fields struct {
+		prepareRepository func(*mocks.MockRepository)
+	}
+	type args struct {
+		url        string
+		eventTypes []string
+		secret     string
+	}
+	tests := []struct {
+		name    string
+		fields  fields
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "Valid subscription with generated secret",
+			fields: fields{
+				prepareRepository: func(mr *mocks.MockRepository) {
+					expectTx(mr)
+					mr.EXPECT().
+						CreateWebhookSubscription(gomock.Any(), gomock.Any()).
+						Return(7, nil)
+					mr.EXPECT().
+						AddAuditEntry(gomock.Any(), auditEntry(service.AuditWebhookCreate, 1)).
+						Return(nil)
+				},
+			},
+			args: args{
+				url:        "https://bots.example.com/hook",
+				eventTypes: []string{models.EventCoinReceived, models.EventItemPurchased},
+			},
+			wantErr: false,
+		},
+		{
+			name:   "Invalid url",
+			fields: fields{prepareRepository: func(mr *mocks.MockRepository) {}},
+			args: args{
+				url:        "ftp://bots.example.com/hook",
+				eventTypes: []string{models.EventCoinSent},
+			},
+			wantErr: true,
+		},
+		{
+			name:   "Unknown event type",
+			fields: fields{prepareRepository: func(mr *mocks.MockRepository) {}},
+			args: args{
+				url:        "https://bots.example.com/hook",
+				eventTypes: []string{"coin.burned"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx := context.Background()
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.fields.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			webhook, err := svc.CreateWebhook(ctx, 1, tt.args.url, tt.args.eventTypes, tt.args.secret)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 7, webhook.ID)
+			require.NotEmpty(t, webhook.Secret)
+			require.True(t, webhook.Active)
+		})
+	}
+}
+
+func TestService_Authenticate_AdminRole(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	hashed, _ := bcrypt.GenerateFromPassword([]byte("pass"), bcrypt.DefaultCost)
+	mockRepo := mocks.NewMockRepository(ctrl)
+	mockRepo.EXPECT().
+		GetUserByUsername(gomock.Any(), "boss").
+		Return(models.User{ID: 10, Username: "boss", Password: string(hashed), Role: models.RoleUser}, nil)
+	mockRepo.EXPECT().
+		AddAuditEntry(gomock.Any(), auditEntry(service.AuditAuthLogin, 10)).
+		Return(nil)
+
+	svc := service.NewService(mockRepo, "secret", service.WithAdmins("boss"))
+	token, err := svc.Authenticate(context.Background(), "boss", "pass")
+	require.NoError(t, err)
+
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		return []byte("secret"), nil
+	})
+	require.NoError(t, err)
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	require.True(t, ok)
+	require.Equal(t, models.RoleAdmin, claims["role"])
+}
+
+func TestService_Authenticate_PrivilegedNotAutoRegistered(t *testing.T) {
+	for _, opt := range []service.Option{service.WithAdmins("boss"), service.WithManagers("boss")} {
+		ctrl := gomock.NewController(t)
+		mockRepo := mocks.NewMockRepository(ctrl)
+		mockRepo.EXPECT().
+			GetUserByUsername(gomock.Any(), "boss").
+			Return(models.User{}, sql.ErrNoRows)
+
+		svc := service.NewService(mockRepo, "secret", opt)
+		_, err := svc.Authenticate(context.Background(), "boss", "pass")
+		require.Error(t, err)
+		ctrl.Finish()
+	}
+}
+
+func TestService_UserRole(t *testing.T) {
+	tests := []struct {
+		name string
+		user models.User
+		want string
+	}{
+		{
+			name: "Admin from config",
+			user: models.User{ID: 1, Username: "boss", Role: models.RoleUser},
+			want: models.RoleAdmin,
+		},
+		{
+			name: "Manager from profile",
+			user: models.User{ID: 2, Username: "lead", Role: models.RoleManager},
+			want: models.RoleManager,
+		},
+		{
+			name: "Admin claim is not trusted once removed from config",
+			user: models.User{ID: 3, Username: "former", Role: models.RoleUser},
+			want: models.RoleUser,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			mockRepo.EXPECT().GetUserByID(gomock.Any(), tt.user.ID).Return(tt.user, nil)
+
+			svc := service.NewService(mockRepo, "secret", service.WithAdmins("boss"))
+			role, err := svc.UserRole(context.Background(), tt.user.ID)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, role)
+		})
+	}
+}
+
+func TestService_AdjustBalance(t *testing.T) {
+	type fields struct {
+		prepareRepository func(*mocks.MockRepository)
+	}
+	tests := []struct {
+		name      string
+		fields    fields
+		args      service.BalanceAdjustment
+		wantErr   bool
+		wantCoins int
+	}{
+		{
+			name: "Debit is recorded as outgoing adjustment",
+			fields: fields{
+				prepareRepository: func(mr *mocks.MockRepository) {
+					expectTx(mr)
+					mr.EXPECT().
+						GetUserByUsername(gomock.Any(), "worker").
+						Return(models.User{ID: 5, Username: "worker", Coins: 1000}, nil)
+					mr.EXPECT().
+						AdjustUserCoins(gomock.Any(), 5, -200, false).
+						Return(800, nil)
+					mr.EXPECT().
+						AddTransaction(gomock.Any(), models.Transaction{
+							FromUserID: 5,
+							Amount:     200,
+							Type:       models.TransactionTypeAdjustment,
+							Reason:     "Ошибочное начисление",
+							Reference:  "HR-42",
+							ActorID:    1,
+						}).
+						Return(21, nil)
+					mr.EXPECT().
+						AddAuditEntry(gomock.Any(), auditEntry(service.AuditBalanceAdjustment, 1)).
+						Return(nil)
+					mr.EXPECT().
+						NotifyUser(gomock.Any(), gomock.Any()).
+						Return(nil)
+				},
+			},
+			args: service.BalanceAdjustment{
+				Username:  "worker",
+				Amount:    -200,
+				Reason:    "  Ошибочное начисление ",
+				Reference: "HR-42",
+			},
+			wantCoins: 800,
+		},
+		{
+			name: "Negative balance is rejected without override",
+			fields: fields{
+				prepareRepository: func(mr *mocks.MockRepository) {
+					expectTx(mr)
+					mr.EXPECT().
+						GetUserByUsername(gomock.Any(), "worker").
+						Return(models.User{ID: 5, Username: "worker", Coins: 100}, nil)
+					mr.EXPECT().
+						AdjustUserCoins(gomock.Any(), 5, -200, false).
+						Return(0, errors.New("недостаточно монет"))
+				},
+			},
+			args: service.BalanceAdjustment{
+				Username:  "worker",
+				Amount:    -200,
+				Reason:    "Ошибочное начисление",
+				Reference: "HR-42",
+			},
+			wantErr: true,
+		},
+		{
+			name:   "Reason is required",
+			fields: fields{prepareRepository: func(mr *mocks.MockRepository) {}},
+			args: service.BalanceAdjustment{
+				Username:  "worker",
+				Amount:    100,
+				Reference: "HR-42",
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			ctx := context.Background()
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.fields.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			resp, err := svc.AdjustBalance(ctx, 1, tt.args)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantCoins, resp.Coins)
+			require.Equal(t, 21, resp.TransactionID)
+		})
+	}
+}
+
+func TestService_ListAuditLog(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	mockRepo := mocks.NewMockRepository(ctrl)
+	mockRepo.EXPECT().
+		GetUserByUsername(gomock.Any(), "boss").
+		Return(models.User{ID: 10, Username: "boss"}, nil)
+	mockRepo.EXPECT().
+		ListAuditEntries(gomock.Any(), models.AuditFilter{
+			ActorID: 10,
+			Action:  service.AuditBalanceAdjustment,
+			From:    from,
+			Limit:   100,
+		}).
+		Return([]models.AuditEntry{{
+			ID:            1,
+			ActorID:       10,
+			ActorUsername: "boss",
+			Action:        service.AuditBalanceAdjustment,
+			Target:        "user:worker",
+			Before:        []byte(`{"coins":1000}`),
+			After:         []byte(`{"coins":800}`),
+			RequestID:     "req-1",
+			IP:            "10.0.0.1",
+		}}, nil)
+
+	svc := service.NewService(mockRepo, "secret")
+	entries, err := svc.ListAuditLog(context.Background(), service.AuditQuery{
+		Actor:  "boss",
+		Action: service.AuditBalanceAdjustment,
+		From:   from,
+		Limit:  1000,
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "boss", entries[0].Actor)
+	require.JSONEq(t, `{"coins":800}`, string(entries[0].After))
+}
+
+func TestService_GetFeed(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockRepository(ctrl)
+	mockRepo.EXPECT().
+		ListPublicTransfers(gomock.Any(), 20, 0).
+		Return([]models.FeedEntry{
+			{TransactionID: 7, FromUsername: "alice", ToUsername: "bob", Amount: 30, Memo: "спасибо за ревью"},
+			{TransactionID: 5, FromUsername: "bob", ToUsername: "carol", Amount: 10},
+		}, nil)
+	mockRepo.EXPECT().
+		ListKudosReactions(gomock.Any(), []int{7, 5}).
+		Return([]models.KudosReaction{
+			{TransactionID: 7, UserID: 1, Reaction: models.ReactionClap},
+			{TransactionID: 7, UserID: 2, Reaction: models.ReactionClap},
+			{TransactionID: 7, UserID: 2, Reaction: models.ReactionHeart},
+		}, nil)
+
+	svc := service.NewService(mockRepo, "secret")
+	feed, err := svc.GetFeed(context.Background(), 2, 0, -5)
+	require.NoError(t, err)
+	require.Len(t, feed, 2)
+	require.Equal(t, map[string]int{models.ReactionClap: 2, models.ReactionHeart: 1}, feed[0].Reactions)
+	require.Equal(t, []string{models.ReactionClap, models.ReactionHeart}, feed[0].MyReactions)
+	require.Empty(t, feed[1].Reactions)
+}
+
+func TestService_AddReaction(t *testing.T) {
+	tests := []struct {
+		name              string
+		reaction          string
+		prepareRepository func(*mocks.MockRepository)
+		wantErr           error
+	}{
+		{
+			name:     "Public transfer",
+			reaction: models.ReactionFire,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().
+					GetTransactionByID(gomock.Any(), 7).
+					Return(models.Transaction{ID: 7, Type: models.TransactionTypeTransfer, Public: true}, nil)
+				mr.EXPECT().
+					AddKudosReaction(gomock.Any(), models.KudosReaction{TransactionID: 7, UserID: 2, Reaction: models.ReactionFire}).
+					Return(nil)
+			},
+		},
+		{
+			name:     "Private transfer is hidden",
+			reaction: models.ReactionFire,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().
+					GetTransactionByID(gomock.Any(), 7).
+					Return(models.Transaction{ID: 7, Type: models.TransactionTypeTransfer}, nil)
+			},
+			wantErr: sql.ErrNoRows,
+		},
+		{
+			name:              "Unknown reaction",
+			reaction:          "poop",
+			prepareRepository: func(mr *mocks.MockRepository) {},
+			wantErr:           errors.New("неизвестная реакция"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			err := svc.AddReaction(context.Background(), 2, 7, tt.reaction)
+			if tt.wantErr != nil {
+				require.EqualError(t, err, tt.wantErr.Error())
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestService_SendCoin_Limits(t *testing.T) {
+	sender := models.User{ID: 3, Username: "testuser3", Coins: 1000, Role: models.RoleUser}
+	receiver := models.User{ID: 4, Username: "testuser4", Coins: 1000}
+	defaults := service.TransferLimits{PerTransfer: 500, PerDay: 300, RecipientsPerDay: 2}
+
+	tests := []struct {
+		name     string
+		amount   int
+		override models.TransferLimitOverride
+		usage    *models.TransferUsage
+		wantErr  string
+	}{
+		{
+			name:    "Per transfer limit",
+			amount:  600,
+			wantErr: "превышен лимит на один перевод",
+		},
+		{
+			name:    "Daily limit",
+			amount:  100,
+			usage:   &models.TransferUsage{SentToday: 250, RecipientsToday: []int{4}},
+			wantErr: "превышен дневной лимит переводов",
+		},
+		{
+			name:     "User override lifts daily limit and sets monthly",
+			amount:   100,
+			override: models.TransferLimitOverride{PerDay: intPtr(0), PerMonth: intPtr(1000)},
+			usage:    &models.TransferUsage{SentToday: 250, SentThisMonth: 950},
+			wantErr:  "превышен месячный лимит переводов",
+		},
+		{
+			name:    "Too many recipients",
+			amount:  10,
+			usage:   &models.TransferUsage{SentToday: 20, RecipientsToday: []int{5, 6}},
+			wantErr: "превышено количество получателей за день",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			expectTx(mockRepo)
+			mockRepo.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(receiver, nil)
+			mockRepo.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+			mockRepo.EXPECT().GetUserByID(gomock.Any(), 3).Return(sender, nil)
+			mockRepo.EXPECT().
+				GetTransferLimitOverride(gomock.Any(), 3, models.RoleUser).
+				Return(tt.override, nil)
+			if tt.usage != nil {
+				mockRepo.EXPECT().
+					GetTransferUsage(gomock.Any(), 3, gomock.Any(), gomock.Any()).
+					Return(*tt.usage, nil)
+			}
+
+			svc := service.NewService(mockRepo, "secret", service.WithTransferLimits(defaults))
+			_, err := svc.SendCoin(context.Background(), 3, "testuser4", tt.amount, service.TransferOptions{})
+			require.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestService_SendCoin_AdminRoleLimits(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	// Роль администратора задаётся конфигурацией, в базе у пользователя роль user.
+	sender := models.User{ID: 3, Username: "testuser3", Coins: 1000, Role: models.RoleUser}
+	receiver := models.User{ID: 4, Username: "testuser4", Coins: 1000}
+
+	mockRepo := mocks.NewMockRepository(ctrl)
+	expectTx(mockRepo)
+	mockRepo.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(receiver, nil)
+	mockRepo.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+	mockRepo.EXPECT().GetUserByID(gomock.Any(), 3).Return(sender, nil)
+	mockRepo.EXPECT().
+		GetTransferLimitOverride(gomock.Any(), 3, models.RoleAdmin).
+		Return(models.TransferLimitOverride{PerTransfer: intPtr(50)}, nil)
+
+	svc := service.NewService(mockRepo, "secret",
+		service.WithAdmins("testuser3"),
+		service.WithTransferLimits(service.TransferLimits{PerTransfer: 500}))
+	_, err := svc.SendCoin(context.Background(), 3, "testuser4", 100, service.TransferOptions{})
+	require.EqualError(t, err, "превышен лимит на один перевод")
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestService_SendCoin_RequiresApproval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	sender := models.User{ID: 3, Username: "testuser3", Coins: 1000}
+	receiver := models.User{ID: 4, Username: "testuser4", Coins: 1000}
+
+	mockRepo := mocks.NewMockRepository(ctrl)
+	expectTx(mockRepo)
+	mockRepo.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(receiver, nil)
+	mockRepo.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+	mockRepo.EXPECT().GetUserByID(gomock.Any(), 3).Return(sender, nil)
+	mockRepo.EXPECT().GetTransferLimitOverride(gomock.Any(), 3, "").Return(models.TransferLimitOverride{}, nil)
+	mockRepo.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 600).Return(400, nil, nil)
+	mockRepo.EXPECT().
+		CreatePendingTransfer(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, p models.PendingTransfer) (int, error) {
+			require.Equal(t, 3, p.FromUserID)
+			require.Equal(t, 4, p.ToUserID)
+			require.Equal(t, 600, p.Amount)
+			require.WithinDuration(t, time.Now().Add(48*time.Hour), p.ExpiresAt, time.Minute)
+			return 21, nil
+		})
+	mockRepo.EXPECT().ParkCoinLots(gomock.Any(), 21, gomock.Any()).Return(nil)
+	mockRepo.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditTransferHold, 3)).Return(nil)
+	mockRepo.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Return(nil)
+
+	svc := service.NewService(mockRepo, "secret", service.WithTransferApproval(500, 48*time.Hour))
+	result, err := svc.SendCoin(context.Background(), 3, "testuser4", 600, service.TransferOptions{})
+	require.NoError(t, err)
+	require.Equal(t, service.TransferResult{Status: service.TransferStatusPending, PendingTransferID: 21}, result)
+}
+
+func TestService_ApproveTransfer(t *testing.T) {
+	pending := models.PendingTransfer{
+		ID:           21,
+		Kind:         models.PendingKindApproval,
+		FromUserID:   3,
+		ToUserID:     4,
+		FromUsername: "testuser3",
+		ToUsername:   "testuser4",
+		Amount:       600,
+		Status:       models.PendingStatusPending,
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+
+	tests := []struct {
+		name              string
+		approverID        int
+		prepareRepository func(*mocks.MockRepository)
+		wantErr           string
+	}{
+		{
+			name:       "Manager approves",
+			approverID: 7,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().GetUserByID(gomock.Any(), 7).Return(models.User{ID: 7, Username: "boss"}, nil)
+				expectTx(mr)
+				mr.EXPECT().GetPendingTransferForUpdate(gomock.Any(), 21).Return(pending, nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 3).Return(models.User{ID: 3, Username: "testuser3"}, nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 4).Return(models.User{ID: 4, Username: "testuser4"}, nil)
+				mr.EXPECT().TakeParkedCoinLots(gomock.Any(), 21).Return(nil, nil)
+				mr.EXPECT().DepositUserCoins(gomock.Any(), 4, 600, gomock.Any()).Return(1600, nil)
+				mr.EXPECT().
+					AddTransaction(gomock.Any(), models.Transaction{
+						FromUserID: 3,
+						ToUserID:   4,
+						Amount:     600,
+						Type:       models.TransactionTypeTransfer,
+					}).
+					Return(30, nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+				mr.EXPECT().
+					UpdatePendingTransfer(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, p models.PendingTransfer) error {
+						require.Equal(t, models.PendingStatusApproved, p.Status)
+						require.Equal(t, 7, p.ApproverID)
+						require.Equal(t, 30, p.TransactionID)
+						require.NotNil(t, p.DecidedAt)
+						return nil
+					})
+				mr.EXPECT().
+					GetCoinRequestByPaymentForUpdate(gomock.Any(), 0, 21).
+					Return(models.CoinRequest{ID: 9, RequesterID: 4, PendingTransferID: 21, Status: models.CoinRequestPaymentPending}, nil)
+				mr.EXPECT().
+					UpdateCoinRequest(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, cr models.CoinRequest) error {
+						require.Equal(t, models.CoinRequestPaid, cr.Status)
+						require.Equal(t, 30, cr.TransactionID)
+						return nil
+					})
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditTransferApprove, 7)).Return(nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(4).Return(nil)
+			},
+		},
+		{
+			name:       "Regular user cannot approve",
+			approverID: 8,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().GetUserByID(gomock.Any(), 8).Return(models.User{ID: 8, Username: "peer", Role: models.RoleUser}, nil)
+			},
+			wantErr: "недостаточно прав",
+		},
+		{
+			name:       "Sender cannot approve own transfer",
+			approverID: 3,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().GetUserByID(gomock.Any(), 3).Return(models.User{ID: 3, Username: "testuser3", Role: models.RoleManager}, nil)
+				expectTx(mr)
+				mr.EXPECT().GetPendingTransferForUpdate(gomock.Any(), 21).Return(pending, nil)
+			},
+			wantErr: "нельзя согласовать собственный перевод",
+		},
+		{
+			name:       "Recipient cannot approve incoming transfer",
+			approverID: 4,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				mr.EXPECT().GetUserByID(gomock.Any(), 4).Return(models.User{ID: 4, Username: "testuser4", Role: models.RoleManager}, nil)
+				expectTx(mr)
+				mr.EXPECT().GetPendingTransferForUpdate(gomock.Any(), 21).Return(pending, nil)
+			},
+			wantErr: "нельзя согласовать перевод самому себе",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret", service.WithManagers("boss"))
+			err := svc.ApproveTransfer(context.Background(), tt.approverID, 21)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestService_ExpirePendingTransfers(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mockRepo := mocks.NewMockRepository(ctrl)
+	expectTx(mockRepo)
+	mockRepo.EXPECT().
+		ClaimExpiredPendingTransfers(gomock.Any(), gomock.Any(), 100).
+		Return([]models.PendingTransfer{{ID: 21, FromUserID: 3, ToUserID: 4, Amount: 600, Status: models.PendingStatusPending}}, nil)
+	mockRepo.EXPECT().LockUsers(gomock.Any(), 3).Times(2).Return(nil)
+	mockRepo.EXPECT().TakeParkedCoinLots(gomock.Any(), 21).Return(nil, nil)
+	mockRepo.EXPECT().DepositUserCoins(gomock.Any(), 3, 600, gomock.Any()).Return(1000, nil)
+	mockRepo.EXPECT().
+		UpdatePendingTransfer(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, p models.PendingTransfer) error {
+			require.Equal(t, models.PendingStatusExpired, p.Status)
+			return nil
+		})
+	mockRepo.EXPECT().
+		GetCoinRequestByPaymentForUpdate(gomock.Any(), 0, 21).
+		Return(models.CoinRequest{
+			ID:                9,
+			RequesterID:       4,
+			PendingTransferID: 21,
+			Status:            models.CoinRequestPaymentPending,
+			ExpiresAt:         time.Now().Add(time.Hour),
+		}, nil)
+	mockRepo.EXPECT().
+		UpdateCoinRequest(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, cr models.CoinRequest) error {
+			require.Equal(t, models.CoinRequestPending, cr.Status)
+			require.Zero(t, cr.PendingTransferID)
+			require.Nil(t, cr.DecidedAt)
+			return nil
+		})
+	mockRepo.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditTransferExpire, 0)).Return(nil)
+	mockRepo.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(3).Return(nil)
+
+	svc := service.NewService(mockRepo, "secret")
+	require.NoError(t, svc.ExpirePendingTransfers(context.Background()))
+}
+
+func TestService_AcceptTransfer(t *testing.T) {
+	escrow := models.PendingTransfer{
+		ID:           22,
+		Kind:         models.PendingKindEscrow,
+		FromUserID:   3,
+		ToUserID:     4,
+		FromUsername: "testuser3",
+		ToUsername:   "testuser4",
+		Amount:       40,
+		Status:       models.PendingStatusPending,
+		ExpiresAt:    time.Now().Add(time.Hour),
+	}
+
+	tests := []struct {
+		name              string
+		userID            int
+		prepareRepository func(*mocks.MockRepository)
+		wantErr           string
+	}{
+		{
+			name:   "Recipient accepts",
+			userID: 4,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().GetPendingTransferForUpdate(gomock.Any(), 22).Return(escrow, nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 3).Return(models.User{ID: 3, Username: "testuser3"}, nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 4).Return(models.User{ID: 4, Username: "testuser4"}, nil)
+				mr.EXPECT().TakeParkedCoinLots(gomock.Any(), 22).Return(nil, nil)
+				mr.EXPECT().DepositUserCoins(gomock.Any(), 4, 40, gomock.Any()).Return(1040, nil)
+				mr.EXPECT().AddTransaction(gomock.Any(), gomock.Any()).Return(31, nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+				mr.EXPECT().
+					UpdatePendingTransfer(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, p models.PendingTransfer) error {
+						require.Equal(t, models.PendingStatusAccepted, p.Status)
+						require.Equal(t, 31, p.TransactionID)
+						return nil
+					})
+				mr.EXPECT().GetCoinRequestByPaymentForUpdate(gomock.Any(), 0, 22).Return(models.CoinRequest{}, sql.ErrNoRows)
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditTransferAccept, 4)).Return(nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(3).Return(nil)
+			},
+		},
+		{
+			name:   "Other user cannot accept",
+			userID: 5,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().GetPendingTransferForUpdate(gomock.Any(), 22).Return(escrow, nil)
+			},
+			wantErr: sql.ErrNoRows.Error(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			err := svc.AcceptTransfer(context.Background(), tt.userID, 22)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestService_UndoTransfer(t *testing.T) {
+	transfer := models.Transaction{
+		ID:         15,
+		FromUserID: 3,
+		ToUserID:   4,
+		Amount:     50,
+		Type:       models.TransactionTypeTransfer,
+		CreatedAt:  time.Now().Add(-10 * time.Second),
+	}
+
+	tests := []struct {
+		name              string
+		prepareRepository func(*mocks.MockRepository)
+		wantErr           string
+	}{
+		{
+			name: "Undo within window",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().GetTransactionByID(gomock.Any(), 15).Return(transfer, nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+				mr.EXPECT().HasReversal(gomock.Any(), 15).Return(false, nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 3).Return(models.User{ID: 3, Username: "testuser3", Coins: 950}, nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 4).Return(models.User{ID: 4, Username: "testuser4", Coins: 1050}, nil)
+				mr.EXPECT().WithdrawUserCoins(gomock.Any(), 4, 50).Return(1000, nil, nil)
+				mr.EXPECT().DepositUserCoins(gomock.Any(), 3, 50, gomock.Any()).Return(1000, nil)
+				mr.EXPECT().
+					AddTransaction(gomock.Any(), models.Transaction{
+						FromUserID: 4,
+						ToUserID:   3,
+						Amount:     50,
+						Type:       models.TransactionTypeReversal,
+						ReversalOf: 15,
+					}).
+					Return(16, nil)
+				mr.EXPECT().
+					GetCoinRequestByPaymentForUpdate(gomock.Any(), 15, 0).
+					Return(models.CoinRequest{
+						ID:            9,
+						RequesterID:   4,
+						TransactionID: 15,
+						Status:        models.CoinRequestPaid,
+						ExpiresAt:     time.Now().Add(-time.Hour),
+					}, nil)
+				mr.EXPECT().
+					UpdateCoinRequest(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, cr models.CoinRequest) error {
+						require.Equal(t, models.CoinRequestExpired, cr.Status)
+						require.Zero(t, cr.TransactionID)
+						return nil
+					})
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditTransferUndo, 3)).Return(nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), outboxEvent(models.EventCoinReversed, 3)).Return(nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), outboxEvent(models.EventCoinReversed, 4)).Return(nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(3).Return(nil)
+			},
+		},
+		{
+			name: "Recipient already spent coins",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().GetTransactionByID(gomock.Any(), 15).Return(transfer, nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+				mr.EXPECT().HasReversal(gomock.Any(), 15).Return(false, nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 3).Return(models.User{ID: 3, Coins: 950}, nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 4).Return(models.User{ID: 4, Coins: 20}, nil)
+			},
+			wantErr: "получатель уже потратил монеты, отмена невозможна",
+		},
+		{
+			name: "Window elapsed",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				old := transfer
+				old.CreatedAt = time.Now().Add(-2 * time.Minute)
+				mr.EXPECT().GetTransactionByID(gomock.Any(), 15).Return(old, nil)
+			},
+			wantErr: "время отмены перевода истекло",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret", service.WithUndoWindow(time.Minute))
+			reversal, err := svc.UndoTransfer(context.Background(), 3, 15)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, 16, reversal.ID)
+			require.Equal(t, 15, reversal.ReversalOf)
+		})
+	}
+}
+
+func TestService_PayCoinRequest(t *testing.T) {
+	request := models.CoinRequest{
+		ID:                9,
+		RequesterID:       4,
+		PayerID:           3,
+		RequesterUsername: "testuser4",
+		PayerUsername:     "testuser3",
+		Amount:            50,
+		Memo:              "за пиццу",
+		Status:            models.CoinRequestPending,
+		ExpiresAt:         time.Now().Add(time.Hour),
+	}
+
+	tests := []struct {
+		name              string
+		payerID           int
+		prepareRepository func(*mocks.MockRepository)
+		wantErr           string
+	}{
+		{
+			name:    "Payer pays through SendCoin",
+			payerID: 3,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				expectTx(mr)
+				mr.EXPECT().GetCoinRequestForUpdate(gomock.Any(), 9).Return(request, nil)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(models.User{ID: 4, Username: "testuser4"}, nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 3).Return(models.User{ID: 3, Username: "testuser3", Coins: 1000}, nil)
+				mr.EXPECT().GetTransferLimitOverride(gomock.Any(), 3, "").Return(models.TransferLimitOverride{}, nil)
+				mr.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 50).Return(950, nil, nil)
+				mr.EXPECT().DepositUserCoins(gomock.Any(), 4, 50, gomock.Any()).Return(1050, nil)
+				mr.EXPECT().
+					AddTransaction(gomock.Any(), models.Transaction{
+						FromUserID: 3,
+						ToUserID:   4,
+						Amount:     50,
+						Type:       models.TransactionTypeTransfer,
+						Memo:       "за пиццу",
+					}).
+					Return(40, nil)
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditTransfer, 3)).Return(nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+				mr.EXPECT().
+					UpdateCoinRequest(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, cr models.CoinRequest) error {
+						require.Equal(t, models.CoinRequestPaid, cr.Status)
+						require.Equal(t, 40, cr.TransactionID)
+						return nil
+					})
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(4).Return(nil)
+			},
+		},
+		{
+			name:    "Only the payer can pay",
+			payerID: 5,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().GetCoinRequestForUpdate(gomock.Any(), 9).Return(request, nil)
+			},
+			wantErr: sql.ErrNoRows.Error(),
+		},
+		{
+			name:    "Already declined",
+			payerID: 3,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				declined := request
+				declined.Status = models.CoinRequestDeclined
+				mr.EXPECT().GetCoinRequestForUpdate(gomock.Any(), 9).Return(declined, nil)
+			},
+			wantErr: "запрос уже обработан",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			result, err := svc.PayCoinRequest(context.Background(), tt.payerID, 9)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, service.TransferStatusCompleted, result.Status)
+		})
+	}
+}
+
+func TestService_PayCoinRequest_HeldForApproval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	request := models.CoinRequest{
+		ID:                9,
+		RequesterID:       4,
+		PayerID:           3,
+		RequesterUsername: "testuser4",
+		PayerUsername:     "testuser3",
+		Amount:            600,
+		Status:            models.CoinRequestPending,
+		ExpiresAt:         time.Now().Add(time.Hour),
+	}
+
+	mockRepo := mocks.NewMockRepository(ctrl)
+	expectTx(mockRepo)
+	expectTx(mockRepo)
+	mockRepo.EXPECT().GetCoinRequestForUpdate(gomock.Any(), 9).Return(request, nil)
+	mockRepo.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(models.User{ID: 4, Username: "testuser4"}, nil)
+	mockRepo.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+	mockRepo.EXPECT().GetUserByID(gomock.Any(), 3).Return(models.User{ID: 3, Username: "testuser3", Coins: 1000}, nil)
+	mockRepo.EXPECT().GetTransferLimitOverride(gomock.Any(), 3, "").Return(models.TransferLimitOverride{}, nil)
+	mockRepo.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 600).Return(400, nil, nil)
+	mockRepo.EXPECT().CreatePendingTransfer(gomock.Any(), gomock.Any()).Return(21, nil)
+	mockRepo.EXPECT().ParkCoinLots(gomock.Any(), 21, gomock.Any()).Return(nil)
+	mockRepo.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditTransferHold, 3)).Return(nil)
+	mockRepo.EXPECT().
+		UpdateCoinRequest(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(_ context.Context, cr models.CoinRequest) error {
+			require.Equal(t, models.CoinRequestPaymentPending, cr.Status)
+			require.Equal(t, 21, cr.PendingTransferID)
+			require.Zero(t, cr.TransactionID)
+			require.Nil(t, cr.DecidedAt)
+			return nil
+		})
+	mockRepo.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+
+	svc := service.NewService(mockRepo, "secret", service.WithTransferApproval(500, 48*time.Hour))
+	result, err := svc.PayCoinRequest(context.Background(), 3, 9)
+	require.NoError(t, err)
+	require.Equal(t, service.TransferResult{Status: service.TransferStatusPending, PendingTransferID: 21}, result)
+}
+
+func TestService_GetBillSplit_PaymentAwaitingApproval(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	split := models.BillSplit{ID: 5, CreatorID: 4, CreatorUsername: "testuser4", Total: 900}
+	requests := []models.CoinRequest{
+		{ID: 9, SplitID: 5, RequesterID: 4, PayerID: 3, Amount: 600, Status: models.CoinRequestPaymentPending, PendingTransferID: 21},
+		{ID: 10, SplitID: 5, RequesterID: 4, PayerID: 6, Amount: 300, Status: models.CoinRequestPaid, TransactionID: 40},
+	}
+
+	mockRepo := mocks.NewMockRepository(ctrl)
+	mockRepo.EXPECT().GetBillSplit(gomock.Any(), 5).Return(split, nil)
+	mockRepo.EXPECT().ListCoinRequests(gomock.Any(), models.CoinRequestFilter{SplitID: 5}).Return(requests, nil)
+
+	svc := service.NewService(mockRepo, "secret")
+	info, err := svc.GetBillSplit(context.Background(), 4, 5)
+	require.NoError(t, err)
+	require.Equal(t, 300, info.Paid)
+	require.Equal(t, 600, info.Processing)
+	require.Equal(t, 0, info.Outstanding)
+	require.Equal(t, service.SplitStatusOpen, info.Status)
+}
+
+func TestService_CreateBillSplit(t *testing.T) {
+	tests := []struct {
+		name              string
+		req               service.BillSplitRequest
+		prepareRepository func(*mocks.MockRepository)
+		wantAmounts       []int
+		wantCreatorShare  int
+		wantErr           string
+	}{
+		{
+			name: "Equal shares with creator",
+			req: service.BillSplitRequest{
+				Total:          100,
+				Memo:           "обед",
+				Participants:   []service.SplitShare{{Username: "testuser3"}, {Username: "testuser5"}},
+				IncludeCreator: true,
+			},
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().GetUserByID(gomock.Any(), 4).Return(models.User{ID: 4, Username: "testuser4"}, nil)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser3").Return(models.User{ID: 3, Username: "testuser3"}, nil)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser5").Return(models.User{ID: 5, Username: "testuser5"}, nil)
+				mr.EXPECT().CreateBillSplit(gomock.Any(), gomock.Any()).Return(7, nil)
+				mr.EXPECT().
+					CreateCoinRequest(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, cr models.CoinRequest) (int, error) {
+						require.Equal(t, 7, cr.SplitID)
+						return cr.PayerID * 10, nil
+					}).
+					Times(2)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+			},
+			wantAmounts:      []int{34, 33},
+			wantCreatorShare: 33,
+		},
+		{
+			name: "Custom shares must cover the total",
+			req: service.BillSplitRequest{
+				Total:        100,
+				Participants: []service.SplitShare{{Username: "testuser3", Amount: 40}, {Username: "testuser5", Amount: 50}},
+			},
+			prepareRepository: func(mr *mocks.MockRepository) {},
+			wantErr:           "сумма долей не совпадает с суммой счёта",
+		},
+		{
+			name: "Mixed shares",
+			req: service.BillSplitRequest{
+				Total:        100,
+				Participants: []service.SplitShare{{Username: "testuser3", Amount: 40}, {Username: "testuser5"}},
+			},
+			prepareRepository: func(mr *mocks.MockRepository) {},
+			wantErr:           "доли нужно указать для всех участников или ни для кого",
+		},
+		{
+			name: "Creator cannot be a participant",
+			req: service.BillSplitRequest{
+				Total:        100,
+				Participants: []service.SplitShare{{Username: "testuser4"}},
+			},
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().GetUserByID(gomock.Any(), 4).Return(models.User{ID: 4, Username: "testuser4"}, nil)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(models.User{ID: 4, Username: "testuser4"}, nil)
+			},
+			wantErr: "создатель не может быть участником счёта",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			split, err := svc.CreateBillSplit(context.Background(), 4, tt.req)
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, service.SplitStatusOpen, split.Status)
+			require.Equal(t, tt.wantCreatorShare, split.CreatorPart)
+			require.Len(t, split.Requests, len(tt.wantAmounts))
+			for i, amount := range tt.wantAmounts {
+				require.Equal(t, amount, split.Requests[i].Amount)
+			}
+		})
+	}
+}
+
+func TestService_SendCoinBatch(t *testing.T) {
+	transfers := []service.BatchTransfer{
+		{ToUser: "testuser4", Amount: 30},
+		{ToUser: "testuser5", Amount: 20},
+	}
+
+	tests := []struct {
+		name              string
+		transfers         []service.BatchTransfer
+		prepareRepository func(*mocks.MockRepository)
+		wantStatuses      []string
+		wantErr           string
+	}{
+		{
+			name:      "All transfers complete",
+			transfers: transfers,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				expectTx(mr)
+				expectTx(mr)
+				sender := models.User{ID: 3, Username: "testuser3", Coins: 100}
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(models.User{ID: 4, Username: "testuser4"}, nil).Times(2)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser5").Return(models.User{ID: 5, Username: "testuser5"}, nil).Times(2)
+				mr.EXPECT().LockUsers(gomock.Any(), 3, 4, 5).Return(nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3, 5).Return(nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 3).Return(sender, nil).Times(3)
+				mr.EXPECT().GetTransferLimitOverride(gomock.Any(), 3, "").Return(models.TransferLimitOverride{}, nil).Times(2)
+				mr.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 30).Return(70, nil, nil)
+				mr.EXPECT().DepositUserCoins(gomock.Any(), 4, 30, gomock.Any()).Return(1030, nil)
+				mr.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 20).Return(50, nil, nil)
+				mr.EXPECT().DepositUserCoins(gomock.Any(), 5, 20, gomock.Any()).Return(1020, nil)
+				mr.EXPECT().AddTransaction(gomock.Any(), gomock.Any()).Return(40, nil)
+				mr.EXPECT().AddTransaction(gomock.Any(), gomock.Any()).Return(41, nil)
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditTransfer, 3)).Times(2).Return(nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), gomock.Any()).Times(4).Return(nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(6).Return(nil)
+			},
+			wantStatuses: []string{service.TransferStatusCompleted, service.TransferStatusCompleted},
+		},
+		{
+			name:      "Unknown recipient rejects the whole batch",
+			transfers: transfers,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(models.User{ID: 4, Username: "testuser4"}, nil)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser5").Return(models.User{}, sql.ErrNoRows)
+			},
+			wantStatuses: []string{service.BatchItemSkipped, service.BatchItemFailed},
+			wantErr:      "пакет содержит ошибки",
+		},
+		{
+			name:      "Total exceeds balance",
+			transfers: transfers,
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(models.User{ID: 4, Username: "testuser4"}, nil)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser5").Return(models.User{ID: 5, Username: "testuser5"}, nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3, 4, 5).Return(nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 3).Return(models.User{ID: 3, Username: "testuser3", Coins: 40}, nil)
+			},
+			wantErr: "недостаточно монет",
+		},
+		{
+			name:      "Duplicate recipient",
+			transfers: []service.BatchTransfer{{ToUser: "testuser4", Amount: 1}, {ToUser: "testuser4", Amount: 2}},
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(models.User{ID: 4, Username: "testuser4"}, nil)
+			},
+			wantStatuses: []string{service.BatchItemSkipped, service.BatchItemFailed},
+			wantErr:      "пакет содержит ошибки",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			results, err := svc.SendCoinBatch(context.Background(), 3, tt.transfers, service.TransferOptions{})
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				var batchErr *service.BatchTransferError
+				if errors.As(err, &batchErr) {
+					results = batchErr.Results
+				}
+			} else {
+				require.NoError(t, err)
+			}
+			require.Len(t, results, len(tt.wantStatuses))
+			for i, status := range tt.wantStatuses {
+				require.Equal(t, status, results[i].Status)
+			}
+		})
+	}
+}
+
+func TestService_RunScheduledTransfers(t *testing.T) {
+	lease := time.Now().Add(5 * time.Minute).Truncate(time.Microsecond)
+	scheduled := models.ScheduledTransfer{
+		ID:         11,
+		FromUserID: 3,
+		ToUserID:   4,
+		ToUsername: "testuser4",
+		Amount:     100,
+		Interval:   models.IntervalMonthly,
+		Status:     models.ScheduleActive,
+		NextRunAt:  time.Now().Add(-time.Minute),
+		RetryAt:    lease,
+	}
+
+	tests := []struct {
+		name              string
+		prepareRepository func(*mocks.MockRepository)
+	}{
+		{
+			name: "Recurring transfer moves to the next month",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				expectTx(mr)
+				mr.EXPECT().
+					ClaimDueScheduledTransfers(gomock.Any(), gomock.Any(), gomock.Any(), 50).
+					Return([]models.ScheduledTransfer{{ID: 11, RetryAt: lease}}, nil)
+				mr.EXPECT().GetScheduledTransferForUpdate(gomock.Any(), 11).Return(scheduled, nil)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(models.User{ID: 4, Username: "testuser4"}, nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 3).Return(models.User{ID: 3, Username: "testuser3", Coins: 1000}, nil)
+				mr.EXPECT().GetTransferLimitOverride(gomock.Any(), 3, "").Return(models.TransferLimitOverride{}, nil)
+				mr.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 100).Return(900, nil, nil)
+				mr.EXPECT().DepositUserCoins(gomock.Any(), 4, 100, gomock.Any()).Return(1100, nil)
+				mr.EXPECT().AddTransaction(gomock.Any(), gomock.Any()).Return(42, nil)
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditTransfer, 3)).Return(nil)
+				mr.EXPECT().AddOutboxEvent(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(3).Return(nil)
+				mr.EXPECT().
+					UpdateScheduledTransfer(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, st models.ScheduledTransfer) error {
+						require.Equal(t, models.ScheduleActive, st.Status)
+						require.Equal(t, 42, st.LastTransactionID)
+						require.Equal(t, scheduled.NextRunAt.AddDate(0, 1, 0), st.NextRunAt)
+						require.Equal(t, st.NextRunAt, st.RetryAt)
+						return nil
+					})
+			},
+		},
+		{
+			name: "Failed attempt is retried later",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				expectTx(mr)
+				expectTx(mr)
+				mr.EXPECT().
+					ClaimDueScheduledTransfers(gomock.Any(), gomock.Any(), gomock.Any(), 50).
+					Return([]models.ScheduledTransfer{{ID: 11, RetryAt: lease}}, nil)
+				mr.EXPECT().GetScheduledTransferForUpdate(gomock.Any(), 11).Return(scheduled, nil).Times(2)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(models.User{ID: 4, Username: "testuser4"}, nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3, 4).Return(nil)
+				mr.EXPECT().GetUserByID(gomock.Any(), 3).Return(models.User{ID: 3, Username: "testuser3", Coins: 10}, nil)
+				mr.EXPECT().GetTransferLimitOverride(gomock.Any(), 3, "").Return(models.TransferLimitOverride{}, nil)
+				mr.EXPECT().WithdrawUserCoins(gomock.Any(), 3, 100).Return(0, nil, errors.New("недостаточно монет"))
+				mr.EXPECT().
+					UpdateScheduledTransfer(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, st models.ScheduledTransfer) error {
+						require.Equal(t, models.ScheduleActive, st.Status)
+						require.Equal(t, 1, st.Attempts)
+						require.Equal(t, "недостаточно монет", st.LastError)
+						require.Equal(t, scheduled.NextRunAt, st.NextRunAt)
+						require.True(t, st.RetryAt.After(time.Now()))
+						return nil
+					})
+			},
+		},
+		{
+			name: "Last failed attempt notifies the owner",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				expectTx(mr)
+				expectTx(mr)
+				once := scheduled
+				once.Interval = models.IntervalOnce
+				once.Attempts = 2
+				mr.EXPECT().
+					ClaimDueScheduledTransfers(gomock.Any(), gomock.Any(), gomock.Any(), 50).
+					Return([]models.ScheduledTransfer{{ID: 11, RetryAt: lease}}, nil)
+				mr.EXPECT().GetScheduledTransferForUpdate(gomock.Any(), 11).Return(once, nil).Times(2)
+				mr.EXPECT().GetUserByUsername(gomock.Any(), "testuser4").Return(models.User{}, sql.ErrNoRows)
+				mr.EXPECT().
+					UpdateScheduledTransfer(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, st models.ScheduledTransfer) error {
+						require.Equal(t, models.ScheduleFailed, st.Status)
+						return nil
+					})
+				mr.EXPECT().
+					NotifyUser(gomock.Any(), gomock.Any()).
+					DoAndReturn(func(_ context.Context, payload []byte) error {
+						var n models.UserNotification
+						require.NoError(t, json.Unmarshal(payload, &n))
+						require.Equal(t, 3, n.UserID)
+						require.Equal(t, models.NotificationScheduledFailed, n.Type)
+						return nil
+					})
+			},
+		},
+		{
+			name: "Lease taken over by another instance",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				taken := scheduled
+				taken.RetryAt = lease.Add(time.Minute)
+				mr.EXPECT().
+					ClaimDueScheduledTransfers(gomock.Any(), gomock.Any(), gomock.Any(), 50).
+					Return([]models.ScheduledTransfer{{ID: 11, RetryAt: lease}}, nil)
+				mr.EXPECT().GetScheduledTransferForUpdate(gomock.Any(), 11).Return(taken, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret", service.WithScheduleRetry(3, time.Minute))
+			require.NoError(t, svc.RunScheduledTransfers(context.Background()))
+		})
+	}
+}
+
+func TestService_RunDueGrants(t *testing.T) {
+	period := time.Now().UTC().Format("2006-01")
+	policy := models.GrantPolicy{
+		ID:       2,
+		Name:     "Ежемесячное начисление",
+		Amount:   100,
+		Interval: models.IntervalMonthly,
+		Role:     models.RoleUser,
+		Active:   true,
+	}
+	users := []models.User{
+		{ID: 3, Username: "testuser3", Role: models.RoleUser},
+		{ID: 4, Username: "boss", Role: models.RoleUser},
+		{ID: 5, Username: "testuser5", Role: models.RoleManager},
+	}
+
+	tests := []struct {
+		name              string
+		prepareRepository func(*mocks.MockRepository)
+	}{
+		{
+			name: "Credits users with the policy role",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().ListGrantPolicies(gomock.Any(), true).Return([]models.GrantPolicy{policy}, nil)
+				mr.EXPECT().ListGrantRecipients(gomock.Any(), "").Return(users, nil)
+				mr.EXPECT().
+					CreateGrantRun(gomock.Any(), models.GrantRun{PolicyID: 2, Period: period, Recipients: 1, Total: 100}).
+					Return(1, true, nil)
+				mr.EXPECT().UpdateUserCoins(gomock.Any(), 3, 100).Return(1100, nil)
+				mr.EXPECT().
+					AddTransaction(gomock.Any(), models.Transaction{
+						ToUserID:  3,
+						Amount:    100,
+						Type:      models.TransactionTypeGrant,
+						Reason:    "Ежемесячное начисление",
+						Reference: "grant:2:" + period,
+					}).
+					Return(50, nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Return(nil)
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditGrantRun, 0)).Return(nil)
+			},
+		},
+		{
+			name: "Period already granted by another instance",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().ListGrantPolicies(gomock.Any(), true).Return([]models.GrantPolicy{policy}, nil)
+				mr.EXPECT().ListGrantRecipients(gomock.Any(), "").Return(users, nil)
+				mr.EXPECT().CreateGrantRun(gomock.Any(), gomock.Any()).Return(0, false, nil)
+			},
+		},
+		{
+			name: "Period already granted",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				granted := policy
+				granted.LastPeriod = period
+				mr.EXPECT().ListGrantPolicies(gomock.Any(), true).Return([]models.GrantPolicy{granted}, nil)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret", service.WithAdmins("boss"))
+			require.NoError(t, svc.RunDueGrants(context.Background()))
+		})
+	}
+}
+
+func TestService_ExpireCoins(t *testing.T) {
+	tests := []struct {
+		name              string
+		prepareRepository func(*mocks.MockRepository)
+		wantErr           string
+	}{
+		{
+			name: "Expired lots are written off",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().ListUsersWithExpiredCoinLots(gomock.Any(), gomock.Any(), 100).Return([]int{3}, nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3).Return(nil)
+				mr.EXPECT().SumExpiredCoinLots(gomock.Any(), 3, gomock.Any()).Return(150, nil)
+				mr.EXPECT().UpdateUserCoins(gomock.Any(), 3, -150).Return(850, nil)
+				mr.EXPECT().
+					AddTransaction(gomock.Any(), models.Transaction{
+						FromUserID: 3,
+						Amount:     150,
+						Type:       models.TransactionTypeExpiry,
+						Reason:     "Истёк срок действия монет",
+					}).
+					Return(60, nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Return(nil)
+			},
+		},
+		{
+			name: "Already expired by another instance",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().ListUsersWithExpiredCoinLots(gomock.Any(), gomock.Any(), 100).Return([]int{3}, nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3).Return(nil)
+				mr.EXPECT().SumExpiredCoinLots(gomock.Any(), 3, gomock.Any()).Return(0, nil)
+			},
+		},
+		{
+			name: "Failure for one user does not stop the others",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				expectTx(mr)
+				mr.EXPECT().ListUsersWithExpiredCoinLots(gomock.Any(), gomock.Any(), 100).Return([]int{3, 4}, nil)
+				mr.EXPECT().LockUsers(gomock.Any(), 3).Return(errors.New("deadlock detected"))
+				mr.EXPECT().LockUsers(gomock.Any(), 4).Return(nil)
+				mr.EXPECT().SumExpiredCoinLots(gomock.Any(), 4, gomock.Any()).Return(20, nil)
+				mr.EXPECT().UpdateUserCoins(gomock.Any(), 4, -20).Return(980, nil)
+				mr.EXPECT().AddTransaction(gomock.Any(), gomock.Any()).Return(61, nil)
+				mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Return(nil)
+			},
+			wantErr: "пользователь 3: deadlock detected",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			err := svc.ExpireCoins(context.Background())
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestService_RequestReturn(t *testing.T) {
+	recent := models.Purchase{
+		ID: 11, UserID: 3, Item: "hoody", Quantity: 1, Price: 300, OriginalPrice: 300,
+		CreatedAt: time.Now().Add(-24 * time.Hour),
+	}
+	tests := []struct {
+		name              string
+		prepareRepository func(*mocks.MockRepository)
+		wantErr           bool
+	}{
+		{
+			name: "Return requested",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().GetPurchaseForUpdate(gomock.Any(), 11).Return(recent, nil)
+				mr.EXPECT().
+					ListPurchaseReturns(gomock.Any(), models.PurchaseReturnFilter{PurchaseID: 11}).
+					Return([]models.PurchaseReturn{{ID: 1, Status: models.ReturnRejected}}, nil)
+				mr.EXPECT().CreatePurchaseReturn(gomock.Any(), gomock.Any()).Return(2, nil)
+				mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditReturnRequest, 3)).Return(nil)
+			},
+		},
+		{
+			name: "Another user's purchase",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				other := recent
+				other.UserID = 4
+				expectTx(mr)
+				mr.EXPECT().GetPurchaseForUpdate(gomock.Any(), 11).Return(other, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Return window passed",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				old := recent
+				old.CreatedAt = time.Now().Add(-30 * 24 * time.Hour)
+				expectTx(mr)
+				mr.EXPECT().GetPurchaseForUpdate(gomock.Any(), 11).Return(old, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Return already pending",
+			prepareRepository: func(mr *mocks.MockRepository) {
+				expectTx(mr)
+				mr.EXPECT().GetPurchaseForUpdate(gomock.Any(), 11).Return(recent, nil)
+				mr.EXPECT().
+					ListPurchaseReturns(gomock.Any(), models.PurchaseReturnFilter{PurchaseID: 11}).
+					Return([]models.PurchaseReturn{{ID: 1, Status: models.ReturnPending}}, nil)
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			defer ctrl.Finish()
+
+			mockRepo := mocks.NewMockRepository(ctrl)
+			tt.prepareRepository(mockRepo)
+
+			svc := service.NewService(mockRepo, "secret")
+			_, err := svc.RequestReturn(context.Background(), 3, 11, "Не подошёл размер")
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestService_ApproveReturn(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	mr := mocks.NewMockRepository(ctrl)
+	expectTx(mr)
+	mr.EXPECT().GetPurchaseReturnForUpdate(gomock.Any(), 2).Return(models.PurchaseReturn{
+		ID: 2, PurchaseID: 11, UserID: 3, Item: "hoody", Amount: 300, Status: models.ReturnPending,
+	}, nil)
+	mr.EXPECT().GetPurchaseForUpdate(gomock.Any(), 11).Return(models.Purchase{
+		ID: 11, UserID: 3, Item: "hoody", VariantID: 7, Quantity: 1, Price: 300, PaidBonus: 50, OriginalPrice: 300,
+	}, nil)
+	// Блокировки берутся в том же порядке, что и при покупке: вариант,
+	// промокод, пользователь.
+	gomock.InOrder(
+		mr.EXPECT().RestockItemVariant(gomock.Any(), 7, 1).Return(nil),
+		mr.EXPECT().ReleasePromoRedemption(gomock.Any(), 11).Return(nil),
+		mr.EXPECT().LockUsers(gomock.Any(), 3).Return(nil),
+	)
+	mr.EXPECT().GetUserByID(gomock.Any(), 3).Return(models.User{ID: 3, Username: "testuser3", Coins: 100}, nil)
+	mr.EXPECT().DecrementPurchase(gomock.Any(), 11).Return(nil)
+	mr.EXPECT().UpdateUserBonusCoins(gomock.Any(), 3, 50).Return(50, nil)
+	mr.EXPECT().AddTransaction(gomock.Any(), models.Transaction{
+		ToUserID: 3, Amount: 50, Type: models.TransactionTypeRefund, Wallet: models.WalletBonus,
+		Reference: "purchase:11", ActorID: 1,
+	}).Return(20, nil)
+	expires := time.Now().Add(24 * time.Hour)
+	lots := []models.CoinLot{{Amount: 250, Remaining: 250, ExpiresAt: &expires}}
+	mr.EXPECT().TakePurchaseCoinLots(gomock.Any(), 11).Return(lots, nil)
+	// Возвращённые монеты сохраняют прежний срок действия.
+	mr.EXPECT().DepositUserCoins(gomock.Any(), 3, 250, lots).Return(350, nil)
+	mr.EXPECT().AddTransaction(gomock.Any(), models.Transaction{
+		ToUserID: 3, Amount: 250, Type: models.TransactionTypeRefund, Reference: "purchase:11", ActorID: 1,
+	}).Return(21, nil)
+	mr.EXPECT().UpdatePurchaseReturn(gomock.Any(), gomock.Any()).DoAndReturn(
+		func(_ context.Context, ret models.PurchaseReturn) error {
+			require.Equal(t, models.ReturnApproved, ret.Status)
+			require.Equal(t, 21, ret.TransactionID)
+			require.Equal(t, 20, ret.BonusTransactionID)
+			require.Equal(t, 1, ret.ApproverID)
+			return nil
+		},
+	)
+	mr.EXPECT().AddAuditEntry(gomock.Any(), auditEntry(service.AuditReturnApprove, 1)).Return(nil)
+	mr.EXPECT().NotifyUser(gomock.Any(), gomock.Any()).Times(2).Return(nil)
+
+	svc := service.NewService(mr, "secret")
+	require.NoError(t, svc.ApproveReturn(context.Background(), 1, 2))
+}
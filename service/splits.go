@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"AVTproject/models"
+)
+
+const maxSplitParticipants = 50
+
+const (
+	SplitStatusOpen    = "open"
+	SplitStatusSettled = "settled"
+	SplitStatusClosed  = "closed"
+)
+
+type SplitShare struct {
+	Username string `json:"username"`
+	Amount   int    `json:"amount,omitempty"`
+}
+
+type BillSplitRequest struct {
+	Total          int
+	Memo           string
+	Participants   []SplitShare
+	IncludeCreator bool
+}
+
+type BillSplitInfo struct {
+	ID          int               `json:"id"`
+	Creator     string            `json:"creator"`
+	Total       int               `json:"total"`
+	Memo        string            `json:"memo,omitempty"`
+	CreatorPart int               `json:"creatorShare"`
+	Requested   int               `json:"requested"`
+	Paid        int               `json:"paid"`
+	Processing  int               `json:"processing"`
+	Outstanding int               `json:"outstanding"`
+	Status      string            `json:"status"`
+	Requests    []CoinRequestInfo `json:"requests"`
+	CreatedAt   time.Time         `json:"createdAt"`
+}
+
+// CreateBillSplit делит сумму между коллегами и создаёт каждому запрос монет.
+// Если доли не указаны, сумма делится поровну; остаток от деления достаётся
+// первым участникам. С IncludeCreator создатель тоже получает долю, но
+// запрос на неё не создаётся.
+func (s Service) CreateBillSplit(ctx context.Context, creatorID int, req BillSplitRequest) (BillSplitInfo, error) {
+	opts, err := TransferOptions{Memo: req.Memo}.normalize()
+	if err != nil {
+		return BillSplitInfo{}, err
+	}
+	shares, err := splitShares(req)
+	if err != nil {
+		return BillSplitInfo{}, err
+	}
+
+	var info BillSplitInfo
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		creator, err := s.repo.GetUserByID(ctx, creatorID)
+		if err != nil {
+			return err
+		}
+		payers := make([]models.User, 0, len(shares))
+		for _, share := range shares {
+			payer, err := s.repo.GetUserByUsername(ctx, share.Username)
+			if err != nil {
+				return err
+			}
+			if payer.ID == creator.ID {
+				return errors.New("создатель не может быть участником счёта")
+			}
+			payers = append(payers, payer)
+		}
+
+		split := models.BillSplit{
+			CreatorID:       creator.ID,
+			CreatorUsername: creator.Username,
+			Total:           req.Total,
+			Memo:            opts.Memo,
+			CreatedAt:       time.Now(),
+		}
+		if split.ID, err = s.repo.CreateBillSplit(ctx, split); err != nil {
+			return err
+		}
+		requests := make([]models.CoinRequest, 0, len(shares))
+		for i, share := range shares {
+			cr, err := s.createCoinRequest(ctx, creator, payers[i], share.Amount, opts.Memo, split.ID)
+			if err != nil {
+				return err
+			}
+			requests = append(requests, cr)
+		}
+		info = toBillSplitInfo(split, requests)
+		return nil
+	})
+	if err != nil {
+		return BillSplitInfo{}, err
+	}
+	return info, nil
+}
+
+func (s Service) GetBillSplit(ctx context.Context, userID, id int) (BillSplitInfo, error) {
+	split, err := s.repo.GetBillSplit(ctx, id)
+	if err != nil {
+		return BillSplitInfo{}, err
+	}
+	requests, err := s.repo.ListCoinRequests(ctx, models.CoinRequestFilter{SplitID: id})
+	if err != nil {
+		return BillSplitInfo{}, err
+	}
+	if split.CreatorID != userID && !hasPayer(requests, userID) {
+		return BillSplitInfo{}, sql.ErrNoRows
+	}
+	return toBillSplitInfo(split, requests), nil
+}
+
+func (s Service) ListBillSplits(ctx context.Context, userID int) ([]BillSplitInfo, error) {
+	splits, err := s.repo.ListBillSplits(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(splits) == 0 {
+		return []BillSplitInfo{}, nil
+	}
+	requests, err := s.repo.ListCoinRequests(ctx, models.CoinRequestFilter{RequesterID: userID})
+	if err != nil {
+		return nil, err
+	}
+	bySplit := make(map[int][]models.CoinRequest)
+	for _, cr := range requests {
+		if cr.SplitID != 0 {
+			bySplit[cr.SplitID] = append(bySplit[cr.SplitID], cr)
+		}
+	}
+	result := make([]BillSplitInfo, 0, len(splits))
+	for _, split := range splits {
+		result = append(result, toBillSplitInfo(split, bySplit[split.ID]))
+	}
+	return result, nil
+}
+
+func splitShares(req BillSplitRequest) ([]SplitShare, error) {
+	if req.Total <= 0 {
+		return nil, errors.New("сумма счёта должна быть положительной")
+	}
+	if len(req.Participants) == 0 {
+		return nil, errors.New("не указаны участники счёта")
+	}
+	if len(req.Participants) > maxSplitParticipants {
+		return nil, errors.New("слишком много участников счёта")
+	}
+
+	custom := req.Participants[0].Amount != 0
+	seen := make(map[string]bool, len(req.Participants))
+	for _, p := range req.Participants {
+		if p.Username == "" || seen[p.Username] {
+			return nil, errors.New("участники счёта должны быть указаны без повторов")
+		}
+		seen[p.Username] = true
+		if (p.Amount != 0) != custom {
+			return nil, errors.New("доли нужно указать для всех участников или ни для кого")
+		}
+		if p.Amount < 0 {
+			return nil, errors.New("доля участника должна быть положительной")
+		}
+	}
+
+	shares := make([]SplitShare, len(req.Participants))
+	copy(shares, req.Participants)
+	if custom {
+		sum := 0
+		for _, share := range shares {
+			sum += share.Amount
+		}
+		if sum > req.Total || (!req.IncludeCreator && sum != req.Total) {
+			return nil, errors.New("сумма долей не совпадает с суммой счёта")
+		}
+		return shares, nil
+	}
+
+	parts := len(shares)
+	if req.IncludeCreator {
+		parts++
+	}
+	base, remainder := req.Total/parts, req.Total%parts
+	if base == 0 {
+		return nil, errors.New("сумма счёта меньше числа участников")
+	}
+	for i := range shares {
+		shares[i].Amount = base
+		if i < remainder {
+			shares[i].Amount++
+		}
+	}
+	return shares, nil
+}
+
+func toBillSplitInfo(split models.BillSplit, requests []models.CoinRequest) BillSplitInfo {
+	info := BillSplitInfo{
+		ID:        split.ID,
+		Creator:   split.CreatorUsername,
+		Total:     split.Total,
+		Memo:      split.Memo,
+		Requests:  toCoinRequestInfos(requests),
+		CreatedAt: split.CreatedAt,
+	}
+	open := false
+	for _, cr := range requests {
+		info.Requested += cr.Amount
+		// Оплата на согласовании ещё может не состояться, поэтому такая доля
+		// не считается оплаченной и не закрывает счёт.
+		switch cr.Status {
+		case models.CoinRequestPaid:
+			info.Paid += cr.Amount
+		case models.CoinRequestPaymentPending:
+			open = true
+			info.Processing += cr.Amount
+		case models.CoinRequestPending:
+			open = true
+			info.Outstanding += cr.Amount
+		}
+	}
+	info.CreatorPart = split.Total - info.Requested
+
+	switch {
+	case open:
+		info.Status = SplitStatusOpen
+	case info.Paid == info.Requested:
+		info.Status = SplitStatusSettled
+	default:
+		info.Status = SplitStatusClosed
+	}
+	return info
+}
+
+func hasPayer(requests []models.CoinRequest, userID int) bool {
+	for _, cr := range requests {
+		if cr.PayerID == userID {
+			return true
+		}
+	}
+	return false
+}
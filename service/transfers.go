@@ -0,0 +1,66 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"AVTproject/models"
+)
+
+const maxMemoLength = 200
+
+const (
+	TransferStatusCompleted          = "completed"
+	TransferStatusPending            = "pending"
+	TransferStatusAwaitingAcceptance = "awaiting_acceptance"
+)
+
+var transferCategories = map[string]bool{
+	models.CategoryThanks:        true,
+	models.CategoryReimbursement: true,
+	models.CategoryGift:          true,
+	models.CategoryBonus:         true,
+	models.CategoryOther:         true,
+}
+
+type TransferOptions struct {
+	Memo               string
+	Category           string
+	Public             bool
+	RequiresAcceptance bool
+}
+
+type TransferResult struct {
+	Status            string `json:"status"`
+	TransactionID     int    `json:"transactionId,omitempty"`
+	PendingTransferID int    `json:"pendingTransferId,omitempty"`
+}
+
+func (o TransferOptions) normalize() (TransferOptions, error) {
+	o.Memo = sanitizeMemo(o.Memo)
+	if utf8.RuneCountInString(o.Memo) > maxMemoLength {
+		return TransferOptions{}, errors.New("комментарий к переводу слишком длинный")
+	}
+	o.Category = strings.ToLower(strings.TrimSpace(o.Category))
+	if o.Category != "" && !transferCategories[o.Category] {
+		return TransferOptions{}, errors.New("неизвестная категория перевода")
+	}
+	return o, nil
+}
+
+// sanitizeMemo убирает управляющие и невидимые символы форматирования
+// (в том числе переопределения направления текста) и схлопывает пробелы.
+func sanitizeMemo(memo string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsSpace(r):
+			return ' '
+		case unicode.IsControl(r), unicode.Is(unicode.Cf, r), r == utf8.RuneError:
+			return -1
+		}
+		return r
+	}, memo)
+	return strings.Join(strings.Fields(cleaned), " ")
+}
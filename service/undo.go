@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"AVTproject/models"
+)
+
+type CoinReversedEvent struct {
+	TransactionID int    `json:"transactionId"`
+	ReversalID    int    `json:"reversalId"`
+	FromUser      string `json:"fromUser"`
+	ToUser        string `json:"toUser"`
+	Amount        int    `json:"amount"`
+}
+
+// WithUndoWindow задаёт время, в течение которого отправитель может отменить
+// перевод. Нулевое значение отключает отмену.
+func WithUndoWindow(window time.Duration) Option {
+	return func(s *Service) {
+		s.undoWindow = window
+	}
+}
+
+// UndoTransfer отменяет перевод встречной транзакцией, связанной с исходной.
+// Исходная запись в transactions не изменяется и не удаляется.
+func (s Service) UndoTransfer(ctx context.Context, userID, transactionID int) (TransactionInfo, error) {
+	var reversal models.Transaction
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		t, err := s.repo.GetTransactionByID(ctx, transactionID)
+		if err != nil {
+			return err
+		}
+		if t.Type != models.TransactionTypeTransfer || t.FromUserID != userID {
+			return sql.ErrNoRows
+		}
+		if s.undoWindow <= 0 || time.Since(t.CreatedAt) > s.undoWindow {
+			return errors.New("время отмены перевода истекло")
+		}
+		if err := s.repo.LockUsers(ctx, t.FromUserID, t.ToUserID); err != nil {
+			return err
+		}
+		reversed, err := s.repo.HasReversal(ctx, t.ID)
+		if err != nil {
+			return err
+		}
+		if reversed {
+			return errors.New("перевод уже отменён")
+		}
+		sender, err := s.repo.GetUserByID(ctx, t.FromUserID)
+		if err != nil {
+			return err
+		}
+		receiver, err := s.repo.GetUserByID(ctx, t.ToUserID)
+		if err != nil {
+			return err
+		}
+		if receiver.Coins < t.Amount {
+			return errors.New("получатель уже потратил монеты, отмена невозможна")
+		}
+
+		receiverCoins, lots, err := s.repo.WithdrawUserCoins(ctx, receiver.ID, t.Amount)
+		if err != nil {
+			return err
+		}
+		senderCoins, err := s.repo.DepositUserCoins(ctx, sender.ID, t.Amount, lots)
+		if err != nil {
+			return err
+		}
+		reversal = models.Transaction{
+			FromUserID: receiver.ID,
+			ToUserID:   sender.ID,
+			Amount:     t.Amount,
+			Type:       models.TransactionTypeReversal,
+			ReversalOf: t.ID,
+		}
+		if reversal.ID, err = s.repo.AddTransaction(ctx, reversal); err != nil {
+			return err
+		}
+		if err := s.reopenCoinRequest(ctx, t.ID, 0, models.CoinRequestPaid); err != nil {
+			return err
+		}
+
+		if err := s.audit(
+			ctx,
+			userID,
+			AuditTransferUndo,
+			userTarget(receiver.Username),
+			map[string]int{"senderCoins": senderCoins - t.Amount, "receiverCoins": receiverCoins + t.Amount},
+			map[string]int{"senderCoins": senderCoins, "receiverCoins": receiverCoins, "reversalOf": t.ID},
+		); err != nil {
+			return err
+		}
+
+		data := CoinReversedEvent{
+			TransactionID: t.ID,
+			ReversalID:    reversal.ID,
+			FromUser:      sender.Username,
+			ToUser:        receiver.Username,
+			Amount:        t.Amount,
+		}
+		if err := s.recordEvent(ctx, models.EventCoinReversed, sender.ID, data); err != nil {
+			return err
+		}
+		if err := s.recordEvent(ctx, models.EventCoinReversed, receiver.ID, data); err != nil {
+			return err
+		}
+		if err := s.notifyBalance(ctx, sender.ID, senderCoins, t.Amount); err != nil {
+			return err
+		}
+		return s.notifyBalance(ctx, receiver.ID, receiverCoins, -t.Amount)
+	})
+	if err != nil {
+		return TransactionInfo{}, err
+	}
+	return toTransactionInfo(reversal, reversal.FromUserID), nil
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode/utf8"
+
+	"AVTproject/models"
+)
+
+const (
+	maxVariantSizeLength  = 20
+	maxVariantColorLength = 30
+)
+
+type ItemVariantRequest struct {
+	Size  string
+	Color string
+	Stock int
+	Price *int
+}
+
+// SetItemVariant создаёт вариант товара или обновляет остаток и цену
+// существующего. Вариант определяется парой размер/цвет.
+func (s Service) SetItemVariant(
+	ctx context.Context,
+	actorID int,
+	item string,
+	req ItemVariantRequest,
+) (CatalogVariant, error) {
+	if _, ok := merchPrices[item]; !ok {
+		return CatalogVariant{}, errors.New("неверное название мерча")
+	}
+	size, color := normalizeVariant(req.Size, req.Color)
+	if size == "" && color == "" {
+		return CatalogVariant{}, errors.New("необходимо указать размер или цвет")
+	}
+	if utf8.RuneCountInString(size) > maxVariantSizeLength ||
+		utf8.RuneCountInString(color) > maxVariantColorLength {
+		return CatalogVariant{}, errors.New("слишком длинное название варианта")
+	}
+	if req.Stock < 0 {
+		return CatalogVariant{}, errors.New("остаток не может быть отрицательным")
+	}
+	if req.Price != nil && *req.Price <= 0 {
+		return CatalogVariant{}, errors.New("цена должна быть положительной")
+	}
+
+	var resp CatalogVariant
+	err := s.repo.WithTx(ctx, func(ctx context.Context) error {
+		v, err := s.repo.UpsertItemVariant(ctx, models.ItemVariant{
+			Item:  item,
+			Size:  size,
+			Color: color,
+			Stock: req.Stock,
+			Price: req.Price,
+		})
+		if err != nil {
+			return err
+		}
+		resp = CatalogVariant{Size: v.Size, Color: v.Color, Price: variantPrice(v), Stock: v.Stock}
+		return s.audit(ctx, actorID, AuditItemVariant, "item:"+item+":"+variantLabel(v), nil, resp)
+	})
+	if err != nil {
+		return CatalogVariant{}, err
+	}
+	return resp, nil
+}
+
+// selectVariant находит вариант, выбранный покупателем. Для товаров без
+// вариантов возвращает nil.
+func selectVariant(variants []models.ItemVariant, opts PurchaseOptions) (*models.ItemVariant, error) {
+	size, color := normalizeVariant(opts.Size, opts.Color)
+	if len(variants) == 0 {
+		if size != "" || color != "" {
+			return nil, errors.New("у этого мерча нет вариантов")
+		}
+		return nil, nil
+	}
+	if size == "" && color == "" {
+		return nil, errors.New("необходимо выбрать размер или цвет")
+	}
+	for i := range variants {
+		if variants[i].Size == size && variants[i].Color == color {
+			return &variants[i], nil
+		}
+	}
+	return nil, errors.New("такого варианта мерча нет")
+}
+
+func normalizeVariant(size, color string) (string, string) {
+	return strings.ToUpper(strings.TrimSpace(size)), strings.ToLower(strings.TrimSpace(color))
+}
+
+func variantPrice(v models.ItemVariant) int {
+	if v.Price != nil {
+		return *v.Price
+	}
+	return merchPrices[v.Item]
+}
+
+func variantLabel(v models.ItemVariant) string {
+	return strings.Trim(v.Size+"/"+v.Color, "/")
+}
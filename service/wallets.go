@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+
+	"AVTproject/models"
+)
+
+const (
+	operationTransfer = "transfer"
+	operationPurchase = "purchase"
+)
+
+// operationWallets задаёт, из каких кошельков можно платить за операцию и в
+// каком порядке они расходуются. Обычный кошелёк всегда последний: он
+// покрывает остаток и выдаёт ошибку, если монет не хватает.
+var operationWallets = map[string][]string{
+	operationTransfer: {models.WalletRegular},
+	operationPurchase: {models.WalletBonus, models.WalletRegular},
+}
+
+// creditOperations — операции, для которых обычный баланс может уйти в минус
+// в пределах кредитного лимита пользователя.
+var creditOperations = map[string]bool{
+	operationPurchase: true,
+}
+
+// WalletSpend — сколько списано из каждого кошелька и какой остаток в нём.
+// Lots — израсходованные партии обычного кошелька.
+type WalletSpend struct {
+	Regular    int
+	Bonus      int
+	Coins      int
+	BonusCoins int
+	Lots       []models.CoinLot
+}
+
+// spend списывает amount с кошельков пользователя по правилам операции.
+// Пользователь должен быть заблокирован вызывающим.
+func (s Service) spend(ctx context.Context, user models.User, operation string, amount int) (WalletSpend, error) {
+	res := WalletSpend{Coins: user.Coins, BonusCoins: user.BonusCoins}
+	remaining := amount
+	var err error
+	for _, wallet := range operationWallets[operation] {
+		switch wallet {
+		case models.WalletBonus:
+			take := min(remaining, user.BonusCoins)
+			if take <= 0 {
+				continue
+			}
+			if res.BonusCoins, err = s.repo.UpdateUserBonusCoins(ctx, user.ID, -take); err != nil {
+				return WalletSpend{}, err
+			}
+			res.Bonus = take
+			remaining -= take
+		case models.WalletRegular:
+			if remaining <= 0 {
+				continue
+			}
+			// Израсходованные партии сохраняют срок действия у получателя
+			// перевода и при возврате покупки.
+			if creditOperations[operation] && user.CreditLimit > 0 {
+				res.Coins, res.Lots, err = s.repo.ChargeUserCoins(ctx, user.ID, remaining, user.CreditLimit)
+			} else {
+				res.Coins, res.Lots, err = s.repo.WithdrawUserCoins(ctx, user.ID, remaining)
+			}
+			if err != nil {
+				return WalletSpend{}, err
+			}
+			res.Regular = remaining
+			remaining = 0
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"AVTproject/models"
+)
+
+var webhookEventTypes = map[string]bool{
+	models.EventCoinSent:      true,
+	models.EventCoinReceived:  true,
+	models.EventItemPurchased: true,
+	models.EventCoinReversed:  true,
+}
+
+type WebhookInfo struct {
+	ID         int       `json:"id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	EventTypes []string  `json:"eventTypes"`
+	Active     bool      `json:"active"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+type WebhookDeliveryInfo struct {
+	ID           int             `json:"id"`
+	EventType    string          `json:"eventType"`
+	Payload      json.RawMessage `json:"payload"`
+	Status       string          `json:"status"`
+	Attempts     int             `json:"attempts"`
+	ResponseCode int             `json:"responseCode,omitempty"`
+	LastError    string          `json:"lastError,omitempty"`
+	NextAttempt  *time.Time      `json:"nextAttemptAt,omitempty"`
+	CreatedAt    time.Time       `json:"createdAt"`
+	DeliveredAt  *time.Time      `json:"deliveredAt,omitempty"`
+}
+
+func (s Service) CreateWebhook(
+	ctx context.Context,
+	actorID int,
+	rawURL string,
+	eventTypes []string,
+	secret string,
+) (WebhookInfo, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return WebhookInfo{}, errors.New("неверный адрес вебхука")
+	}
+	if len(eventTypes) == 0 {
+		return WebhookInfo{}, errors.New("не указаны типы событий")
+	}
+	for _, eventType := range eventTypes {
+		if !webhookEventTypes[eventType] {
+			return WebhookInfo{}, errors.New("неизвестный тип события: " + eventType)
+		}
+	}
+	if secret == "" {
+		secret, err = randomSecret()
+		if err != nil {
+			return WebhookInfo{}, err
+		}
+	}
+
+	sub := models.WebhookSubscription{
+		URL:        rawURL,
+		Secret:     secret,
+		EventTypes: eventTypes,
+		Active:     true,
+		CreatedAt:  time.Now(),
+	}
+	err = s.repo.WithTx(ctx, func(ctx context.Context) error {
+		id, err := s.repo.CreateWebhookSubscription(ctx, sub)
+		if err != nil {
+			return err
+		}
+		sub.ID = id
+		return s.audit(ctx, actorID, AuditWebhookCreate, webhookTarget(id), nil, map[string]interface{}{
+			"url":        sub.URL,
+			"eventTypes": sub.EventTypes,
+		})
+	})
+	if err != nil {
+		return WebhookInfo{}, err
+	}
+
+	info := toWebhookInfo(sub)
+	info.Secret = secret
+	return info, nil
+}
+
+func (s Service) ListWebhooks(ctx context.Context) ([]WebhookInfo, error) {
+	subs, err := s.repo.ListWebhookSubscriptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]WebhookInfo, 0, len(subs))
+	for _, sub := range subs {
+		result = append(result, toWebhookInfo(sub))
+	}
+	return result, nil
+}
+
+func (s Service) DeleteWebhook(ctx context.Context, actorID, id int) error {
+	return s.repo.WithTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.DeactivateWebhookSubscription(ctx, id); err != nil {
+			return err
+		}
+		return s.audit(
+			ctx,
+			actorID,
+			AuditWebhookDelete,
+			webhookTarget(id),
+			map[string]bool{"active": true},
+			map[string]bool{"active": false},
+		)
+	})
+}
+
+func webhookTarget(id int) string {
+	return "webhook:" + strconv.Itoa(id)
+}
+
+func (s Service) ListWebhookDeliveries(
+	ctx context.Context,
+	subscriptionID, limit int,
+) ([]WebhookDeliveryInfo, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	deliveries, err := s.repo.ListWebhookDeliveries(ctx, subscriptionID, limit)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]WebhookDeliveryInfo, 0, len(deliveries))
+	for _, d := range deliveries {
+		info := WebhookDeliveryInfo{
+			ID:           d.ID,
+			EventType:    d.EventType,
+			Payload:      d.Payload,
+			Status:       d.Status,
+			Attempts:     d.Attempts,
+			ResponseCode: d.ResponseCode,
+			LastError:    d.LastError,
+			CreatedAt:    d.CreatedAt,
+			DeliveredAt:  d.DeliveredAt,
+		}
+		if d.Status == models.DeliveryStatusPending {
+			next := d.NextAttemptAt
+			info.NextAttempt = &next
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+func toWebhookInfo(sub models.WebhookSubscription) WebhookInfo {
+	return WebhookInfo{
+		ID:         sub.ID,
+		URL:        sub.URL,
+		EventTypes: sub.EventTypes,
+		Active:     sub.Active,
+		CreatedAt:  sub.CreatedAt,
+	}
+}
+
+func randomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
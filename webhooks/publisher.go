@@ -0,0 +1,29 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+
+	"AVTproject/outbox"
+)
+
+type Enqueuer interface {
+	EnqueueWebhookDeliveries(ctx context.Context, eventID, eventType string, payload []byte) error
+}
+
+// Publisher ставит события из outbox в очередь доставки вебхуков подписчикам.
+type Publisher struct {
+	store Enqueuer
+}
+
+func NewPublisher(store Enqueuer) Publisher {
+	return Publisher{store: store}
+}
+
+func (p Publisher) Publish(ctx context.Context, env outbox.Envelope) error {
+	payload, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	return p.store.EnqueueWebhookDeliveries(ctx, env.ID, env.Type, payload)
+}
@@ -0,0 +1,113 @@
+package webhooks_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"AVTproject/models"
+	"AVTproject/webhooks"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	due     []models.WebhookDelivery
+	updated []models.WebhookDelivery
+}
+
+func (s *fakeStore) ClaimDueWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	due := s.due
+	s.due = nil
+	return due, nil
+}
+
+func (s *fakeStore) UpdateWebhookDelivery(ctx context.Context, d models.WebhookDelivery) error {
+	s.updated = append(s.updated, d)
+	return nil
+}
+
+func TestDispatcher_DeliverDue(t *testing.T) {
+	payload := []byte(`{"type":"coin.received","data":{"amount":50}}`)
+	tests := []struct {
+		name         string
+		statusCode   int
+		attempts     int
+		wantStatus   string
+		wantAttempts int
+	}{
+		{
+			name:         "Delivered with valid signature",
+			statusCode:   http.StatusOK,
+			wantStatus:   models.DeliveryStatusDelivered,
+			wantAttempts: 1,
+		},
+		{
+			name:         "Failed attempt is rescheduled",
+			statusCode:   http.StatusInternalServerError,
+			wantStatus:   models.DeliveryStatusPending,
+			wantAttempts: 1,
+		},
+		{
+			name:         "Last attempt marks delivery as failed",
+			statusCode:   http.StatusBadGateway,
+			attempts:     2,
+			wantStatus:   models.DeliveryStatusFailed,
+			wantAttempts: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				require.Equal(t, payload, body)
+				require.Equal(t, models.EventCoinReceived, r.Header.Get(webhooks.HeaderEvent))
+
+				timestamp, err := strconv.ParseInt(r.Header.Get(webhooks.HeaderTimestamp), 10, 64)
+				require.NoError(t, err)
+				require.Equal(t, webhooks.Sign("top-secret", timestamp, body), r.Header.Get(webhooks.HeaderSignature))
+				w.WriteHeader(tt.statusCode)
+			}))
+			defer ts.Close()
+
+			store := &fakeStore{due: []models.WebhookDelivery{{
+				ID:        1,
+				URL:       ts.URL,
+				Secret:    "top-secret",
+				EventType: models.EventCoinReceived,
+				Payload:   payload,
+				Status:    models.DeliveryStatusPending,
+				Attempts:  tt.attempts,
+			}}}
+			dispatcher := webhooks.NewDispatcher(store, webhooks.Config{
+				BatchSize:   10,
+				MaxAttempts: 3,
+				BaseBackoff: time.Second,
+				MaxBackoff:  time.Minute,
+				Timeout:     time.Second,
+			})
+
+			require.NoError(t, dispatcher.DeliverDue(context.Background()))
+			require.Len(t, store.updated, 1)
+			got := store.updated[0]
+			require.Equal(t, tt.wantStatus, got.Status)
+			require.Equal(t, tt.wantAttempts, got.Attempts)
+			require.Equal(t, tt.statusCode, got.ResponseCode)
+			if tt.wantStatus == models.DeliveryStatusPending {
+				require.True(t, got.NextAttemptAt.After(time.Now()))
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	require.Equal(t, time.Second, webhooks.Backoff(1, time.Second, time.Minute))
+	require.Equal(t, 4*time.Second, webhooks.Backoff(3, time.Second, time.Minute))
+	require.Equal(t, time.Minute, webhooks.Backoff(20, time.Second, time.Minute))
+}